@@ -0,0 +1,120 @@
+package main
+
+import (
+	"b-resto/services"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// dbCommands son los subcomandos que necesitan la base de datos migrada (el resto corre sin conectarse)
+var dbCommands = map[string]bool{
+	"recompute-kardex": true,
+}
+
+// runCommand ejecuta un subcomando del binario en lugar de levantar el servidor
+func runCommand(name string, args []string) error {
+	switch name {
+	case "recompute-kardex":
+		return recomputeKardexCommand(args)
+	case "fake-printer":
+		return fakePrinterCommand(args)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+// recomputeKardexCommand: b-resto recompute-kardex [--product ID] [--warehouse ID] [--dry-run]
+func recomputeKardexCommand(args []string) error {
+	fs := flag.NewFlagSet("recompute-kardex", flag.ContinueOnError)
+	productID := fs.Uint("product", 0, "ID del producto (0 = todos)")
+	warehouseID := fs.Uint("warehouse", 0, "ID del almacén (0 = todos)")
+	dryRun := fs.Bool("dry-run", false, "Solo informar los cambios, sin guardarlos")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	result, err := services.NewInventoryService().RecomputeKardex(*productID, *warehouseID, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range result.Changes {
+		log.Printf("inventory #%d (product %d, warehouse %d): balance %.4f → %.4f, cost %.4f → %.4f, total %.2f → %.2f",
+			change.InventoryID, change.ProductID, change.WarehouseID,
+			change.Before.QuantityBalance, change.After.QuantityBalance,
+			change.Before.CostBalance, change.After.CostBalance,
+			change.Before.TotalBalance, change.After.TotalBalance)
+	}
+
+	status := "saved"
+	if result.DryRun {
+		status = "dry run, nothing saved"
+	}
+	log.Printf("✅ %d movements replayed, %d changed (%s)", result.Movements, len(result.Changes), status)
+
+	return nil
+}
+
+// fakePrinterCommand: b-resto fake-printer [--addr :9100] [--out DIR]
+// Levanta una impresora simulada que guarda cada documento recibido y muestra su texto en el log
+func fakePrinterCommand(args []string) error {
+	fs := flag.NewFlagSet("fake-printer", flag.ContinueOnError)
+	addr := fs.String("addr", ":9100", "Dirección en la que escuchar")
+	out := fs.String("out", "", "Carpeta donde guardar los documentos (vacío = no guardar)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *out != "" {
+		if err := os.MkdirAll(*out, 0o755); err != nil {
+			return err
+		}
+	}
+
+	var mu sync.Mutex
+	count := 0
+	printer, err := services.StartFakePrinter(*addr, func(data []byte) {
+		mu.Lock()
+		count++
+		number := count
+		mu.Unlock()
+
+		if *out != "" {
+			name := filepath.Join(*out, fmt.Sprintf("%s-%03d.bin", time.Now().Format("20060102-150405"), number))
+			if err := os.WriteFile(name, data, 0o644); err != nil {
+				log.Printf("⚠️ failed to save job %d: %v", number, err)
+			}
+		}
+		log.Printf("🖨️ job %d (%d bytes)\n%s", number, len(data), printableText(data))
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("✅ Fake printer listening on %s", printer.Addr())
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	return printer.Close()
+}
+
+// printableText deja los caracteres imprimibles del documento (vista previa aproximada: algunos
+// parámetros de los comandos ESC/POS también son imprimibles)
+func printableText(data []byte) string {
+	var b strings.Builder
+	for _, c := range data {
+		if c == '\n' || (c >= 0x20 && c < 0x7F) {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -43,6 +43,22 @@ func SeedCasbinPolicies() {
 	Enforcer.AddPolicy("user_role", "/api/units", "GET")
 	Enforcer.AddPolicy("user_role", "/api/units/*", "GET")
 
+	// Caja: los cajeros abren, operan y cierran sus sesiones (el reconteo lo valida el servicio)
+	for _, method := range []string{"GET", "POST", "PATCH"} {
+		Enforcer.AddPolicy("user_role", "/api/pos-sessions", method)
+		Enforcer.AddPolicy("user_role", "/api/pos-sessions/*", method)
+	}
+	Enforcer.AddPolicy("user_role", "/api/z-reports", "GET")
+
+	// Salón: órdenes, cobros, devoluciones e impresión
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		Enforcer.AddPolicy("user_role", "/api/orders", method)
+		Enforcer.AddPolicy("user_role", "/api/orders/*", method)
+	}
+	Enforcer.AddPolicy("user_role", "/api/refunds", "GET")
+	Enforcer.AddPolicy("user_role", "/api/refunds/*", "GET")
+	Enforcer.AddPolicy("user_role", "/api/print-jobs/*", "POST")
+
 	// Guardar cambios
 	Enforcer.SavePolicy()
 	log.Println("✅ Casbin policies seeded")
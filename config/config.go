@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -21,6 +22,15 @@ func GetEnvironment() string {
 	return os.Getenv("ENVIRONMENT")
 }
 
+// GetAdjustmentApprovalThreshold returns the adjustment value above which approval is required (0 = disabled)
+func GetAdjustmentApprovalThreshold() float64 {
+	threshold, err := strconv.ParseFloat(os.Getenv("ADJUSTMENT_APPROVAL_THRESHOLD"), 64)
+	if err != nil {
+		return 0
+	}
+	return threshold
+}
+
 var (
 	JWTSecret       = []byte("your_secret_key_change_in_production")
 	TokenExpiration = 1 * time.Hour
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSessionCashCounts godoc
+// @Summary      Listar conteos de caja
+// @Description  Obtiene los conteos por denominación de una sesión (apertura, cierre y reconteo). En cierre ciego el esperado y la diferencia solo los ve un supervisor hasta que la sesión se cierra
+// @Tags         pos-sessions
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID de la sesión"
+// @Success      200  {object}  map[string]interface{}  "data: array de cash counts"
+// @Failure      404  {object}  map[string]string       "error: POS session not found"
+// @Router       /pos-sessions/{id}/cash-counts [get]
+// @Security     Bearer
+func GetSessionCashCounts(c *gin.Context) {
+	sessionID, ok := sessionIDParam(c)
+	if !ok {
+		return
+	}
+
+	var session models.POSSession
+	if err := config.DB.Preload("POS").First(&session, sessionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "POS session not found"})
+		return
+	}
+
+	var counts []models.CashCount
+	if err := config.DB.Where("pos_session_id = ?", sessionID).
+		Preload("Lines").Preload("CountedByUser").
+		Order("id asc").
+		Find(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash counts"})
+		return
+	}
+
+	if blindFor(c, &session) {
+		for i := range counts {
+			counts[i].Expected = nil
+			counts[i].Difference = nil
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": counts})
+}
+
+// GetSessionCash godoc
+// @Summary      Efectivo esperado
+// @Description  Detalle del efectivo esperado de la sesión por moneda: fondo inicial, cobros en efectivo, vuelto, ingresos y egresos. En terminales con cierre ciego solo un supervisor lo ve antes del cierre
+// @Tags         pos-sessions
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID de la sesión"
+// @Success      200  {object}  map[string]interface{}  "data: efectivo esperado"
+// @Failure      403  {object}  map[string]string       "error: cierre ciego"
+// @Failure      404  {object}  map[string]string       "error: POS session not found"
+// @Router       /pos-sessions/{id}/cash [get]
+// @Security     Bearer
+func GetSessionCash(c *gin.Context) {
+	sessionID, ok := sessionIDParam(c)
+	if !ok {
+		return
+	}
+
+	var session models.POSSession
+	if err := config.DB.Preload("POS").First(&session, sessionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "POS session not found"})
+		return
+	}
+	if blindFor(c, &session) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Blind close: the expected cash is shown after the session is closed"})
+		return
+	}
+
+	cash, err := services.NewPOSSessionService().SessionCash(session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cash})
+}
+
+// RecountPOSSession godoc
+// @Summary      Reconteo del supervisor
+// @Description  Segundo conteo de una sesión en closing (la diferencia del cajero superó el umbral). Lo hace un supervisor distinto del cajero; la sesión se cierra con este conteo y se genera el reporte Z
+// @Tags         pos-sessions
+// @Accept       json
+// @Produce      json
+// @Param        id       path  int                     true  "ID de la sesión"
+// @Param        request  body  map[string]interface{}  true  "amount o denominations [{currency, type, value, quantity}], notes"
+// @Success      200  {object}  map[string]interface{}  "message, data, count, cash y z_report"
+// @Failure      400  {object}  map[string]string       "error: validación, sesión sin reconteo pendiente o usuario no supervisor"
+// @Router       /pos-sessions/{id}/recount [post]
+// @Security     Bearer
+func RecountPOSSession(c *gin.Context) {
+	sessionID, ok := sessionIDParam(c)
+	if !ok {
+		return
+	}
+
+	var count services.CashCountInput
+	if err := c.ShouldBindJSON(&count); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := services.NewPOSSessionService().Recount(sessionID, count, currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondCloseResult(c, result, "POS session recounted and closed successfully")
+}
+
+// respondCloseResult responde un cierre o reconteo. Mientras falte el reconteo de un terminal con
+// cierre ciego, la respuesta no muestra el esperado ni la diferencia.
+func respondCloseResult(c *gin.Context, result *services.CloseResult, message string) {
+	if result.RecountRequired {
+		if pos := result.Session.POS; pos != nil && pos.BlindClose {
+			result.Count.Expected = nil
+			result.Count.Difference = nil
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":          "Cash difference exceeds the threshold, a supervisor must recount",
+			"data":             result.Session,
+			"count":            result.Count,
+			"recount_required": true,
+		})
+		return
+	}
+
+	cash, err := services.NewPOSSessionService().SessionCash(result.Session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          message,
+		"data":             result.Session,
+		"count":            result.Count,
+		"recount_required": false,
+		"cash":             cash,
+		"z_report":         result.ZReport,
+	})
+}
+
+// blindFor indica si hay que ocultar el esperado al usuario: terminal con cierre ciego, sesión
+// aún no cerrada y usuario que no es supervisor
+func blindFor(c *gin.Context, session *models.POSSession) bool {
+	if session.POS == nil || !session.POS.BlindClose || session.Status == services.SessionStatusClosed {
+		return false
+	}
+	user := currentUser(c)
+	return user == nil || user.Role != models.AdminRole
+}
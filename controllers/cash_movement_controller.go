@@ -3,8 +3,11 @@ package controllers
 import (
 	"b-resto/config"
 	"b-resto/models"
+	"b-resto/services"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,7 +27,7 @@ func GetCashMovements(c *gin.Context) {
 	sessionID := c.Param("id")
 	var movements []models.CashMovement
 
-	if err := config.DB.Where("pos_session_id = ?", sessionID).
+	if err := config.DB.Where("cash_register_id = ?", sessionID).
 		Find(&movements).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash movements"})
 		return
@@ -35,14 +38,14 @@ func GetCashMovements(c *gin.Context) {
 
 // CreateCashMovement godoc
 // @Summary      Crear movimiento de efectivo
-// @Description  Registra un nuevo movimiento de efectivo (ingreso/egreso)
+// @Description  Registra un nuevo movimiento de efectivo (ingreso/egreso) en una sesión abierta. El efectivo en otra moneda se envía con currency y tendered_amount; amount se calcula en moneda base con el tipo de cambio del día
 // @Tags         cash-movements
 // @Accept       json
 // @Produce      json
 // @Param        session_id  path  int                   true  "ID de la sesión"
 // @Param        movement    body  models.CashMovement   true  "Datos del movimiento"
 // @Success      201  {object}  map[string]interface{}  "message y data"
-// @Failure      400  {object}  map[string]string       "error: validación"
+// @Failure      400  {object}  map[string]string       "error: validación, sesión no abierta o sin tipo de cambio"
 // @Router       /pos-sessions/{session_id}/cash-movements [post]
 // @Security     Bearer
 func CreateCashMovement(c *gin.Context) {
@@ -62,8 +65,9 @@ func CreateCashMovement(c *gin.Context) {
 	}
 	movement.POSSessionID = sessionIDUint
 
-	if err := config.DB.Create(&movement).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create cash movement"})
+	sessionService := services.NewPOSSessionService()
+	if err := sessionService.AddCashMovement(&movement); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -75,27 +79,35 @@ func CreateCashMovement(c *gin.Context) {
 
 // DeleteCashMovement godoc
 // @Summary      Eliminar movimiento de efectivo
-// @Description  Elimina un movimiento de efectivo de una sesión de caja
+// @Description  Elimina un movimiento de efectivo de una sesión abierta. No se eliminan movimientos de sesiones contadas o cerradas ni el pago en efectivo de una devolución
 // @Tags         cash-movements
 // @Accept       json
 // @Produce      json
 // @Param        session_id   path  int  true  "ID de la sesión"
 // @Param        movement_id  path  int  true  "ID del movimiento"
 // @Success      200  {object}  map[string]string  "message: Cash movement deleted successfully"
+// @Failure      400  {object}  map[string]string  "error: sesión no abierta o movimiento de una devolución"
 // @Failure      404  {object}  map[string]string  "error: Cash movement not found"
 // @Router       /pos-sessions/{session_id}/cash-movements/{movement_id} [delete]
 // @Security     Bearer
 func DeleteCashMovement(c *gin.Context) {
-	movementID := c.Param("movement_id")
-	var movement models.CashMovement
-
-	if err := config.DB.First(&movement, movementID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Cash movement not found"})
+	sessionID, ok := sessionIDParam(c)
+	if !ok {
+		return
+	}
+	movementID, err := strconv.ParseUint(c.Param("movement_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movement ID"})
 		return
 	}
 
-	if err := config.DB.Delete(&movement).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete cash movement"})
+	sessionService := services.NewPOSSessionService()
+	if err := sessionService.DeleteCashMovement(sessionID, uint(movementID)); err != nil {
+		if errors.Is(err, services.ErrCashMovementNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cash movement not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentUser obtiene el usuario autenticado (nil si la petición no trae token, p.ej. en desarrollo)
+func currentUser(c *gin.Context) *models.User {
+	username, exists := c.Get("username")
+	if !exists {
+		return nil
+	}
+
+	var user models.User
+	if err := config.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil
+	}
+
+	return &user
+}
+
+// currentUserID obtiene el ID del usuario autenticado (nil si la petición no trae token, p.ej. en desarrollo)
+func currentUserID(c *gin.Context) *uint {
+	if user := currentUser(c); user != nil {
+		return &user.ID
+	}
+	return nil
+}
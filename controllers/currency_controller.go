@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/services"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCurrencies godoc
+// @Summary      Listar monedas
+// @Description  Obtiene las monedas aceptadas en caja
+// @Tags         currencies
+// @Accept       json
+// @Produce      json
+// @Param        is_active  query  string  false  "Filtrar por estado activo"  Enums(true, false)
+// @Success      200  {object}  map[string]interface{}  "data: array de currencies"
+// @Failure      500  {object}  map[string]string       "error: mensaje"
+// @Router       /currencies [get]
+// @Security     Bearer
+func GetCurrencies(c *gin.Context) {
+	var currencies []models.Currency
+
+	query := config.DB
+	if isActive := c.Query("is_active"); isActive != "" {
+		query = query.Where("is_active = ?", isActive == "true")
+	}
+
+	if err := query.Order("code asc").Find(&currencies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch currencies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": currencies})
+}
+
+// CreateCurrency godoc
+// @Summary      Crear moneda
+// @Description  Registra una moneda (código ISO 4217, ej: USD)
+// @Tags         currencies
+// @Accept       json
+// @Produce      json
+// @Param        currency  body  models.Currency  true  "Datos de la moneda"
+// @Success      201  {object}  map[string]interface{}  "message y data creada"
+// @Failure      400  {object}  map[string]string       "error: validación"
+// @Failure      409  {object}  map[string]string       "error: código duplicado"
+// @Router       /currencies [post]
+// @Security     Bearer
+func CreateCurrency(c *gin.Context) {
+	var currency models.Currency
+
+	if err := c.ShouldBindJSON(&currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	currency.Code = strings.ToUpper(currency.Code)
+
+	var existing models.Currency
+	if err := config.DB.Where("code = ?", currency.Code).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Currency with this code already exists"})
+		return
+	}
+
+	if err := config.DB.Create(&currency).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create currency"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Currency created successfully",
+		"data":    currency,
+	})
+}
+
+// UpdateCurrency godoc
+// @Summary      Actualizar moneda
+// @Description  Actualiza el nombre y el símbolo de una moneda (el código no cambia: lo usan los pagos y los tipos de cambio)
+// @Tags         currencies
+// @Accept       json
+// @Produce      json
+// @Param        id        path  int              true  "ID de la moneda"
+// @Param        currency  body  models.Currency  true  "Datos actualizados"
+// @Success      200  {object}  map[string]interface{}  "message y data actualizada"
+// @Failure      400  {object}  map[string]string       "error: validación"
+// @Failure      404  {object}  map[string]string       "error: Currency not found"
+// @Router       /currencies/{id} [put]
+// @Security     Bearer
+func UpdateCurrency(c *gin.Context) {
+	id := c.Param("id")
+	var currency models.Currency
+
+	if err := config.DB.First(&currency, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Currency not found"})
+		return
+	}
+
+	var updateData models.Currency
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Model(&currency).Updates(map[string]interface{}{
+		"name":   updateData.Name,
+		"symbol": updateData.Symbol,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update currency"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Currency updated successfully",
+		"data":    currency,
+	})
+}
+
+// ToggleCurrencyStatus godoc
+// @Summary      Activar/Desactivar moneda
+// @Description  Cambia el estado is_active de una moneda (las inactivas no admiten nuevos tipos de cambio)
+// @Tags         currencies
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID de la moneda"
+// @Success      200  {object}  map[string]interface{}  "message y data con nuevo estado"
+// @Failure      404  {object}  map[string]string       "error: Currency not found"
+// @Router       /currencies/{id}/toggle [patch]
+// @Security     Bearer
+func ToggleCurrencyStatus(c *gin.Context) {
+	id := c.Param("id")
+	var currency models.Currency
+
+	if err := config.DB.First(&currency, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Currency not found"})
+		return
+	}
+
+	currency.IsActive = !currency.IsActive
+
+	if err := config.DB.Save(&currency).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to toggle status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Status toggled successfully",
+		"data":    currency,
+	})
+}
+
+// GetExchangeRates godoc
+// @Summary      Listar tipos de cambio
+// @Description  Obtiene el historial de tipos de cambio, del más reciente al más antiguo
+// @Tags         currencies
+// @Accept       json
+// @Produce      json
+// @Param        company_id  query  int     false  "Filtrar por compañía"
+// @Param        currency    query  string  false  "Filtrar por moneda (ej: USD)"
+// @Success      200  {object}  map[string]interface{}  "data: array de exchange rates"
+// @Failure      500  {object}  map[string]string       "error: mensaje"
+// @Router       /exchange-rates [get]
+// @Security     Bearer
+func GetExchangeRates(c *gin.Context) {
+	var rates []models.ExchangeRate
+
+	query := config.DB
+	if companyID := c.Query("company_id"); companyID != "" {
+		query = query.Where("company_id = ?", companyID)
+	}
+	if currency := c.Query("currency"); currency != "" {
+		query = query.Where("currency_code = ?", strings.ToUpper(currency))
+	}
+
+	if err := query.Order("date desc, currency_code asc").Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rates})
+}
+
+// GetCurrentExchangeRate godoc
+// @Summary      Tipo de cambio vigente
+// @Description  Tipo de cambio que se aplica a una moneda en una fecha (el último registrado hasta ese día); es el que usan los pagos y movimientos de caja
+// @Tags         currencies
+// @Accept       json
+// @Produce      json
+// @Param        company_id  query  int     true   "ID de la compañía"
+// @Param        currency    query  string  true   "Moneda (ej: USD)"
+// @Param        date        query  string  false  "Fecha (YYYY-MM-DD, por defecto hoy)"
+// @Success      200  {object}  map[string]interface{}  "data: currency, date, rate"
+// @Failure      400  {object}  map[string]string       "error: parámetros inválidos o sin tipo de cambio"
+// @Router       /exchange-rates/current [get]
+// @Security     Bearer
+func GetCurrentExchangeRate(c *gin.Context) {
+	var query struct {
+		CompanyID uint   `form:"company_id" binding:"required"`
+		Currency  string `form:"currency" binding:"required,len=3"`
+		Date      string `form:"date" binding:"omitempty,datetime=2006-01-02"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	date := time.Now()
+	if query.Date != "" {
+		date, _ = time.ParseInLocation("2006-01-02", query.Date, time.Local)
+	}
+
+	rate, err := services.NewCurrencyService().Rate(query.CompanyID, query.Currency, date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"company_id": query.CompanyID,
+		"currency":   strings.ToUpper(query.Currency),
+		"date":       date.Format("2006-01-02"),
+		"rate":       rate,
+	}})
+}
+
+// SetExchangeRate godoc
+// @Summary      Registrar tipo de cambio
+// @Description  Registra el tipo de cambio del día de una moneda para la compañía: cuánto vale una unidad en la moneda base. Si ya hay uno para esa fecha, lo reemplaza
+// @Tags         currencies
+// @Accept       json
+// @Produce      json
+// @Param        rate  body  map[string]interface{}  true  "company_id, currency_code, rate, date (YYYY-MM-DD, por defecto hoy)"
+// @Success      201  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: validación, moneda inactiva o moneda base"
+// @Router       /exchange-rates [post]
+// @Security     Bearer
+func SetExchangeRate(c *gin.Context) {
+	var request struct {
+		CompanyID    uint    `json:"company_id" binding:"required"`
+		CurrencyCode string  `json:"currency_code" binding:"required,len=3"`
+		Rate         float64 `json:"rate" binding:"required,gt=0"`
+		Date         string  `json:"date" binding:"omitempty,datetime=2006-01-02"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rate := models.ExchangeRate{
+		CompanyID:    request.CompanyID,
+		CurrencyCode: request.CurrencyCode,
+		Rate:         request.Rate,
+	}
+	if request.Date != "" {
+		rate.Date, _ = time.ParseInLocation("2006-01-02", request.Date, time.Local)
+	}
+
+	if err := services.NewCurrencyService().SetRate(&rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Exchange rate saved successfully",
+		"data":    rate,
+	})
+}
+
+// DeleteExchangeRate godoc
+// @Summary      Eliminar tipo de cambio
+// @Description  Elimina un tipo de cambio (los pagos ya registrados conservan el que usaron)
+// @Tags         currencies
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID del tipo de cambio"
+// @Success      200  {object}  map[string]string  "message: Exchange rate deleted successfully"
+// @Failure      404  {object}  map[string]string  "error: Exchange rate not found"
+// @Router       /exchange-rates/{id} [delete]
+// @Security     Bearer
+func DeleteExchangeRate(c *gin.Context) {
+	id := c.Param("id")
+	var rate models.ExchangeRate
+
+	if err := config.DB.First(&rate, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exchange rate not found"})
+		return
+	}
+
+	if err := config.DB.Delete(&rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete exchange rate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exchange rate deleted successfully"})
+}
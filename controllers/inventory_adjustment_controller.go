@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetInventoryAdjustments godoc
+// @Summary      Listar ajustes de inventario
+// @Description  Obtiene la lista de ajustes manuales de inventario con filtros
+// @Tags         inventory-adjustments
+// @Accept       json
+// @Produce      json
+// @Param        status        query  string  false  "Filtrar por estado"  Enums(pending, done, rejected)
+// @Param        reason        query  string  false  "Filtrar por motivo"  Enums(waste, breakage, count_correction, staff_meal, other)
+// @Param        warehouse_id  query  int     false  "Filtrar por almacén"
+// @Param        product_id    query  int     false  "Filtrar por producto"
+// @Success      200  {object}  map[string]interface{}  "data: array de adjustments"
+// @Failure      500  {object}  map[string]string       "error: mensaje"
+// @Router       /inventory-adjustments [get]
+// @Security     Bearer
+func GetInventoryAdjustments(c *gin.Context) {
+	var adjustments []models.InventoryAdjustment
+
+	query := config.DB
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if reason := c.Query("reason"); reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+	}
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+
+	if err := query.Preload("Warehouse").Preload("Product").Order("id desc").Find(&adjustments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inventory adjustments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": adjustments})
+}
+
+// GetInventoryAdjustment godoc
+// @Summary      Obtener ajuste de inventario
+// @Description  Obtiene un ajuste de inventario por ID
+// @Tags         inventory-adjustments
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID del ajuste"
+// @Success      200  {object}  map[string]interface{}  "data: adjustment"
+// @Failure      404  {object}  map[string]string       "error: Inventory adjustment not found"
+// @Router       /inventory-adjustments/{id} [get]
+// @Security     Bearer
+func GetInventoryAdjustment(c *gin.Context) {
+	id := c.Param("id")
+	var adjustment models.InventoryAdjustment
+
+	if err := config.DB.
+		Preload("Warehouse").
+		Preload("Product").
+		Preload("CreatedByUser").
+		Preload("ApprovedByUser").
+		First(&adjustment, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory adjustment not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": adjustment})
+}
+
+// ApproveInventoryAdjustment godoc
+// @Summary      Aprobar ajuste de inventario
+// @Description  Aprueba un ajuste pendiente y registra su movimiento en el Kardex
+// @Tags         inventory-adjustments
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID del ajuste"
+// @Success      200  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: estado inválido o stock insuficiente"
+// @Router       /inventory-adjustments/{id}/approve [patch]
+// @Security     Bearer
+func ApproveInventoryAdjustment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid adjustment ID"})
+		return
+	}
+
+	inventoryService := services.NewInventoryService()
+	adjustment, err := inventoryService.ApproveAdjustment(uint(id), currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Inventory adjustment approved and applied",
+		"data":    adjustment,
+	})
+}
+
+// RejectInventoryAdjustment godoc
+// @Summary      Rechazar ajuste de inventario
+// @Description  Rechaza un ajuste pendiente sin registrar movimiento
+// @Tags         inventory-adjustments
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID del ajuste"
+// @Success      200  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: estado inválido"
+// @Router       /inventory-adjustments/{id}/reject [patch]
+// @Security     Bearer
+func RejectInventoryAdjustment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid adjustment ID"})
+		return
+	}
+
+	inventoryService := services.NewInventoryService()
+	adjustment, err := inventoryService.RejectAdjustment(uint(id), currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Inventory adjustment rejected",
+		"data":    adjustment,
+	})
+}
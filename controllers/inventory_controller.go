@@ -3,6 +3,8 @@ package controllers
 import (
 	"b-resto/config"
 	"b-resto/models"
+	"b-resto/services"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -64,51 +66,86 @@ func GetInventory(c *gin.Context) {
 
 // GetInventoryByWarehouseAndProduct godoc
 // @Summary      Obtener stock específico
-// @Description  Obtiene el stock de un producto en un almacén
+// @Description  Obtiene el saldo actual (en mano, reservado y disponible) de un producto en un almacén
 // @Tags         inventories
 // @Accept       json
 // @Produce      json
 // @Param        warehouse_id  path  int  true  "ID del almacén"
 // @Param        product_id    path  int  true  "ID del producto"
-// @Success      200  {object}  map[string]interface{}  "data: inventory"
+// @Success      200  {object}  map[string]interface{}  "data: stock quant"
 // @Failure      404  {object}  map[string]string       "error: Inventory not found"
 // @Router       /inventories/warehouse/{warehouse_id}/product/{product_id} [get]
 // @Security     Bearer
 func GetInventoryByWarehouseAndProduct(c *gin.Context) {
 	warehouseID := c.Param("warehouse_id")
 	productID := c.Param("product_id")
-	var inventory models.Inventory
+	var quant models.StockQuant
 
 	if err := config.DB.
 		Where("warehouse_id = ? AND product_id = ?", warehouseID, productID).
 		Preload("Warehouse").
 		Preload("Product").
-		First(&inventory).Error; err != nil {
+		First(&quant).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": inventory})
+	c.JSON(http.StatusOK, gin.H{"data": quant})
+}
+
+// GetStock godoc
+// @Summary      Existencias actuales
+// @Description  Lista el saldo actual (en mano, reservado, disponible y valor) por producto en todos los almacenes
+// @Tags         inventories
+// @Accept       json
+// @Produce      json
+// @Param        warehouse_id           query  int   false  "Filtrar por almacén"
+// @Param        category_id            query  int   false  "Filtrar por categoría de producto"
+// @Param        inventory_category_id  query  int   false  "Filtrar por categoría de inventario"
+// @Param        zero                   query  bool  false  "Solo saldos en cero"
+// @Param        negative               query  bool  false  "Solo saldos negativos"
+// @Success      200  {object}  map[string]interface{}  "data: array de stock quants"
+// @Failure      500  {object}  map[string]string       "error: mensaje"
+// @Router       /inventories/stock [get]
+// @Security     Bearer
+func GetStock(c *gin.Context) {
+	inventoryService := services.NewInventoryService()
+
+	quants, err := inventoryService.ListStock(services.StockFilter{
+		WarehouseID:         c.Query("warehouse_id"),
+		CategoryID:          c.Query("category_id"),
+		InventoryCategoryID: c.Query("inventory_category_id"),
+		Zero:                c.Query("zero") == "true",
+		Negative:            c.Query("negative") == "true",
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": quants})
 }
 
 // AdjustInventory godoc
 // @Summary      Ajustar inventario
-// @Description  Realiza un ajuste manual de inventario (entrada/salida)
+// @Description  Registra un ajuste manual de inventario (entrada/salida) como movimiento de Kardex. Si su valor supera el umbral configurado queda pendiente de aprobación
 // @Tags         inventories
 // @Accept       json
 // @Produce      json
-// @Param        adjustment  body  map[string]interface{}  true  "warehouse_id, product_id, quantity, type (in/out), reason"
-// @Success      200  {object}  map[string]interface{}  "message y data"
-// @Failure      400  {object}  map[string]string       "error: validación"
+// @Param        adjustment  body  map[string]interface{}  true  "warehouse_id, product_id, quantity, type (in/out), reason (waste, breakage, count_correction, staff_meal, other), unit_cost, notes"
+// @Success      201  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: validación o stock insuficiente"
 // @Router       /inventories/adjust [post]
 // @Security     Bearer
 func AdjustInventory(c *gin.Context) {
 	var request struct {
 		WarehouseID uint    `json:"warehouse_id" binding:"required"`
 		ProductID   uint    `json:"product_id" binding:"required"`
-		Quantity    float64 `json:"quantity" binding:"required"`
+		Quantity    float64 `json:"quantity" binding:"required,gt=0"`
 		Type        string  `json:"type" binding:"required,oneof=in out"` // in/out
-		Reason      string  `json:"reason"`
+		Reason      string  `json:"reason" binding:"required,oneof=waste breakage count_correction staff_meal other"`
+		UnitCost    float64 `json:"unit_cost" binding:"omitempty,gte=0"` // Solo entradas; por defecto el costo vigente
+		Notes       string  `json:"notes"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -116,72 +153,180 @@ func AdjustInventory(c *gin.Context) {
 		return
 	}
 
-	// Buscar o crear inventario
-	var inventory models.Inventory
-	result := config.DB.Where("warehouse_id = ? AND product_id = ?", request.WarehouseID, request.ProductID).First(&inventory)
-
-	if result.Error != nil {
-		// Crear nuevo registro de inventario
-		inventory = models.Inventory{
-			WarehouseID:     request.WarehouseID,
-			ProductID:       request.ProductID,
-			QuantityBalance: 0,
-		}
-	}
-
-	// Ajustar cantidad
-	if request.Type == "in" {
-		inventory.QuantityBalance += request.Quantity
-	} else {
-		inventory.QuantityBalance -= request.Quantity
-		if inventory.QuantityBalance < 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient stock"})
-			return
-		}
-	}
-
-	// Guardar o actualizar
-	if result.Error != nil {
-		if err := config.DB.Create(&inventory).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create inventory"})
-			return
-		}
-	} else {
-		if err := config.DB.Save(&inventory).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory"})
-			return
-		}
+	adjustment := models.InventoryAdjustment{
+		WarehouseID: request.WarehouseID,
+		ProductID:   request.ProductID,
+		Type:        request.Type,
+		Quantity:    request.Quantity,
+		Reason:      request.Reason,
+		UnitCost:    request.UnitCost,
+		Notes:       request.Notes,
+		CreatedBy:   currentUserID(c),
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Inventory adjusted successfully",
-		"data":    inventory,
+	inventoryService := services.NewInventoryService()
+	if err := inventoryService.CreateAdjustment(&adjustment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Inventory error: %s", err.Error())})
+		return
+	}
+
+	message := "Inventory adjusted successfully"
+	if adjustment.Status == "pending" {
+		message = "Adjustment exceeds the approval threshold and is pending approval"
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": message,
+		"data":    adjustment,
 	})
 }
 
 // GetLowStockProducts godoc
 // @Summary      Productos con stock bajo
-// @Description  Obtiene productos que están por debajo del stock mínimo
+// @Description  Evalúa el saldo actual de cada producto contra sus reglas de reabastecimiento y devuelve los que están bajo el mínimo
 // @Tags         inventories
 // @Accept       json
 // @Produce      json
-// @Success      200  {object}  map[string]interface{}  "data: array de inventories con stock bajo"
+// @Param        warehouse_id  query  int  false  "Filtrar por almacén"
+// @Success      200  {object}  map[string]interface{}  "data: array de productos bajo mínimo con cantidad sugerida"
 // @Failure      500  {object}  map[string]string       "error: mensaje"
 // @Router       /inventories/low-stock [get]
 // @Security     Bearer
 func GetLowStockProducts(c *gin.Context) {
-	var inventories []models.Inventory
+	inventoryService := services.NewInventoryService()
 
-	// Productos donde quantity_on_hand <= min_quantity
-	// Nota: El modelo Inventory no tiene min_quantity, ajustar según necesidad
-	if err := config.DB.
-		Where("quantity_balance < ?", 10). // Stock bajo si < 10
-		Preload("Warehouse").
-		Preload("Product").
-		Find(&inventories).Error; err != nil {
+	items, err := inventoryService.GetLowStock(c.Query("warehouse_id"))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch low stock products"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": inventories})
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// ProposeReorderPurchaseOrders godoc
+// @Summary      Proponer órdenes de compra
+// @Description  Crea una orden de compra en borrador por proveedor preferido con todo lo que está bajo el mínimo
+// @Tags         inventories
+// @Accept       json
+// @Produce      json
+// @Param        warehouse_id  query  int  false  "Limitar a un almacén"
+// @Success      201  {object}  map[string]interface{}  "message, data: purchase orders creadas, without_supplier: productos sin proveedor"
+// @Failure      500  {object}  map[string]string       "error: mensaje"
+// @Router       /inventories/low-stock/purchase-orders [post]
+// @Security     Bearer
+func ProposeReorderPurchaseOrders(c *gin.Context) {
+	inventoryService := services.NewInventoryService()
+
+	orders, withoutSupplier, err := inventoryService.ProposePurchaseOrders(c.Query("warehouse_id"), currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to propose purchase orders: %s", err.Error())})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":          fmt.Sprintf("%d draft purchase orders created", len(orders)),
+		"data":             orders,
+		"without_supplier": withoutSupplier,
+	})
+}
+
+// GetInventoryValuation godoc
+// @Summary      Valorización de inventario
+// @Description  Obtiene el saldo valorizado (costo promedio ponderado) de cada producto por almacén
+// @Tags         inventories
+// @Accept       json
+// @Produce      json
+// @Param        warehouse_id  query  int  false  "Filtrar por almacén"
+// @Success      200  {object}  map[string]interface{}  "data: stock quants por producto/almacén, total_value: valor total"
+// @Failure      500  {object}  map[string]string       "error: mensaje"
+// @Router       /inventories/valuation [get]
+// @Security     Bearer
+func GetInventoryValuation(c *gin.Context) {
+	inventoryService := services.NewInventoryService()
+
+	balances, totalValue, err := inventoryService.GetValuation(c.Query("warehouse_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inventory valuation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        balances,
+		"total_value": totalValue,
+	})
+}
+
+// GetStockLayers godoc
+// @Summary      Capas de costo FIFO
+// @Description  Obtiene las capas de costo abiertas de un producto (entradas con saldo pendiente)
+// @Tags         inventories
+// @Accept       json
+// @Produce      json
+// @Param        product_id    query  int   false  "Filtrar por producto"
+// @Param        warehouse_id  query  int   false  "Filtrar por almacén"
+// @Param        all           query  bool  false  "Incluir capas agotadas"
+// @Success      200  {object}  map[string]interface{}  "data: array de stock layers"
+// @Failure      500  {object}  map[string]string       "error: mensaje"
+// @Router       /inventories/layers [get]
+// @Security     Bearer
+func GetStockLayers(c *gin.Context) {
+	var layers []models.StockLayer
+
+	query := config.DB
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+	}
+	if c.Query("all") != "true" {
+		query = query.Where("remaining_quantity > 0")
+	}
+
+	if err := query.Order("id asc").Find(&layers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock layers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": layers})
+}
+
+// RecomputeKardex godoc
+// @Summary      Recalcular Kardex
+// @Description  Reprocesa en orden cronológico los movimientos de un producto, un almacén o todo el inventario y reescribe saldos y costos. Devuelve los movimientos que cambiaron
+// @Tags         inventories
+// @Accept       json
+// @Produce      json
+// @Param        product_id    query  int   false  "Limitar a un producto"
+// @Param        warehouse_id  query  int   false  "Limitar a un almacén"
+// @Param        dry_run       query  bool  false  "Solo informar, sin guardar cambios"
+// @Success      200  {object}  map[string]interface{}  "message y data: movimientos recorridos y cambios"
+// @Failure      400  {object}  map[string]string       "error: parámetros inválidos"
+// @Failure      500  {object}  map[string]string       "error: mensaje"
+// @Router       /admin/inventories/recompute [post]
+// @Security     Bearer
+func RecomputeKardex(c *gin.Context) {
+	var query struct {
+		ProductID   uint `form:"product_id"`
+		WarehouseID uint `form:"warehouse_id"`
+		DryRun      bool `form:"dry_run"`
+	}
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	inventoryService := services.NewInventoryService()
+	result, err := inventoryService.RecomputeKardex(query.ProductID, query.WarehouseID, query.DryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to recompute kardex: %s", err.Error())})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("%d movements replayed, %d changed", result.Movements, len(result.Changes)),
+		"data":    result,
+	})
 }
@@ -3,11 +3,23 @@ package controllers
 import (
 	"b-resto/config"
 	"b-resto/models"
+	"b-resto/services"
+	"encoding/json"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	kitchenHeartbeat  = 15 * time.Second // Intervalo de keep-alive del feed SSE
+	kitchenEventBatch = 100              // Eventos por lectura al ponerse al día
+)
+
 // GetKitchenTickets godoc
 // @Summary      Listar tickets de cocina
 // @Description  Obtiene lista de tickets de cocina con filtros
@@ -25,13 +37,13 @@ func GetKitchenTickets(c *gin.Context) {
 
 	query := config.DB
 	if status := c.Query("status"); status != "" {
-		query = query.Where("status = ?", status)
+		query = query.Where("state = ?", status)
 	}
 	if stationID := c.Query("station_id"); stationID != "" {
 		query = query.Where("kitchen_station_id = ?", stationID)
 	}
 
-	if err := query.Preload("Order").Preload("KitchenStation").Find(&tickets).Error; err != nil {
+	if err := query.Preload("Order").Preload("KitchenStation").Preload("Items.OrderItem.Product").Find(&tickets).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch kitchen tickets"})
 		return
 	}
@@ -54,7 +66,7 @@ func GetKitchenTicket(c *gin.Context) {
 	id := c.Param("id")
 	var ticket models.KitchenTicket
 
-	if err := config.DB.Preload("Order").Preload("KitchenStation").First(&ticket, id).Error; err != nil {
+	if err := config.DB.Preload("Order").Preload("KitchenStation").Preload("Items.OrderItem.Product").First(&ticket, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Kitchen ticket not found"})
 		return
 	}
@@ -85,7 +97,7 @@ func UpdateTicketStatus(c *gin.Context) {
 	}
 
 	var request struct {
-		Status string `json:"status" binding:"required"`
+		Status string `json:"status" binding:"required,oneof=pending preparing ready delivered"`
 	}
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -99,6 +111,8 @@ func UpdateTicketStatus(c *gin.Context) {
 		return
 	}
 
+	notifyTicketState(&ticket)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Ticket status updated successfully",
 		"data":    ticket,
@@ -132,6 +146,8 @@ func MarkTicketPreparing(c *gin.Context) {
 		return
 	}
 
+	notifyTicketState(&ticket)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Ticket marked as preparing",
 		"data":    ticket,
@@ -165,6 +181,8 @@ func MarkTicketReady(c *gin.Context) {
 		return
 	}
 
+	notifyTicketState(&ticket)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Ticket marked as ready",
 		"data":    ticket,
@@ -198,6 +216,8 @@ func MarkTicketDelivered(c *gin.Context) {
 		return
 	}
 
+	notifyTicketState(&ticket)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Ticket marked as delivered",
 		"data":    ticket,
@@ -221,6 +241,8 @@ func GetTicketsByStation(c *gin.Context) {
 
 	if err := config.DB.Where("kitchen_station_id = ?", stationID).
 		Preload("Order").
+		Preload("Items.OrderItem.Product").
+		Order("id asc").
 		Find(&tickets).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tickets"})
 		return
@@ -228,3 +250,99 @@ func GetTicketsByStation(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"data": tickets})
 }
+
+// notifyTicketState publica el cambio de estado en el feed de la estación. El estado ya quedó
+// guardado, así que un fallo del feed solo se registra.
+func notifyTicketState(ticket *models.KitchenTicket) {
+	if err := services.RecordKitchenEvent(ticket.ID, services.KitchenEventStateChanged); err != nil {
+		log.Printf("⚠️ Failed to publish kitchen ticket %d: %v", ticket.ID, err)
+	}
+}
+
+// StreamKitchenStation godoc
+// @Summary      Feed en tiempo real de una estación
+// @Description  Stream SSE con los eventos de tickets de la estación (created, updated, state_changed, voided); cada evento trae el ticket completo con sus líneas. Para retomar tras una reconexión se envía el último ID recibido en el header Last-Event-ID o en last_event_id; sin él, el stream empieza desde ahora
+// @Tags         kitchen-tickets
+// @Produce      text/event-stream
+// @Param        station_id     path   int  true   "ID de la estación"
+// @Param        last_event_id  query  int  false  "Último evento recibido"
+// @Success      200  {string}  string             "event-stream"
+// @Failure      404  {object}  map[string]string  "error: Kitchen station not found"
+// @Router       /kitchen-tickets/station/{station_id}/stream [get]
+// @Security     Bearer
+func StreamKitchenStation(c *gin.Context) {
+	stationID, err := strconv.ParseUint(c.Param("station_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid station ID"})
+		return
+	}
+
+	var station models.KitchenStation
+	if err := config.DB.First(&station, stationID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kitchen station not found"})
+		return
+	}
+
+	// Suscribirse antes de leer el punto de partida para no perder avisos
+	notifications, unsubscribe := services.SubscribeKitchenStation(station.ID)
+	defer unsubscribe()
+
+	resumeFrom := c.GetHeader("Last-Event-ID")
+	if resumeFrom == "" {
+		resumeFrom = c.Query("last_event_id")
+	}
+
+	var lastID uint
+	if resumeFrom != "" {
+		id, err := strconv.ParseUint(resumeFrom, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid last event ID"})
+			return
+		}
+		lastID = uint(id)
+	} else {
+		if lastID, err = services.LastKitchenEventID(station.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open kitchen feed"})
+			return
+		}
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(kitchenHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		events, err := services.KitchenEventsSince(station.ID, lastID, kitchenEventBatch)
+		if err != nil {
+			log.Printf("⚠️ Kitchen feed for station %d: %v", station.ID, err)
+			return false
+		}
+
+		for _, event := range events {
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatUint(uint64(event.ID), 10),
+				Event: event.Type,
+				Data:  json.RawMessage(event.Payload),
+			})
+			lastID = event.ID
+		}
+		if len(events) == kitchenEventBatch {
+			return true // Aún quedan eventos atrasados
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-notifications:
+		case <-heartbeat.C:
+			// Comentario SSE: mantiene viva la conexión a través de proxies
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return false
+			}
+		}
+		return true
+	})
+}
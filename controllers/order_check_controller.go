@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// orderCheckParams lee los IDs de orden y subcuenta de la ruta
+func orderCheckParams(c *gin.Context) (uint, uint, bool) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return 0, 0, false
+	}
+
+	checkID, err := strconv.ParseUint(c.Param("check_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid check ID"})
+		return 0, 0, false
+	}
+
+	return orderID, uint(checkID), true
+}
+
+// GetOrderChecks godoc
+// @Summary      Listar subcuentas de la orden
+// @Description  Obtiene las subcuentas de una orden dividida con sus líneas y pagos
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID de la orden"
+// @Success      200  {object}  map[string]interface{}  "data: array de checks"
+// @Failure      500  {object}  map[string]string       "error: mensaje"
+// @Router       /orders/{id}/checks [get]
+// @Security     Bearer
+func GetOrderChecks(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	var checks []models.OrderCheck
+	if err := config.DB.Where("order_id = ?", orderID).
+		Preload("Items").
+		Preload("Payments.PaymentMethod").
+		Order("sequence asc").
+		Find(&checks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order checks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": checks})
+}
+
+// SplitOrderChecks godoc
+// @Summary      Dividir la cuenta
+// @Description  Divide una orden abierta en subcuentas: por líneas (mode=items, admite fracciones), por asientos (mode=seats) o en partes iguales (mode=even). Reemplaza una división anterior sin pagos
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id     path  int                     true  "ID de la orden"
+// @Param        split  body  map[string]interface{}  true  "mode, parts (even), checks [[{order_item_id, quantity}]] (items)"
+// @Success      201  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: validación u orden cerrada"
+// @Router       /orders/{id}/checks [post]
+// @Security     Bearer
+func SplitOrderChecks(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Mode   string                      `json:"mode" binding:"required,oneof=items seats even"`
+		Parts  int                         `json:"parts"`
+		Checks [][]services.CheckItemShare `json:"checks" binding:"dive,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orderService := services.NewOrderService()
+	checks, err := orderService.SplitChecks(orderID, services.CheckSplit{
+		Mode:   request.Mode,
+		Parts:  request.Parts,
+		Checks: request.Checks,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Order split successfully",
+		"data":    checks,
+	})
+}
+
+// DeleteOrderChecks godoc
+// @Summary      Deshacer la división
+// @Description  Elimina las subcuentas de una orden abierta (solo si no tienen pagos)
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID de la orden"
+// @Success      200  {object}  map[string]string  "message: Order split removed successfully"
+// @Failure      400  {object}  map[string]string  "error: subcuentas con pagos u orden cerrada"
+// @Router       /orders/{id}/checks [delete]
+// @Security     Bearer
+func DeleteOrderChecks(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	orderService := services.NewOrderService()
+	if err := orderService.RemoveChecks(orderID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Order split removed successfully"})
+}
+
+// CreateOrderCheckPayment godoc
+// @Summary      Pagar subcuenta
+// @Description  Registra un pago sobre una subcuenta abierta (admite currency y tendered_amount como los pagos de orden)
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id        path  int                  true  "ID de la orden"
+// @Param        check_id  path  int                  true  "ID de la subcuenta"
+// @Param        payment   body  models.OrderPayment  true  "Datos del pago"
+// @Success      201  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: validación o subcuenta cerrada"
+// @Router       /orders/{id}/checks/{check_id}/payments [post]
+// @Security     Bearer
+func CreateOrderCheckPayment(c *gin.Context) {
+	orderID, checkID, ok := orderCheckParams(c)
+	if !ok {
+		return
+	}
+
+	var payment models.OrderPayment
+	if err := c.ShouldBindJSON(&payment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orderService := services.NewOrderService()
+	if err := orderService.PayCheck(orderID, checkID, &payment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Payment created successfully",
+		"data":    payment,
+	})
+}
+
+// CloseOrderCheck godoc
+// @Summary      Cerrar subcuenta
+// @Description  Cierra una subcuenta cuyos pagos cubren su importe. La orden se puede completar cuando todas están cerradas
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id        path  int  true  "ID de la orden"
+// @Param        check_id  path  int  true  "ID de la subcuenta"
+// @Success      200  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: pago incompleto o subcuenta cerrada"
+// @Router       /orders/{id}/checks/{check_id}/close [patch]
+// @Security     Bearer
+func CloseOrderCheck(c *gin.Context) {
+	orderID, checkID, ok := orderCheckParams(c)
+	if !ok {
+		return
+	}
+
+	orderService := services.NewOrderService()
+	check, err := orderService.CloseCheck(orderID, checkID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order check closed successfully",
+		"data":    check,
+	})
+}
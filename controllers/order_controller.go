@@ -4,8 +4,10 @@ import (
 	"b-resto/config"
 	"b-resto/models"
 	"b-resto/services"
-	"fmt"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,9 +18,10 @@ import (
 // @Tags         orders
 // @Accept       json
 // @Produce      json
-// @Param        state     query  string  false  "Filtrar por estado"  Enums(draft, confirmed, done, cancelled)
-// @Param        table_id  query  int     false  "Filtrar por mesa"
-// @Param        date      query  string  false  "Filtrar por fecha (YYYY-MM-DD)"
+// @Param        state           query  string  false  "Filtrar por estado"  Enums(draft, confirmed, done, cancelled)
+// @Param        table_id        query  int     false  "Filtrar por mesa"
+// @Param        date            query  string  false  "Filtrar por fecha (YYYY-MM-DD)"
+// @Param        pos_session_id  query  int     false  "Filtrar por sesión de caja"
 // @Success      200  {object}  map[string]interface{}  "data: array de orders"
 // @Failure      500  {object}  map[string]string       "error: mensaje"
 // @Router       /orders [get]
@@ -36,8 +39,11 @@ func GetOrders(c *gin.Context) {
 	if date := c.Query("date"); date != "" {
 		query = query.Where("order_date = ?", date)
 	}
+	if sessionID := c.Query("pos_session_id"); sessionID != "" {
+		query = query.Where("pos_session_id = ?", sessionID)
+	}
 
-	if err := query.Preload("Journal").Preload("User").Preload("Items").Preload("Payments").Find(&orders).Error; err != nil {
+	if err := query.Preload("Journal").Preload("User").Preload("Items.Taxes").Preload("Payments").Find(&orders).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
 	}
@@ -63,9 +69,11 @@ func GetOrder(c *gin.Context) {
 	if err := config.DB.
 		Preload("Journal").
 		Preload("User").
-		Preload("Items").
+		Preload("Items.Taxes").
 		Preload("Payments").
 		Preload("Tickets").
+		Preload("TaxLines").
+		Preload("Checks.Items").
 		First(&order, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
@@ -76,33 +84,40 @@ func GetOrder(c *gin.Context) {
 
 // CreateOrder godoc
 // @Summary      Crear orden
-// @Description  Crea una nueva orden de venta
+// @Description  Crea una nueva orden de venta en la sesión de caja abierta del terminal (o la indicada en pos_session_id). Las líneas toman el precio y los impuestos del producto y los totales se calculan en el servidor. Pagos, subcuentas, impuestos y almacén no se aceptan aquí
 // @Tags         orders
 // @Accept       json
 // @Produce      json
-// @Param        order  body  models.Order  true  "Datos de la orden"
+// @Param        order  body  map[string]interface{}  true  "journal_id, user_id, table_id, pos_id, pos_session_id, name, order_date, note, items [{product_id, quantity, product_notes, seat}]"
 // @Success      201  {object}  map[string]interface{}  "message y data"
 // @Failure      400  {object}  map[string]string       "error: validación"
+// @Failure      409  {object}  map[string]string       "error: el terminal no tiene sesión de caja abierta"
 // @Router       /orders [post]
 // @Security     Bearer
 func CreateOrder(c *gin.Context) {
-	var order models.Order
-
-	if err := c.ShouldBindJSON(&order); err != nil {
+	var request services.OrderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Estado inicial
-	if order.State == "" {
-		order.State = "draft"
+	if request.JournalID == 0 || request.UserID == 0 || request.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "journal_id, user_id and name are required"})
+		return
 	}
 
-	if err := config.DB.Create(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+	orderService := services.NewOrderService()
+	order, err := orderService.CreateOrder(request)
+	if err != nil {
+		if errors.Is(err, services.ErrNoOpenSession) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	config.DB.Preload("Items.Taxes").Preload("TaxLines").First(order, order.ID)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Order created successfully",
 		"data":    order,
@@ -111,37 +126,43 @@ func CreateOrder(c *gin.Context) {
 
 // UpdateOrder godoc
 // @Summary      Actualizar orden
-// @Description  Actualiza los datos de una orden existente
+// @Description  Actualiza la cabecera (diario, usuario, nombre, fecha, nota) de una orden abierta, agrega las líneas sin id y cambia cantidad, notas y asiento de las líneas con id de la misma orden. Recalcula los totales
 // @Tags         orders
 // @Accept       json
 // @Produce      json
-// @Param        id     path  int           true  "ID de la orden"
-// @Param        order  body  models.Order  true  "Datos actualizados"
+// @Param        id     path  int                     true  "ID de la orden"
+// @Param        order  body  map[string]interface{}  true  "journal_id, user_id, name, order_date, note, items [{id, product_id, quantity, product_notes, seat}]"
 // @Success      200  {object}  map[string]interface{}  "message y data"
-// @Failure      400  {object}  map[string]string       "error: validación"
+// @Failure      400  {object}  map[string]string       "error: validación u orden cerrada"
 // @Failure      404  {object}  map[string]string       "error: Order not found"
 // @Router       /orders/{id} [put]
 // @Security     Bearer
 func UpdateOrder(c *gin.Context) {
-	id := c.Param("id")
-	var order models.Order
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
 
-	if err := config.DB.First(&order, id).Error; err != nil {
+	var order models.Order
+	if err := config.DB.First(&order, orderID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
 
-	var updateData models.Order
-	if err := c.ShouldBindJSON(&updateData); err != nil {
+	var request services.OrderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := config.DB.Model(&order).Updates(updateData).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
+	orderService := services.NewOrderService()
+	if err := orderService.UpdateOrder(order.ID, request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	config.DB.Preload("Items.Taxes").Preload("TaxLines").First(&order, order.ID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Order updated successfully",
 		"data":    order,
@@ -150,31 +171,31 @@ func UpdateOrder(c *gin.Context) {
 
 // ConfirmOrder godoc
 // @Summary      Confirmar orden
-// @Description  Cambia el estado de la orden a confirmed
+// @Description  Cambia el estado de la orden a confirmed y crea un ticket por estación de cocina con las líneas pendientes. Requiere líneas y una sesión de caja abierta. Reconfirmar solo envía a cocina lo nuevo o modificado
 // @Tags         orders
 // @Accept       json
 // @Produce      json
 // @Param        id  path  int  true  "ID de la orden"
 // @Success      200  {object}  map[string]interface{}  "message y data"
 // @Failure      404  {object}  map[string]string       "error: Order not found"
+// @Failure      409  {object}  map[string]interface{}  "error, code, from, to: transición no permitida"
 // @Router       /orders/{id}/confirm [patch]
 // @Security     Bearer
 func ConfirmOrder(c *gin.Context) {
-	id := c.Param("id")
-	var order models.Order
-
-	if err := config.DB.First(&order, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	orderID, ok := orderIDParam(c)
+	if !ok {
 		return
 	}
 
-	order.State = "confirmed"
-
-	if err := config.DB.Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm order"})
+	orderService := services.NewOrderService()
+	order, err := orderService.Confirm(orderID)
+	if err != nil {
+		respondOrderError(c, err)
 		return
 	}
 
+	config.DB.Preload("Items.Taxes").Preload("TaxLines").Preload("Tickets.Items").First(order, order.ID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Order confirmed successfully",
 		"data":    order,
@@ -183,28 +204,27 @@ func ConfirmOrder(c *gin.Context) {
 
 // CancelOrder godoc
 // @Summary      Cancelar orden
-// @Description  Cambia el estado de la orden a cancelled
+// @Description  Cambia el estado de la orden a cancelled. Sus pagos deben estar eliminados o reembolsados. Si la orden estaba cerrada, devuelve al stock lo consumido (incluidos ingredientes de receta)
 // @Tags         orders
 // @Accept       json
 // @Produce      json
-// @Param        id  path  int  true  "ID de la orden"
+// @Param        id     path   int   true   "ID de la orden"
+// @Param        waste  query  bool  false  "Dar de baja lo devuelto como merma en lugar de reponerlo"
 // @Success      200  {object}  map[string]interface{}  "message y data"
 // @Failure      404  {object}  map[string]string       "error: Order not found"
+// @Failure      409  {object}  map[string]interface{}  "error, code, from, to: transición no permitida"
 // @Router       /orders/{id}/cancel [patch]
 // @Security     Bearer
 func CancelOrder(c *gin.Context) {
-	id := c.Param("id")
-	var order models.Order
-
-	if err := config.DB.First(&order, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	orderID, ok := orderIDParam(c)
+	if !ok {
 		return
 	}
 
-	order.State = "cancelled"
-
-	if err := config.DB.Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel order"})
+	orderService := services.NewOrderService()
+	order, err := orderService.Cancel(orderID, c.Query("waste") == "true", currentUserID(c))
+	if err != nil {
+		respondOrderError(c, err)
 		return
 	}
 
@@ -216,54 +236,64 @@ func CancelOrder(c *gin.Context) {
 
 // CompleteOrder godoc
 // @Summary      Completar orden
-// @Description  Cambia el estado de la orden a done y registra salida en inventario
+// @Description  Cambia el estado de la orden a done y registra salida en inventario. Requiere pagos que cubran el total y una sesión de caja abierta
 // @Tags         orders
 // @Accept       json
 // @Produce      json
 // @Param        id  path  int  true  "ID de la orden"
 // @Success      200  {object}  map[string]interface{}  "message y data"
 // @Failure      404  {object}  map[string]string       "error: Order not found"
+// @Failure      409  {object}  map[string]interface{}  "error, code, from, to: transición no permitida o error de inventario"
 // @Router       /orders/{id}/complete [patch]
 // @Security     Bearer
 func CompleteOrder(c *gin.Context) {
-	id := c.Param("id")
-	var order models.Order
-
-	// Cargar orden con items
-	if err := config.DB.Preload("Items").First(&order, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	orderID, ok := orderIDParam(c)
+	if !ok {
 		return
 	}
 
-	// Validar que no esté ya completada
-	if order.State == "done" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Order already completed"})
+	orderService := services.NewOrderService()
+	order, err := orderService.Complete(orderID)
+	if err != nil {
+		respondOrderError(c, err)
 		return
 	}
 
-	// Registrar salida en inventario (Kardex)
-	inventoryService := services.NewInventoryService()
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order completed successfully and inventory updated",
+		"data":    order,
+	})
+}
 
-	// TODO: Obtener warehouse_id del contexto o configuración
-	// Por ahora usamos warehouse 4 (el que creamos en pruebas)
-	warehouseID := uint(4)
+// orderIDParam lee el ID de la orden de la ruta
+func orderIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
 
-	if err := inventoryService.RegisterSale(order.ID, order.Items, warehouseID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Inventory error: %s", err.Error())})
+// respondOrderError responde un error del ciclo de vida de la orden
+func respondOrderError(c *gin.Context, err error) {
+	var transitionErr *services.TransitionError
+	if errors.As(err, &transitionErr) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": transitionErr.Message,
+			"code":  transitionErr.Code,
+			"from":  transitionErr.From,
+			"to":    transitionErr.To,
+		})
 		return
 	}
 
-	// Actualizar estado de la orden
-	order.State = "done"
-	if err := config.DB.Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete order"})
+	if strings.HasSuffix(err.Error(), "not found") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Order completed successfully and inventory updated",
-		"data":    order,
-	})
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 }
 
 // GetOrdersByTable godoc
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// orderItemParams lee los IDs de orden y línea de la ruta
+func orderItemParams(c *gin.Context) (uint, uint, bool) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return 0, 0, false
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("item_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return 0, 0, false
+	}
+
+	return uint(orderID), uint(itemID), true
+}
+
+// orderWithItems recarga la orden con sus líneas y totales recalculados
+func orderWithItems(orderID uint) models.Order {
+	var order models.Order
+	config.DB.Preload("Items.Taxes").Preload("TaxLines").First(&order, orderID)
+	return order
+}
+
+// AddOrderItem godoc
+// @Summary      Agregar línea a la orden
+// @Description  Agrega un producto a una orden en borrador o confirmada. El precio se toma del producto y los impuestos por defecto del template
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id    path  int                     true  "ID de la orden"
+// @Param        item  body  map[string]interface{}  true  "product_id, quantity, product_notes, seat, tax_ids (opcional)"
+// @Success      201  {object}  map[string]interface{}  "message, item y order"
+// @Failure      400  {object}  map[string]string       "error: validación u orden cerrada"
+// @Router       /orders/{id}/items [post]
+// @Security     Bearer
+func AddOrderItem(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var request struct {
+		ProductID    uint    `json:"product_id" binding:"required"`
+		Quantity     float64 `json:"quantity" binding:"required,gt=0"`
+		ProductNotes string  `json:"product_notes"`
+		Seat         int     `json:"seat" binding:"min=0"` // 0 = compartido
+		TaxIDs       []uint  `json:"tax_ids"`              // Sobrescribe los impuestos del producto
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item := models.OrderItem{
+		ProductID:    request.ProductID,
+		Quantity:     request.Quantity,
+		ProductNotes: request.ProductNotes,
+		Seat:         request.Seat,
+	}
+	if request.TaxIDs != nil {
+		item.Taxes = []models.Tax{}
+		if len(request.TaxIDs) > 0 {
+			if err := config.DB.Where("id IN ?", request.TaxIDs).Find(&item.Taxes).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid taxes"})
+				return
+			}
+		}
+	}
+
+	orderService := services.NewOrderService()
+	if err := orderService.AddItem(uint(orderID), &item); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Order item added successfully",
+		"item":    item,
+		"order":   orderWithItems(uint(orderID)),
+	})
+}
+
+// UpdateOrderItem godoc
+// @Summary      Actualizar línea de la orden
+// @Description  Cambia la cantidad, las notas o el asiento de una línea de una orden en borrador o confirmada
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id       path  int                     true  "ID de la orden"
+// @Param        item_id  path  int                     true  "ID de la línea"
+// @Param        item     body  map[string]interface{}  true  "quantity, product_notes y/o seat"
+// @Success      200  {object}  map[string]interface{}  "message, item y order"
+// @Failure      400  {object}  map[string]string       "error: validación u orden cerrada"
+// @Router       /orders/{id}/items/{item_id} [patch]
+// @Security     Bearer
+func UpdateOrderItem(c *gin.Context) {
+	orderID, itemID, ok := orderItemParams(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Quantity     *float64 `json:"quantity" binding:"omitempty,gt=0"`
+		ProductNotes *string  `json:"product_notes"`
+		Seat         *int     `json:"seat" binding:"omitempty,min=0"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orderService := services.NewOrderService()
+	item, err := orderService.UpdateItem(orderID, itemID, services.OrderItemUpdate{
+		Quantity:     request.Quantity,
+		ProductNotes: request.ProductNotes,
+		Seat:         request.Seat,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order item updated successfully",
+		"item":    item,
+		"order":   orderWithItems(orderID),
+	})
+}
+
+// VoidOrderItem godoc
+// @Summary      Anular línea de la orden
+// @Description  Anula una línea con su motivo; se conserva para auditoría pero deja de sumar al total
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id       path  int                     true  "ID de la orden"
+// @Param        item_id  path  int                     true  "ID de la línea"
+// @Param        void     body  map[string]interface{}  true  "reason"
+// @Success      200  {object}  map[string]interface{}  "message, item y order"
+// @Failure      400  {object}  map[string]string       "error: validación u orden cerrada"
+// @Router       /orders/{id}/items/{item_id}/void [patch]
+// @Security     Bearer
+func VoidOrderItem(c *gin.Context) {
+	orderID, itemID, ok := orderItemParams(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Reason string `json:"reason" binding:"required,max=255"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orderService := services.NewOrderService()
+	item, err := orderService.VoidItem(orderID, itemID, request.Reason, currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order item voided successfully",
+		"item":    item,
+		"order":   orderWithItems(orderID),
+	})
+}
+
+// SplitOrderItem godoc
+// @Summary      Dividir línea de la orden
+// @Description  Separa parte de la cantidad de una línea en una línea nueva con el mismo producto, precio e impuestos
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id       path  int                     true  "ID de la orden"
+// @Param        item_id  path  int                     true  "ID de la línea"
+// @Param        split    body  map[string]interface{}  true  "quantity: cantidad a separar"
+// @Success      201  {object}  map[string]interface{}  "message, item, new_item y order"
+// @Failure      400  {object}  map[string]string       "error: validación u orden cerrada"
+// @Router       /orders/{id}/items/{item_id}/split [post]
+// @Security     Bearer
+func SplitOrderItem(c *gin.Context) {
+	orderID, itemID, ok := orderItemParams(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Quantity float64 `json:"quantity" binding:"required,gt=0"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orderService := services.NewOrderService()
+	item, newItem, err := orderService.SplitItem(orderID, itemID, request.Quantity)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Order item split successfully",
+		"item":     item,
+		"new_item": newItem,
+		"order":    orderWithItems(orderID),
+	})
+}
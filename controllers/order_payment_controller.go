@@ -3,8 +3,10 @@ package controllers
 import (
 	"b-resto/config"
 	"b-resto/models"
-	"fmt"
+	"b-resto/services"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -36,35 +38,36 @@ func GetOrderPayments(c *gin.Context) {
 
 // CreateOrderPayment godoc
 // @Summary      Crear pago de orden
-// @Description  Registra un nuevo pago para una orden
+// @Description  Registra un nuevo pago para una orden en la sesión de caja que cobra (pos_session_id o, por defecto, la sesión abierta del terminal de la orden). Para cobrar en otra moneda se envía currency y tendered_amount: el importe se convierte con el tipo de cambio del día y, en efectivo, lo que excede el saldo se devuelve como vuelto (change_amount) en moneda base
 // @Tags         order-payments
 // @Accept       json
 // @Produce      json
 // @Param        order_id  path  int                  true  "ID de la orden"
 // @Param        payment   body  models.OrderPayment  true  "Datos del pago"
 // @Success      201  {object}  map[string]interface{}  "message y data"
-// @Failure      400  {object}  map[string]string       "error: validación"
+// @Failure      400  {object}  map[string]string       "error: validación, orden dividida, sin sesión abierta o sin tipo de cambio"
+// @Failure      409  {object}  map[string]string       "error: la orden ya está cerrada o cancelada"
 // @Router       /orders/{order_id}/payments [post]
 // @Security     Bearer
 func CreateOrderPayment(c *gin.Context) {
-	orderID := c.Param("id")
-	var payment models.OrderPayment
-
-	if err := c.ShouldBindJSON(&payment); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	orderID, ok := orderIDParam(c)
+	if !ok {
 		return
 	}
 
-	// Asignar el OrderID del path
-	var orderIDUint uint
-	if _, err := fmt.Sscanf(orderID, "%d", &orderIDUint); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+	var payment models.OrderPayment
+	if err := c.ShouldBindJSON(&payment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	payment.OrderID = orderIDUint
 
-	if err := config.DB.Create(&payment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment"})
+	orderService := services.NewOrderService()
+	if err := orderService.AddPayment(orderID, &payment); err != nil {
+		if errors.Is(err, services.ErrPaymentOrderState) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -76,27 +79,42 @@ func CreateOrderPayment(c *gin.Context) {
 
 // DeleteOrderPayment godoc
 // @Summary      Eliminar pago de orden
-// @Description  Elimina un pago de una orden
+// @Description  Elimina un pago de una orden en borrador o confirmada. No se eliminan pagos de devoluciones, de subcuentas cerradas ni de sesiones de caja cerradas o en cierre
 // @Tags         order-payments
 // @Accept       json
 // @Produce      json
 // @Param        order_id    path  int  true  "ID de la orden"
 // @Param        payment_id  path  int  true  "ID del pago"
 // @Success      200  {object}  map[string]string  "message: Payment deleted successfully"
+// @Failure      400  {object}  map[string]string  "error: mensaje"
 // @Failure      404  {object}  map[string]string  "error: Payment not found"
+// @Failure      409  {object}  map[string]string  "error: estado de la orden, devolución, subcuenta o sesión cerrada"
 // @Router       /orders/{order_id}/payments/{payment_id} [delete]
 // @Security     Bearer
 func DeleteOrderPayment(c *gin.Context) {
-	paymentID := c.Param("payment_id")
-	var payment models.OrderPayment
-
-	if err := config.DB.First(&payment, paymentID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+	paymentID, err := strconv.ParseUint(c.Param("payment_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
 		return
 	}
 
-	if err := config.DB.Delete(&payment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete payment"})
+	orderService := services.NewOrderService()
+	if err := orderService.DeletePayment(orderID, uint(paymentID)); err != nil {
+		switch {
+		case errors.Is(err, services.ErrPaymentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		case errors.Is(err, services.ErrPaymentOrderState),
+			errors.Is(err, services.ErrRefundPayment),
+			errors.Is(err, services.ErrPaymentCheckClosed),
+			errors.Is(err, services.ErrPaymentSessionClosed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"b-resto/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MoveOrder godoc
+// @Summary      Cambiar de mesa
+// @Description  Pasa una orden abierta a otra mesa libre (si la mesa tiene una orden abierta, usar merge)
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id    path  int                     true  "ID de la orden"
+// @Param        move  body  map[string]interface{}  true  "table_id: mesa destino"
+// @Success      200  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: mesa ocupada u orden cerrada"
+// @Router       /orders/{id}/move [patch]
+// @Security     Bearer
+func MoveOrder(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		TableID uint `json:"table_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orderService := services.NewOrderService()
+	if _, err := orderService.MoveOrder(orderID, request.TableID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order moved successfully",
+		"data":    orderWithItems(orderID),
+	})
+}
+
+// MergeOrder godoc
+// @Summary      Unir órdenes
+// @Description  Pasa las líneas, pagos y tickets de cocina de otra orden abierta a esta; la orden origen queda cancelada y su mesa libre
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id     path  int                     true  "ID de la orden destino"
+// @Param        merge  body  map[string]interface{}  true  "source_order_id: orden que se une"
+// @Success      200  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: órdenes cerradas o divididas"
+// @Router       /orders/{id}/merge [post]
+// @Security     Bearer
+func MergeOrder(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		SourceOrderID uint `json:"source_order_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orderService := services.NewOrderService()
+	if _, err := orderService.MergeOrders(orderID, request.SourceOrderID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Orders merged successfully",
+		"data":    orderWithItems(orderID),
+	})
+}
+
+// TransferOrderItems godoc
+// @Summary      Pasar líneas a otra mesa
+// @Description  Pasa líneas (o parte de su cantidad) a otra orden abierta o a la orden abierta de una mesa; si la mesa está libre se abre una orden nueva
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id        path  int                     true  "ID de la orden origen"
+// @Param        transfer  body  map[string]interface{}  true  "target_order_id o target_table_id, items [{order_item_id, quantity}]"
+// @Success      200  {object}  map[string]interface{}  "message, source y target"
+// @Failure      400  {object}  map[string]string       "error: validación u órdenes cerradas"
+// @Router       /orders/{id}/items/transfer [post]
+// @Security     Bearer
+func TransferOrderItems(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		TargetOrderID *uint                   `json:"target_order_id"`
+		TargetTableID *uint                   `json:"target_table_id"`
+		Items         []services.ItemTransfer `json:"items" binding:"required,min=1,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orderService := services.NewOrderService()
+	_, target, err := orderService.TransferItems(orderID, request.TargetOrderID, request.TargetTableID, request.Items)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order items transferred successfully",
+		"source":  orderWithItems(orderID),
+		"target":  orderWithItems(target.ID),
+	})
+}
@@ -3,21 +3,23 @@ package controllers
 import (
 	"b-resto/config"
 	"b-resto/models"
+	"b-resto/services"
 	"net/http"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 // GetPOSSessions godoc
 // @Summary      Listar sesiones POS
-// @Description  Obtiene lista de todas las sesiones de caja
+// @Description  Obtiene lista de todas las sesiones de caja. Con include=orders trae sus órdenes y con include=totals los totales de órdenes y pagos por medio (en cierre ciego los pagos solo los ve un supervisor hasta el cierre)
 // @Tags         pos-sessions
 // @Accept       json
 // @Produce      json
-// @Param        pos_id  query  int     false  "Filtrar por terminal POS"
-// @Param        state   query  string  false  "Filtrar por estado"  Enums(opened, closed)
-// @Success      200  {object}  map[string]interface{}  "data: array de pos sessions"
+// @Param        pos_id   query  int     false  "Filtrar por terminal POS"
+// @Param        status   query  string  false  "Filtrar por estado"  Enums(open, closed)
+// @Param        include  query  string  false  "Relaciones a incluir, separadas por coma (orders, totals)"
+// @Success      200  {object}  map[string]interface{}  "data: array de pos sessions, totals: totales por sesión (con include=totals)"
 // @Failure      500  {object}  map[string]string       "error: mensaje"
 // @Router       /pos-sessions [get]
 // @Security     Bearer
@@ -28,26 +30,52 @@ func GetPOSSessions(c *gin.Context) {
 	if posID := c.Query("pos_id"); posID != "" {
 		query = query.Where("pos_id = ?", posID)
 	}
-	if state := c.Query("state"); state != "" {
-		query = query.Where("state = ?", state)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
 	}
 
-	if err := query.Preload("POS").Preload("User").Find(&sessions).Error; err != nil {
+	includes := sessionIncludes(c)
+	query = query.Preload("POS").Preload("OpenedByUser").Preload("ClosedByUser")
+	if includes["orders"] {
+		query = query.Preload("Orders")
+	}
+
+	if err := query.Order("opened_at desc").Find(&sessions).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch POS sessions"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": sessions})
+	response := gin.H{"data": sessions}
+	if includes["totals"] {
+		sessionIDs := make([]uint, len(sessions))
+		for i, session := range sessions {
+			sessionIDs[i] = session.ID
+		}
+		totals, err := services.NewPOSSessionService().Totals(sessionIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for i := range totals {
+			if blindFor(c, &sessions[i]) {
+				hidePayments(&totals[i])
+			}
+		}
+		response["totals"] = totals
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // GetPOSSession godoc
 // @Summary      Obtener sesión POS
-// @Description  Obtiene una sesión de caja por ID
+// @Description  Obtiene una sesión de caja por ID. Con include=orders trae sus órdenes y con include=totals los totales de órdenes y pagos por medio (en cierre ciego los pagos solo los ve un supervisor hasta el cierre)
 // @Tags         pos-sessions
 // @Accept       json
 // @Produce      json
-// @Param        id  path  int  true  "ID de la sesión"
-// @Success      200  {object}  map[string]interface{}  "data: pos session"
+// @Param        id       path   int     true   "ID de la sesión"
+// @Param        include  query  string  false  "Relaciones a incluir, separadas por coma (orders, totals)"
+// @Success      200  {object}  map[string]interface{}  "data: pos session, totals (con include=totals)"
 // @Failure      404  {object}  map[string]string       "error: POS session not found"
 // @Router       /pos-sessions/{id} [get]
 // @Security     Bearer
@@ -55,49 +83,78 @@ func GetPOSSession(c *gin.Context) {
 	id := c.Param("id")
 	var session models.POSSession
 
-	if err := config.DB.Preload("POS").Preload("User").First(&session, id).Error; err != nil {
+	includes := sessionIncludes(c)
+	query := config.DB.Preload("POS").Preload("OpenedByUser").Preload("ClosedByUser")
+	if includes["orders"] {
+		query = query.Preload("Orders")
+	}
+
+	if err := query.First(&session, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "POS session not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": session})
+	response := gin.H{"data": session}
+	if includes["totals"] {
+		totals, err := services.NewPOSSessionService().Totals([]uint{session.ID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if blindFor(c, &session) {
+			hidePayments(&totals[0])
+		}
+		response["totals"] = totals[0]
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // OpenPOSSession godoc
 // @Summary      Abrir sesión de caja
-// @Description  Abre una nueva sesión de caja en un terminal POS
+// @Description  Abre una nueva sesión de caja en un terminal POS. El fondo inicial puede enviarse como total (opening_balance) o contado por denominaciones; con denominaciones el total lo calcula el servidor
 // @Tags         pos-sessions
 // @Accept       json
 // @Produce      json
-// @Param        session  body  models.POSSession  true  "Datos de la sesión (pos_id, user_id, opening_cash)"
+// @Param        session  body  map[string]interface{}  true  "pos_id, opening_balance o denominations [{currency, type, value, quantity}], notes"
 // @Success      201  {object}  map[string]interface{}  "message y data"
 // @Failure      400  {object}  map[string]string       "error: validación o sesión ya abierta"
+// @Failure      401  {object}  map[string]string       "error: sin usuario autenticado"
 // @Router       /pos-sessions/open [post]
 // @Security     Bearer
 func OpenPOSSession(c *gin.Context) {
-	var session models.POSSession
+	var request struct {
+		POSID          uint                    `json:"pos_id" binding:"required"`
+		OpeningBalance *float64                `json:"opening_balance" binding:"omitempty,gte=0"`
+		Denominations  []services.Denomination `json:"denominations" binding:"omitempty,dive"`
+		Notes          string                  `json:"notes"`
+	}
 
-	if err := c.ShouldBindJSON(&session); err != nil {
+	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Verificar que no haya una sesión abierta en este POS
-	var existingSession models.POSSession
-	if err := config.DB.Where("pos_id = ? AND state = ?", session.POSID, "opened").First(&existingSession).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "There is already an open session on this POS terminal"})
+	// La sesión se abre a nombre de quien la abre, no de quien indique el cuerpo
+	userID := currentUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required to open a POS session"})
 		return
 	}
 
-	// Establecer valores iniciales
-	now := time.Now()
-	session.Status = "opened"
-	session.OpenedAt = now
-	diff := 0.0
-	session.Difference = &diff
+	session := models.POSSession{
+		POSID:    request.POSID,
+		OpenedBy: *userID,
+		Notes:    request.Notes,
+	}
+	if request.OpeningBalance != nil {
+		session.OpeningBalance = *request.OpeningBalance
+	}
 
-	if err := config.DB.Create(&session).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open POS session"})
+	count := services.CashCountInput{Amount: request.OpeningBalance, Denominations: request.Denominations}
+	sessionService := services.NewPOSSessionService()
+	if err := sessionService.OpenSession(&session, count); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -109,58 +166,45 @@ func OpenPOSSession(c *gin.Context) {
 
 // ClosePOSSession godoc
 // @Summary      Cerrar sesión de caja
-// @Description  Cierra una sesión de caja abierta
+// @Description  Registra el conteo de cierre (total o por denominaciones) y lo compara con el esperado: fondo inicial + cobros en efectivo + ingresos - egresos. Con denominaciones en varias monedas, cada cajón se concilia por separado (cash.drawers). Si alguna diferencia supera el umbral del terminal, la sesión queda en closing hasta el reconteo de un supervisor; si no, se cierra y se genera el reporte Z. En terminales con cierre ciego el cajero no ve el esperado hasta que la sesión se cierra. No se puede cerrar con órdenes abiertas en la sesión.
 // @Tags         pos-sessions
 // @Accept       json
 // @Produce      json
-// @Param        id       path  int                      true  "ID de la sesión"
-// @Param        request  body  map[string]interface{}   true  "closing_cash: efectivo al cerrar"
-// @Success      200  {object}  map[string]interface{}  "message y data"
-// @Failure      400  {object}  map[string]string       "error: validación o sesión ya cerrada"
-// @Failure      404  {object}  map[string]string       "error: POS session not found"
+// @Param        id       path  int                     true  "ID de la sesión"
+// @Param        request  body  map[string]interface{}  true  "closing_cash o denominations [{currency, type, value, quantity}], notes"
+// @Success      200  {object}  map[string]interface{}  "message, data, count, recount_required, cash y z_report"
+// @Failure      400  {object}  map[string]string       "error: validación, sin usuario autenticado, sesión ya cerrada u órdenes abiertas"
 // @Router       /pos-sessions/{id}/close [patch]
 // @Security     Bearer
 func ClosePOSSession(c *gin.Context) {
-	id := c.Param("id")
-	var session models.POSSession
-
-	if err := config.DB.First(&session, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "POS session not found"})
-		return
-	}
-
-	if session.Status == "closed" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session is already closed"})
+	sessionID, ok := sessionIDParam(c)
+	if !ok {
 		return
 	}
 
 	var request struct {
-		ClosingCash float64 `json:"closing_cash" binding:"required"`
+		ClosingCash   *float64                `json:"closing_cash" binding:"omitempty,gte=0"`
+		Denominations []services.Denomination `json:"denominations" binding:"omitempty,dive"`
+		Notes         string                  `json:"notes"`
 	}
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	now := time.Now()
-	session.Status = "closed"
-	session.ClosedAt = &now
-	session.ClosingBalance = &request.ClosingCash
-
-	// Calcular diferencia
-	expectedCash := session.OpeningBalance
-	diff := request.ClosingCash - expectedCash
-	session.Difference = &diff
-
-	if err := config.DB.Save(&session).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close POS session"})
+	count := services.CashCountInput{
+		Amount:        request.ClosingCash,
+		Denominations: request.Denominations,
+		Notes:         request.Notes,
+	}
+	sessionService := services.NewPOSSessionService()
+	result, err := sessionService.CloseSession(sessionID, count, currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "POS session closed successfully",
-		"data":    session,
-	})
+	respondCloseResult(c, result, "POS session closed successfully")
 }
 
 // GetActivePOSSessions godoc
@@ -176,8 +220,8 @@ func ClosePOSSession(c *gin.Context) {
 func GetActivePOSSessions(c *gin.Context) {
 	var sessions []models.POSSession
 
-	if err := config.DB.Where("state = ?", "opened").
-		Preload("POS").Preload("User").
+	if err := config.DB.Where("status = ?", services.SessionStatusOpen).
+		Preload("POS").Preload("OpenedByUser").
 		Find(&sessions).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch active sessions"})
 		return
@@ -201,7 +245,7 @@ func GetSessionMovements(c *gin.Context) {
 	sessionID := c.Param("id")
 	var movements []models.CashMovement
 
-	if err := config.DB.Where("pos_session_id = ?", sessionID).
+	if err := config.DB.Where("cash_register_id = ?", sessionID).
 		Find(&movements).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch movements"})
 		return
@@ -209,3 +253,21 @@ func GetSessionMovements(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"data": movements})
 }
+
+// hidePayments quita los cobros por medio de los totales: con ellos el cajero deduciría el efectivo esperado
+func hidePayments(totals *services.SessionTotals) {
+	totals.PaymentTotal = 0
+	totals.Payments = nil
+	totals.Blind = true
+}
+
+// sessionIncludes lee el parámetro include (ej: include=orders,totals)
+func sessionIncludes(c *gin.Context) map[string]bool {
+	includes := map[string]bool{}
+	for _, include := range strings.Split(c.Query("include"), ",") {
+		if include = strings.TrimSpace(include); include != "" {
+			includes[include] = true
+		}
+	}
+	return includes
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPrintJobs godoc
+// @Summary      Listar trabajos de impresión
+// @Description  Obtiene la cola de impresión, opcionalmente filtrada por estado, tipo u orden
+// @Tags         print-jobs
+// @Accept       json
+// @Produce      json
+// @Param        status    query  string  false  "Filtrar por estado"  Enums(pending, printed, failed)
+// @Param        type      query  string  false  "Filtrar por tipo"    Enums(kitchen_ticket, receipt)
+// @Param        order_id  query  int     false  "Filtrar por orden"
+// @Success      200  {object}  map[string]interface{}  "data: array de print jobs"
+// @Failure      500  {object}  map[string]string       "error: mensaje"
+// @Router       /print-jobs [get]
+// @Security     Bearer
+func GetPrintJobs(c *gin.Context) {
+	var jobs []models.PrintJob
+
+	query := config.DB
+	if status := c.Query("status"); status != "" {
+		query = query.Where("state = ?", status)
+	}
+	if jobType := c.Query("type"); jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+	if orderID := c.Query("order_id"); orderID != "" {
+		query = query.Where("order_id = ?", orderID)
+	}
+
+	if err := query.Order("id desc").Limit(200).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch print jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": jobs})
+}
+
+// RetryPrintJob godoc
+// @Summary      Reintentar impresión
+// @Description  Vuelve a poner en cola un trabajo fallido o pendiente (reinicia los intentos)
+// @Tags         print-jobs
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID del trabajo"
+// @Success      200  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: trabajo ya impreso o inexistente"
+// @Router       /print-jobs/{id}/retry [post]
+// @Security     Bearer
+func RetryPrintJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid print job ID"})
+		return
+	}
+
+	job, err := services.NewPrintService().RetryJob(uint(id))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Print job queued",
+		"data":    job,
+	})
+}
+
+// ReprintKitchenTicket godoc
+// @Summary      Reimprimir ticket de cocina
+// @Description  Encola una copia del ticket en la impresora de su estación, marcada como reimpresión
+// @Tags         kitchen-tickets
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID del ticket"
+// @Success      202  {object}  map[string]interface{}  "message y data: print job"
+// @Failure      400  {object}  map[string]string       "error: ticket inexistente"
+// @Failure      422  {object}  map[string]string       "error: la estación no tiene impresora"
+// @Router       /kitchen-tickets/{id}/reprint [post]
+// @Security     Bearer
+func ReprintKitchenTicket(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid kitchen ticket ID"})
+		return
+	}
+
+	job, err := services.NewPrintService().ReprintKitchenTicket(uint(id), currentUserID(c))
+	if err != nil {
+		respondPrintError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Kitchen ticket queued for printing",
+		"data":    job,
+	})
+}
+
+// PrintOrderReceipt godoc
+// @Summary      Imprimir comprobante
+// @Description  Encola el comprobante de una orden cerrada en la impresora de su terminal; desde la segunda vez sale como copia
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID de la orden"
+// @Success      202  {object}  map[string]interface{}  "message y data: print job"
+// @Failure      400  {object}  map[string]string       "error: orden no cerrada o inexistente"
+// @Failure      422  {object}  map[string]string       "error: el terminal no tiene impresora"
+// @Router       /orders/{id}/receipt [post]
+// @Security     Bearer
+func PrintOrderReceipt(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	job, err := services.NewPrintService().PrintReceipt(orderID, currentUserID(c))
+	if err != nil {
+		respondPrintError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Receipt queued for printing",
+		"data":    job,
+	})
+}
+
+// respondPrintError responde 422 si no hay impresora configurada y 400 en los demás casos
+func respondPrintError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrNoPrinter) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -70,6 +70,7 @@ func GetProductTemplate(c *gin.Context) {
 		Preload("Unit").
 		Preload("KitchenStation").
 		Preload("Variants").
+		Preload("Taxes").
 		First(&template, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product template not found"})
 		return
@@ -141,11 +142,19 @@ func UpdateProductTemplate(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Model(&template).Updates(updateData).Error; err != nil {
+	if err := config.DB.Model(&template).Omit("Taxes").Updates(updateData).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product template"})
 		return
 	}
 
+	// Impuestos por defecto: si se envían reemplazan a los actuales
+	if updateData.Taxes != nil {
+		if err := config.DB.Model(&template).Association("Taxes").Replace(updateData.Taxes); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product template taxes"})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Product template updated successfully",
 		"data":    template,
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRefunds godoc
+// @Summary      Listar devoluciones
+// @Description  Obtiene las notas de crédito, opcionalmente filtradas por orden
+// @Tags         refunds
+// @Accept       json
+// @Produce      json
+// @Param        order_id  query  int  false  "ID de la orden original"
+// @Success      200  {object}  map[string]interface{}  "data: array de refunds"
+// @Failure      500  {object}  map[string]string       "error: mensaje"
+// @Router       /refunds [get]
+// @Security     Bearer
+func GetRefunds(c *gin.Context) {
+	var refunds []models.Refund
+	query := config.DB.Preload("PaymentMethod")
+
+	if orderID := c.Query("order_id"); orderID != "" {
+		query = query.Where("order_id = ?", orderID)
+	}
+
+	if err := query.Order("id desc").Find(&refunds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch refunds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": refunds})
+}
+
+// GetRefund godoc
+// @Summary      Obtener devolución
+// @Description  Obtiene una nota de crédito con sus líneas, impuestos y pagos
+// @Tags         refunds
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID de la devolución"
+// @Success      200  {object}  map[string]interface{}  "data: refund"
+// @Failure      404  {object}  map[string]string       "error: Refund not found"
+// @Router       /refunds/{id} [get]
+// @Security     Bearer
+func GetRefund(c *gin.Context) {
+	id := c.Param("id")
+	var refund models.Refund
+
+	if err := config.DB.
+		Preload("Items").
+		Preload("TaxLines").
+		Preload("Payments").
+		Preload("PaymentMethod").
+		First(&refund, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Refund not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": refund})
+}
+
+// CreateOrderRefund godoc
+// @Summary      Devolver una orden
+// @Description  Emite una nota de crédito para una orden cerrada, numerada en un diario de tipo refund (el indicado o el de la compañía). Sin líneas devuelve todo lo pendiente; con líneas, las cantidades indicadas. Genera un pago negativo, una salida de caja si el medio es efectivo y devuelve el inventario (o lo da de baja con waste)
+// @Tags         refunds
+// @Accept       json
+// @Produce      json
+// @Param        id      path  int                     true  "ID de la orden"
+// @Param        refund  body  map[string]interface{}  true  "payment_method_id, journal_id (opcional, diario de tipo refund), reason, waste, lines [{order_item_id, quantity, waste}]"
+// @Success      201  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: validación u orden no cerrada"
+// @Router       /orders/{id}/refunds [post]
+// @Security     Bearer
+func CreateOrderRefund(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		PaymentMethodID uint                  `json:"payment_method_id" binding:"required"`
+		JournalID       *uint                 `json:"journal_id"`
+		Reason          string                `json:"reason"`
+		Waste           bool                  `json:"waste"`
+		Lines           []services.RefundLine `json:"lines" binding:"dive"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orderService := services.NewOrderService()
+	refund, err := orderService.CreateRefund(services.RefundRequest{
+		OrderID:         orderID,
+		JournalID:       request.JournalID,
+		PaymentMethodID: request.PaymentMethodID,
+		Reason:          request.Reason,
+		Lines:           request.Lines,
+		Waste:           request.Waste,
+		CreatedBy:       currentUserID(c),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Refund created successfully",
+		"data":    refund,
+	})
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetReorderRules godoc
+// @Summary      Listar reglas de reabastecimiento
+// @Description  Obtiene las reglas de stock mínimo/máximo por producto y almacén
+// @Tags         reorder-rules
+// @Accept       json
+// @Produce      json
+// @Param        warehouse_id  query  int     false  "Filtrar por almacén"
+// @Param        product_id    query  int     false  "Filtrar por producto"
+// @Param        is_active     query  string  false  "Filtrar por estado activo"  Enums(true, false)
+// @Success      200  {object}  map[string]interface{}  "data: array de reorder rules"
+// @Failure      500  {object}  map[string]string       "error: mensaje"
+// @Router       /reorder-rules [get]
+// @Security     Bearer
+func GetReorderRules(c *gin.Context) {
+	var rules []models.ReorderRule
+
+	query := config.DB
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+	}
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+	if isActive := c.Query("is_active"); isActive != "" {
+		query = query.Where("is_active = ?", isActive == "true")
+	}
+
+	if err := query.Preload("Product").Preload("Warehouse").Preload("Partner").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reorder rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rules})
+}
+
+// GetReorderRule godoc
+// @Summary      Obtener regla de reabastecimiento
+// @Description  Obtiene una regla de reabastecimiento por ID
+// @Tags         reorder-rules
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID de la regla"
+// @Success      200  {object}  map[string]interface{}  "data: reorder rule"
+// @Failure      404  {object}  map[string]string       "error: Reorder rule not found"
+// @Router       /reorder-rules/{id} [get]
+// @Security     Bearer
+func GetReorderRule(c *gin.Context) {
+	id := c.Param("id")
+	var rule models.ReorderRule
+
+	if err := config.DB.Preload("Product").Preload("Warehouse").Preload("Partner").First(&rule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reorder rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rule})
+}
+
+// CreateReorderRule godoc
+// @Summary      Crear regla de reabastecimiento
+// @Description  Crea una regla de stock mínimo/máximo para un producto en un almacén
+// @Tags         reorder-rules
+// @Accept       json
+// @Produce      json
+// @Param        rule  body  models.ReorderRule  true  "Datos de la regla"
+// @Success      201  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: validación"
+// @Failure      409  {object}  map[string]string       "error: ya existe una regla para el producto y almacén"
+// @Router       /reorder-rules [post]
+// @Security     Bearer
+func CreateReorderRule(c *gin.Context) {
+	var rule models.ReorderRule
+
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var existing models.ReorderRule
+	if err := config.DB.Where("product_id = ? AND warehouse_id = ?", rule.ProductID, rule.WarehouseID).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "A reorder rule already exists for this product and warehouse"})
+		return
+	}
+
+	if err := config.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reorder rule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Reorder rule created successfully",
+		"data":    rule,
+	})
+}
+
+// UpdateReorderRule godoc
+// @Summary      Actualizar regla de reabastecimiento
+// @Description  Actualiza los datos de una regla de reabastecimiento
+// @Tags         reorder-rules
+// @Accept       json
+// @Produce      json
+// @Param        id    path  int                 true  "ID de la regla"
+// @Param        rule  body  models.ReorderRule  true  "Datos actualizados"
+// @Success      200  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: validación"
+// @Failure      404  {object}  map[string]string       "error: Reorder rule not found"
+// @Router       /reorder-rules/{id} [put]
+// @Security     Bearer
+func UpdateReorderRule(c *gin.Context) {
+	id := c.Param("id")
+	var rule models.ReorderRule
+
+	if err := config.DB.First(&rule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reorder rule not found"})
+		return
+	}
+
+	var updateData models.ReorderRule
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Model(&rule).Updates(updateData).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reorder rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Reorder rule updated successfully",
+		"data":    rule,
+	})
+}
+
+// DeleteReorderRule godoc
+// @Summary      Eliminar regla de reabastecimiento
+// @Description  Elimina una regla de reabastecimiento (soft delete)
+// @Tags         reorder-rules
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID de la regla"
+// @Success      200  {object}  map[string]string  "message: Reorder rule deleted successfully"
+// @Failure      404  {object}  map[string]string  "error: Reorder rule not found"
+// @Router       /reorder-rules/{id} [delete]
+// @Security     Bearer
+func DeleteReorderRule(c *gin.Context) {
+	id := c.Param("id")
+	var rule models.ReorderRule
+
+	if err := config.DB.First(&rule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reorder rule not found"})
+		return
+	}
+
+	if err := config.DB.Delete(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete reorder rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reorder rule deleted successfully"})
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reportWidth es el ancho en caracteres del reporte en texto (papel de 80 mm)
+const reportWidth = 48
+
+// GetSessionXReport godoc
+// @Summary      Reporte X
+// @Description  Reporte parcial de una sesión abierta: ventas por medio de pago, categoría e impuesto, devoluciones, anulaciones y efectivo. No cierra la sesión ni se guarda.
+// @Tags         pos-sessions
+// @Accept       json
+// @Produce      json
+// @Produce      plain
+// @Param        id      path   int     true   "ID de la sesión"
+// @Param        format  query  string  false  "Formato de salida"  Enums(json, text)
+// @Success      200  {object}  map[string]interface{}  "data: reporte"
+// @Failure      400  {object}  map[string]string       "error: sesión cerrada o inexistente"
+// @Failure      403  {object}  map[string]string       "error: cierre ciego (solo supervisores)"
+// @Router       /pos-sessions/{id}/x-report [get]
+// @Security     Bearer
+func GetSessionXReport(c *gin.Context) {
+	sessionID, ok := sessionIDParam(c)
+	if !ok {
+		return
+	}
+
+	if blindXReport(c, sessionID) {
+		return
+	}
+
+	report, err := services.NewSessionReportService().XReport(sessionID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondSessionReport(c, report, nil)
+}
+
+// GetSessionZReport godoc
+// @Summary      Reporte Z
+// @Description  Reporte de cierre guardado al cerrar la sesión (numerado por terminal, no se modifica)
+// @Tags         pos-sessions
+// @Accept       json
+// @Produce      json
+// @Produce      plain
+// @Param        id      path   int     true   "ID de la sesión"
+// @Param        format  query  string  false  "Formato de salida"  Enums(json, text)
+// @Success      200  {object}  map[string]interface{}  "data: reporte, z_report: registro guardado"
+// @Failure      404  {object}  map[string]string       "error: la sesión no tiene reporte Z"
+// @Router       /pos-sessions/{id}/z-report [get]
+// @Security     Bearer
+func GetSessionZReport(c *gin.Context) {
+	sessionID, ok := sessionIDParam(c)
+	if !ok {
+		return
+	}
+
+	zReport, report, err := services.NewSessionReportService().ZReport(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondSessionReport(c, report, zReport)
+}
+
+// GetZReports godoc
+// @Summary      Listar reportes Z
+// @Description  Obtiene los reportes Z emitidos, opcionalmente de un terminal
+// @Tags         pos-sessions
+// @Accept       json
+// @Produce      json
+// @Param        pos_id  query  int  false  "Filtrar por terminal POS"
+// @Success      200  {object}  map[string]interface{}  "data: array de reportes Z"
+// @Failure      500  {object}  map[string]string       "error: mensaje"
+// @Router       /z-reports [get]
+// @Security     Bearer
+func GetZReports(c *gin.Context) {
+	var reports []models.ZReport
+
+	query := config.DB.Omit("payload")
+	if posID := c.Query("pos_id"); posID != "" {
+		query = query.Where("pos_id = ?", posID)
+	}
+
+	if err := query.Order("pos_id asc, sequence desc").Find(&reports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch Z reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reports})
+}
+
+// PrintSessionReport godoc
+// @Summary      Imprimir reporte de sesión
+// @Description  Encola el reporte X (sesión abierta) o Z (sesión cerrada) en la impresora del terminal
+// @Tags         pos-sessions
+// @Accept       json
+// @Produce      json
+// @Param        id       path  int                     true  "ID de la sesión"
+// @Param        request  body  map[string]interface{}  true  "type: x o z"
+// @Success      202  {object}  map[string]interface{}  "message y data: print job"
+// @Failure      400  {object}  map[string]string       "error: validación o reporte inexistente"
+// @Failure      403  {object}  map[string]string       "error: cierre ciego (el X solo lo imprime un supervisor)"
+// @Failure      422  {object}  map[string]string       "error: el terminal no tiene impresora"
+// @Router       /pos-sessions/{id}/reports/print [post]
+// @Security     Bearer
+func PrintSessionReport(c *gin.Context) {
+	sessionID, ok := sessionIDParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Type string `json:"type" binding:"required,oneof=x z"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Type == services.ReportTypeX && blindXReport(c, sessionID) {
+		return
+	}
+
+	job, err := services.NewPrintService().PrintSessionReport(sessionID, request.Type, currentUserID(c))
+	if err != nil {
+		respondPrintError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Report queued for printing",
+		"data":    job,
+	})
+}
+
+// blindXReport rechaza con 403 el reporte X de un terminal con cierre ciego si el usuario no es
+// supervisor (el X muestra el efectivo esperado)
+func blindXReport(c *gin.Context, sessionID uint) bool {
+	var session models.POSSession
+	if err := config.DB.Preload("POS").First(&session, sessionID).Error; err != nil || !blindFor(c, &session) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "Blind close: the X report is only available to supervisors"})
+	return true
+}
+
+// respondSessionReport responde el reporte en JSON o, con format=text, como texto de ancho fijo
+func respondSessionReport(c *gin.Context, report *services.SessionReport, zReport *models.ZReport) {
+	if c.Query("format") == "text" {
+		text := strings.Join(services.SessionReportLines(report, reportWidth), "\n") + "\n"
+		c.String(http.StatusOK, text)
+		return
+	}
+
+	response := gin.H{"data": report}
+	if zReport != nil {
+		response["z_report"] = zReport
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func sessionIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
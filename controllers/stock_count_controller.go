@@ -0,0 +1,292 @@
+package controllers
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetStockCounts godoc
+// @Summary      Listar tomas de inventario
+// @Description  Obtiene la lista de conteos físicos de inventario
+// @Tags         stock-counts
+// @Accept       json
+// @Produce      json
+// @Param        state         query  string  false  "Filtrar por estado"  Enums(draft, counting, review, validated, cancelled)
+// @Param        warehouse_id  query  int     false  "Filtrar por almacén"
+// @Success      200  {object}  map[string]interface{}  "data: array de stock counts"
+// @Failure      500  {object}  map[string]string       "error: mensaje"
+// @Router       /stock-counts [get]
+// @Security     Bearer
+func GetStockCounts(c *gin.Context) {
+	var counts []models.StockCount
+
+	query := config.DB
+	if state := c.Query("state"); state != "" {
+		query = query.Where("state = ?", state)
+	}
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+	}
+
+	if err := query.Preload("Warehouse").Order("id desc").Find(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock counts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": counts})
+}
+
+// GetStockCount godoc
+// @Summary      Obtener toma de inventario
+// @Description  Obtiene un conteo físico con sus líneas y registros de conteo
+// @Tags         stock-counts
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID del conteo"
+// @Success      200  {object}  map[string]interface{}  "data: stock count"
+// @Failure      404  {object}  map[string]string       "error: Stock count not found"
+// @Router       /stock-counts/{id} [get]
+// @Security     Bearer
+func GetStockCount(c *gin.Context) {
+	id := c.Param("id")
+	var count models.StockCount
+
+	if err := config.DB.
+		Preload("Warehouse").
+		Preload("Lines.Product").
+		Preload("Lines.Entries").
+		First(&count, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock count not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": count})
+}
+
+// CreateStockCount godoc
+// @Summary      Crear toma de inventario
+// @Description  Crea un conteo físico en borrador para un almacén
+// @Tags         stock-counts
+// @Accept       json
+// @Produce      json
+// @Param        count  body  models.StockCount  true  "warehouse_id, name, inventory_category_id (opcional)"
+// @Success      201  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: validación"
+// @Router       /stock-counts [post]
+// @Security     Bearer
+func CreateStockCount(c *gin.Context) {
+	var count models.StockCount
+
+	if err := c.ShouldBindJSON(&count); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	count.State = "draft"
+	count.StartedAt = nil
+	count.ValidatedAt = nil
+	count.ValidatedBy = nil
+	count.Lines = nil
+	count.CreatedBy = currentUserID(c)
+
+	if err := config.DB.Create(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock count"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Stock count created successfully",
+		"data":    count,
+	})
+}
+
+// StartStockCount godoc
+// @Summary      Iniciar conteo
+// @Description  Toma el snapshot de saldos esperados del Kardex y habilita el registro de conteos
+// @Tags         stock-counts
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID del conteo"
+// @Success      200  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: estado inválido"
+// @Router       /stock-counts/{id}/start [patch]
+// @Security     Bearer
+func StartStockCount(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock count ID"})
+		return
+	}
+
+	count, err := services.NewStockCountService().Start(uint(id))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Stock count started",
+		"data":    count,
+	})
+}
+
+// RecordStockCounts godoc
+// @Summary      Registrar cantidades contadas
+// @Description  Registra cantidades contadas por producto. mode=add suma al conteo previo (conteos parciales), mode=set lo reemplaza (recuento)
+// @Tags         stock-counts
+// @Accept       json
+// @Produce      json
+// @Param        id       path  int                     true  "ID del conteo"
+// @Param        request  body  map[string]interface{}  true  "items: [{product_id, quantity, mode}] (se atribuye al usuario autenticado)"
+// @Success      200  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: validación o estado inválido"
+// @Router       /stock-counts/{id}/counts [post]
+// @Security     Bearer
+func RecordStockCounts(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock count ID"})
+		return
+	}
+
+	var request struct {
+		Items []services.CountInput `json:"items" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, err := services.NewStockCountService().RecordCounts(uint(id), currentUserID(c), request.Items)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Counts recorded successfully",
+		"data":    count,
+	})
+}
+
+// ReviewStockCount godoc
+// @Summary      Enviar conteo a revisión
+// @Description  Finaliza el conteo y lo deja listo para revisar diferencias
+// @Tags         stock-counts
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID del conteo"
+// @Success      200  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: estado inválido"
+// @Router       /stock-counts/{id}/review [patch]
+// @Security     Bearer
+func ReviewStockCount(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock count ID"})
+		return
+	}
+
+	count, err := services.NewStockCountService().SubmitForReview(uint(id))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Stock count submitted for review",
+		"data":    count,
+	})
+}
+
+// ValidateStockCount godoc
+// @Summary      Validar conteo
+// @Description  Registra las diferencias como ajustes de inventario (count_correction) y cierra el conteo
+// @Tags         stock-counts
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID del conteo"
+// @Success      200  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: estado inválido o stock insuficiente"
+// @Router       /stock-counts/{id}/validate [patch]
+// @Security     Bearer
+func ValidateStockCount(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock count ID"})
+		return
+	}
+
+	count, err := services.NewStockCountService().Validate(uint(id), currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Stock count validated and inventory adjusted",
+		"data":    count,
+	})
+}
+
+// CancelStockCount godoc
+// @Summary      Cancelar conteo
+// @Description  Anula un conteo que aún no fue validado
+// @Tags         stock-counts
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID del conteo"
+// @Success      200  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: estado inválido"
+// @Router       /stock-counts/{id}/cancel [patch]
+// @Security     Bearer
+func CancelStockCount(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock count ID"})
+		return
+	}
+
+	count, err := services.NewStockCountService().Cancel(uint(id))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Stock count cancelled",
+		"data":    count,
+	})
+}
+
+// GetStockCountVariance godoc
+// @Summary      Reporte de diferencias
+// @Description  Obtiene las diferencias entre lo esperado y lo contado, valorizadas al costo
+// @Tags         stock-counts
+// @Accept       json
+// @Produce      json
+// @Param        id  path  int  true  "ID del conteo"
+// @Success      200  {object}  map[string]interface{}  "data: reporte de diferencias"
+// @Failure      404  {object}  map[string]string       "error: Stock count not found"
+// @Router       /stock-counts/{id}/variance [get]
+// @Security     Bearer
+func GetStockCountVariance(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock count ID"})
+		return
+	}
+
+	report, err := services.NewStockCountService().GetVarianceReport(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock count not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
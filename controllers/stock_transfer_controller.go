@@ -150,12 +150,13 @@ func UpdateStockTransfer(c *gin.Context) {
 
 // SendStockTransfer godoc
 // @Summary      Enviar transferencia
-// @Description  Cambia el estado a in_transit (descuenta del origen)
+// @Description  Cambia el estado a in_transit y reserva las cantidades en el almacén origen
 // @Tags         stock-transfers
 // @Accept       json
 // @Produce      json
 // @Param        id  path  int  true  "ID de la transferencia"
 // @Success      200  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: estado inválido o stock insuficiente"
 // @Failure      404  {object}  map[string]string       "error: Stock transfer not found"
 // @Router       /stock-transfers/{id}/send [patch]
 // @Security     Bearer
@@ -163,16 +164,14 @@ func SendStockTransfer(c *gin.Context) {
 	id := c.Param("id")
 	var transfer models.StockTransfer
 
-	if err := config.DB.First(&transfer, id).Error; err != nil {
+	if err := config.DB.Preload("Items").First(&transfer, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Stock transfer not found"})
 		return
 	}
 
-	transfer.Status = "in_transit"
-	// TODO: Descontar stock del almacén origen
-
-	if err := config.DB.Save(&transfer).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send stock transfer"})
+	inventoryService := services.NewInventoryService()
+	if err := inventoryService.SendTransfer(&transfer); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Inventory error: %s", err.Error())})
 		return
 	}
 
@@ -232,12 +231,13 @@ func ReceiveStockTransfer(c *gin.Context) {
 
 // CancelStockTransfer godoc
 // @Summary      Cancelar transferencia
-// @Description  Cambia el estado a cancelled
+// @Description  Cambia el estado a cancelled y libera lo reservado si estaba en tránsito
 // @Tags         stock-transfers
 // @Accept       json
 // @Produce      json
 // @Param        id  path  int  true  "ID de la transferencia"
 // @Success      200  {object}  map[string]interface{}  "message y data"
+// @Failure      400  {object}  map[string]string       "error: transferencia ya recibida"
 // @Failure      404  {object}  map[string]string       "error: Stock transfer not found"
 // @Router       /stock-transfers/{id}/cancel [patch]
 // @Security     Bearer
@@ -245,15 +245,14 @@ func CancelStockTransfer(c *gin.Context) {
 	id := c.Param("id")
 	var transfer models.StockTransfer
 
-	if err := config.DB.First(&transfer, id).Error; err != nil {
+	if err := config.DB.Preload("Items").First(&transfer, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Stock transfer not found"})
 		return
 	}
 
-	transfer.Status = "cancelled"
-
-	if err := config.DB.Save(&transfer).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel stock transfer"})
+	inventoryService := services.NewInventoryService()
+	if err := inventoryService.CancelTransfer(&transfer); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Inventory error: %s", err.Error())})
 		return
 	}
 
@@ -233,14 +233,15 @@ func GetAvailableTables(c *gin.Context) {
 		query = query.Where("area_id = ?", areaID)
 	}
 
-	// Obtener mesas que NO tienen órdenes en estado 'pending' o 'confirmed'
+	// Obtener mesas que NO tienen órdenes abiertas ('draft' o 'confirmed')
 	var tables []models.Table
 	if err := query.Preload("Area").
 		Where("id NOT IN (?)",
 			config.DB.Table("orders").
 				Select("table_id").
 				Where("table_id IS NOT NULL").
-				Where("status IN (?)", []string{"pending", "confirmed"}),
+				Where("state IN (?)", []string{"draft", "confirmed"}).
+				Where("deleted_at IS NULL"),
 		).Find(&tables).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch available tables"})
 		return
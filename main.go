@@ -23,6 +23,7 @@ import (
 	"b-resto/config"
 	"b-resto/models"
 	"b-resto/routes"
+	"b-resto/services"
 	"log"
 	"os"
 
@@ -32,73 +33,54 @@ import (
 )
 
 func main() {
+	// Subcomandos sin base de datos (ej: b-resto fake-printer --addr :9100)
+	if len(os.Args) > 1 && !dbCommands[os.Args[1]] {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
+	}
+
 	dbURL := os.Getenv("DATABASE_URL")
 
 	db, err := gorm.Open(postgres.Open(dbURL), &gorm.Config{})
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
-	if err := db.AutoMigrate(
-		// Existentes
-		&models.User{},
-		&models.Unit{},
-		&models.Company{},
-		&models.PaymentMethod{},
-		&models.Tax{},
-
-		// Nuevos - Base
-		&models.InventoryCategory{},
-		&models.ProductCategory{},
-		&models.KitchenStation{},
-		&models.Warehouse{},
-		&models.TableArea{},
-		&models.Table{},
-
-		// Nuevos - Productos
-		&models.ProductTemplate{},
-		&models.ProductProduct{},
-		&models.ProductAttribute{},
-		&models.ProductAttributeValue{},
-
-		// Nuevos - Combos
-		&models.Combo{},
-		&models.ComboItem{},
-
-		// Nuevos - Órdenes y Ventas
-		&models.Sequence{},
-		&models.Journal{},
-		&models.Order{},
-		&models.OrderItem{},
-		&models.OrderPayment{},
-		&models.KitchenTicket{},
-		&models.KitchenTicketItem{},
-
-		// Nuevos - POS y Caja
-		&models.POS{},
-		&models.POSSession{},
-		&models.CashMovement{},
-
-		// Nuevos - Inventario
-		&models.Partner{},
-		&models.Recipe{},
-		&models.StockTransfer{},
-		&models.StockTransferItem{},
-		&models.PurchaseOrder{},
-		&models.PurchaseOrderItem{},
-		&models.Inventory{},
-
-		// Nuevos - Reservaciones
-		&models.Reservation{},
-	); err != nil {
+	if err := db.AutoMigrate(models.All()...); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 	log.Println("✅ Database migration completed successfully")
 
 	config.DB = db
 
+	// Saldos de stock para movimientos registrados antes de la tabla stock_quants
+	if err := services.NewInventoryService().BackfillStockQuants(); err != nil {
+		log.Printf("⚠️ Failed to backfill stock quants: %v", err)
+	}
+
+	// Factores de conversión de las unidades creadas antes de la columna factor
+	if err := services.NewInventoryService().BackfillUnitFactors(); err != nil {
+		log.Printf("⚠️ Failed to backfill unit factors: %v", err)
+	}
+
+	// Subcomandos de mantenimiento (ej: b-resto recompute-kardex --product 12)
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
+	}
+
 	config.InitCasbin()
 	config.SeedCasbinPolicies()
 
+	// Avisos de Postgres para el feed en tiempo real de las estaciones de cocina
+	go services.ListenKitchenEvents(dbURL)
+
+	// Cola de impresión ESC/POS (tickets de cocina y comprobantes)
+	go services.RunPrintQueue()
+
 	r := gin.Default()
 	r.Use(CORSMiddleware())
 	routes.SetupRoutes(r)
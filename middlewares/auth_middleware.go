@@ -35,3 +35,19 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// OptionalAuthMiddleware identifica al usuario si la petición trae un token válido, sin exigirlo
+// (modo desarrollo: las rutas quedan abiertas pero las acciones se atribuyen a quien las hace)
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString != "" {
+			if claims, err := utils.ValidateToken(tokenString); err == nil {
+				c.Set("username", claims.Username)
+				c.Set("role", claims.Role)
+				c.Set("claims", claims)
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,77 @@
+package models
+
+// All devuelve los modelos a migrar, en orden de dependencia
+func All() []interface{} {
+	return []interface{}{
+		// Existentes
+		&User{},
+		&Unit{},
+		&Company{},
+		&PaymentMethod{},
+		&Currency{},
+		&ExchangeRate{},
+		&Tax{},
+
+		// Nuevos - Base
+		&InventoryCategory{},
+		&ProductCategory{},
+		&KitchenStation{},
+		&Warehouse{},
+		&TableArea{},
+		&Table{},
+
+		// Nuevos - Productos
+		&ProductTemplate{},
+		&ProductProduct{},
+		&ProductAttribute{},
+		&ProductAttributeValue{},
+
+		// Nuevos - Combos
+		&Combo{},
+		&ComboItem{},
+
+		// Nuevos - Órdenes y Ventas
+		&Sequence{},
+		&Journal{},
+		&Order{},
+		&OrderItem{},
+		&OrderTax{},
+		&OrderCheck{},
+		&OrderCheckItem{},
+		&OrderPayment{},
+		&Refund{},
+		&RefundItem{},
+		&RefundTax{},
+		&KitchenTicket{},
+		&KitchenTicketItem{},
+		&KitchenTicketEvent{},
+		&PrintJob{},
+
+		// Nuevos - POS y Caja
+		&POS{},
+		&POSSession{},
+		&CashMovement{},
+		&ZReport{},
+		&CashCount{},
+		&CashCountLine{},
+
+		// Nuevos - Inventario
+		&Partner{},
+		&Recipe{},
+		&StockTransfer{},
+		&StockTransferItem{},
+		&PurchaseOrder{},
+		&PurchaseOrderItem{},
+		&Inventory{},
+		&StockLayer{},
+		&StockQuant{},
+		&InventoryAdjustment{},
+		&StockCount{},
+		&StockCountLine{},
+		&StockCountEntry{},
+		&ReorderRule{},
+
+		// Nuevos - Reservaciones
+		&Reservation{},
+	}
+}
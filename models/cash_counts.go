@@ -0,0 +1,39 @@
+package models
+
+import "gorm.io/gorm"
+
+// CashCount - Conteo del efectivo de una sesión por denominación (apertura, cierre o reconteo del supervisor)
+type CashCount struct {
+	gorm.Model
+	POSSessionID uint     `json:"pos_session_id" gorm:"not null;index"`
+	Kind         string   `json:"kind" gorm:"size:20;not null"` // opening, closing, recount
+	CountedBy    *uint    `json:"counted_by"`
+	Total        float64  `json:"total" gorm:"type:decimal(12,2);not null"` // Suma de las líneas en la moneda base
+	Expected     *float64 `json:"expected" gorm:"type:decimal(12,2)"`       // Esperado al contar (cierre y reconteo)
+	Difference   *float64 `json:"difference" gorm:"type:decimal(12,2)"`     // Total - esperado
+	Notes        string   `json:"notes" gorm:"type:text"`
+
+	// Relaciones
+	POSSession    *POSSession     `json:"pos_session,omitempty" gorm:"foreignKey:POSSessionID"`
+	CountedByUser *User           `json:"counted_by_user,omitempty" gorm:"foreignKey:CountedBy"`
+	Lines         []CashCountLine `json:"lines,omitempty" gorm:"foreignKey:CashCountID"`
+}
+
+func (CashCount) TableName() string {
+	return "cash_counts"
+}
+
+// CashCountLine - Cantidad contada de un billete o moneda
+type CashCountLine struct {
+	ID           uint    `json:"id" gorm:"primaryKey"`
+	CashCountID  uint    `json:"cash_count_id" gorm:"not null;index"`
+	Currency     string  `json:"currency" gorm:"size:3;not null"` // Código ISO 4217 (PEN, USD)
+	Type         string  `json:"type" gorm:"size:10;not null"`    // bill, coin
+	Denomination float64 `json:"denomination" gorm:"type:decimal(10,2);not null"`
+	Quantity     int     `json:"quantity" gorm:"not null"`
+	Amount       float64 `json:"amount" gorm:"type:decimal(12,2);not null"`
+}
+
+func (CashCountLine) TableName() string {
+	return "cash_count_lines"
+}
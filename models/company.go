@@ -2,6 +2,12 @@ package models
 
 import "gorm.io/gorm"
 
+// Métodos de valorización de inventario
+const (
+	CostMethodAverage = "average"
+	CostMethodFIFO    = "fifo"
+)
+
 // Company representa una compañía o sucursal
 type Company struct {
 	gorm.Model
@@ -25,6 +31,13 @@ type Company struct {
 	Email   string `json:"email" gorm:"size:150" binding:"omitempty,email,max=150"`
 	Website string `json:"website" gorm:"size:255" binding:"omitempty,url,max=255"`
 
+	// Inventario: almacén por defecto cuando el POS no define uno
+	DefaultWarehouseID *uint  `json:"default_warehouse_id"`
+	CostMethod         string `json:"cost_method" gorm:"size:20;default:'average';not null" binding:"omitempty,oneof=average fifo"` // average, fifo
+
+	// Moneda base: precios, totales y arqueo (las demás se convierten con el tipo de cambio del día)
+	CurrencyCode string `json:"currency_code" gorm:"size:3;default:'PEN';not null" binding:"omitempty,len=3"`
+
 	// Ubicación
 	Address    string `json:"address" gorm:"type:text" binding:"omitempty,max=500"`
 	UbigeoCode string `json:"ubigeo_code" gorm:"size:6" binding:"omitempty,len=6"`
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Currency - Moneda aceptada en caja (código ISO 4217)
+type Currency struct {
+	gorm.Model
+	Code     string `json:"code" gorm:"size:3;not null;uniqueIndex" binding:"required,len=3"`
+	Name     string `json:"name" gorm:"size:50;not null" binding:"required,min=2,max=50"`
+	Symbol   string `json:"symbol" gorm:"size:5" binding:"omitempty,max=5"`
+	IsActive bool   `json:"is_active" gorm:"default:true;not null"`
+}
+
+func (Currency) TableName() string {
+	return "currencies"
+}
+
+// ExchangeRate - Tipo de cambio de una moneda en una fecha, por compañía. Rate es cuánto vale una
+// unidad de la moneda en la moneda base de la compañía (ej: 1 USD = 3.75 PEN).
+type ExchangeRate struct {
+	gorm.Model
+	CompanyID    uint      `json:"company_id" gorm:"not null;uniqueIndex:idx_exchange_rate_day"`
+	CurrencyCode string    `json:"currency_code" gorm:"size:3;not null;uniqueIndex:idx_exchange_rate_day"`
+	Date         time.Time `json:"date" gorm:"type:date;not null;uniqueIndex:idx_exchange_rate_day"`
+	Rate         float64   `json:"rate" gorm:"type:decimal(12,6);not null"`
+
+	// Relaciones
+	Company *Company `json:"company,omitempty" gorm:"foreignKey:CompanyID"`
+}
+
+func (ExchangeRate) TableName() string {
+	return "exchange_rates"
+}
@@ -5,10 +5,11 @@ import "gorm.io/gorm"
 // InventoryCategory - Categorización para inventario (materias primas, insumos)
 type InventoryCategory struct {
 	gorm.Model
-	ParentID *uint  `json:"parent_id" gorm:"default:null"`
-	Name     string `json:"name" gorm:"size:255;not null" binding:"required,min=3,max=255"`
-	FullName string `json:"full_name" gorm:"size:255"`
-	IsActive bool   `json:"is_active" gorm:"default:true;not null"`
+	ParentID   *uint  `json:"parent_id" gorm:"default:null"`
+	Name       string `json:"name" gorm:"size:255;not null" binding:"required,min=3,max=255"`
+	FullName   string `json:"full_name" gorm:"size:255"`
+	CostMethod string `json:"cost_method" gorm:"size:20" binding:"omitempty,oneof=average fifo"` // Vacío = hereda de la categoría padre o de la compañía
+	IsActive   bool   `json:"is_active" gorm:"default:true;not null"`
 
 	// Relaciones
 	Parent   *InventoryCategory  `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
@@ -15,24 +15,29 @@ type Inventory struct {
 
 	// ✅ Origen del movimiento (solo uno debe estar set)
 	OrderID         *uint `json:"order_id"`          // Si es por venta
+	OrderItemID     *uint `json:"order_item_id"`     // Línea de la venta (consumo por receta)
 	PurchaseOrderID *uint `json:"purchase_order_id"` // Si es por compra
 	StockTransferID *uint `json:"stock_transfer_id"` // Si es por transferencia
+	AdjustmentID    *uint `json:"adjustment_id"`     // Si es por ajuste manual
+
+	// Salida de venta que esta entrada compensa (devolución/cancelación)
+	ReversedInventoryID *uint `json:"reversed_inventory_id" gorm:"index"`
 
 	Detail string `json:"detail" gorm:"size:500"` // Descripción (ajustes manuales)
 
 	// Entradas
 	QuantityIn float64 `json:"quantity_in" gorm:"type:decimal(10,4);default:0;not null"`
-	CostIn     float64 `json:"cost_in" gorm:"type:decimal(10,2);default:0;not null"`
+	CostIn     float64 `json:"cost_in" gorm:"type:decimal(12,4);default:0;not null"`
 	TotalIn    float64 `json:"total_in" gorm:"type:decimal(10,2);default:0;not null"`
 
 	// Salidas
 	QuantityOut float64 `json:"quantity_out" gorm:"type:decimal(10,4);default:0;not null"`
-	CostOut     float64 `json:"cost_out" gorm:"type:decimal(10,2);default:0;not null"`
+	CostOut     float64 `json:"cost_out" gorm:"type:decimal(12,4);default:0;not null"`
 	TotalOut    float64 `json:"total_out" gorm:"type:decimal(10,2);default:0;not null"`
 
-	// Balances (acumulados)
+	// Balances (acumulados) - CostBalance es el costo promedio ponderado vigente
 	QuantityBalance float64 `json:"quantity_balance" gorm:"type:decimal(10,4);default:0;not null"`
-	CostBalance     float64 `json:"cost_balance" gorm:"type:decimal(10,2);default:0;not null"`
+	CostBalance     float64 `json:"cost_balance" gorm:"type:decimal(12,4);default:0;not null"`
 	TotalBalance    float64 `json:"total_balance" gorm:"type:decimal(10,2);default:0;not null"`
 
 	CreatedAt time.Time      `json:"created_at"`
@@ -40,11 +45,14 @@ type Inventory struct {
 	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 
 	// Relaciones
-	Product       *ProductProduct `json:"product,omitempty" gorm:"foreignKey:ProductID"`
-	Warehouse     *Warehouse      `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`
-	Order         *Order          `json:"order,omitempty" gorm:"foreignKey:OrderID"`
-	PurchaseOrder *PurchaseOrder  `json:"purchase_order,omitempty" gorm:"foreignKey:PurchaseOrderID"`
-	StockTransfer *StockTransfer  `json:"stock_transfer,omitempty" gorm:"foreignKey:StockTransferID"`
+	Product       *ProductProduct      `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Warehouse     *Warehouse           `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`
+	Order         *Order               `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	OrderItem     *OrderItem           `json:"order_item,omitempty" gorm:"foreignKey:OrderItemID"`
+	PurchaseOrder *PurchaseOrder       `json:"purchase_order,omitempty" gorm:"foreignKey:PurchaseOrderID"`
+	StockTransfer *StockTransfer       `json:"stock_transfer,omitempty" gorm:"foreignKey:StockTransferID"`
+	Adjustment    *InventoryAdjustment `json:"adjustment,omitempty" gorm:"foreignKey:AdjustmentID"`
+	Reversed      *Inventory           `json:"reversed,omitempty" gorm:"foreignKey:ReversedInventoryID"`
 }
 
 func (Inventory) TableName() string {
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Motivos de ajuste de inventario
+const (
+	AdjustmentReasonWaste           = "waste"
+	AdjustmentReasonBreakage        = "breakage"
+	AdjustmentReasonCountCorrection = "count_correction"
+	AdjustmentReasonStaffMeal       = "staff_meal"
+	AdjustmentReasonOther           = "other"
+)
+
+// InventoryAdjustment - Ajustes manuales de inventario (mermas, roturas, conteos, consumo de personal)
+type InventoryAdjustment struct {
+	gorm.Model
+	WarehouseID uint       `json:"warehouse_id" gorm:"not null"`
+	ProductID   uint       `json:"product_id" gorm:"not null"`   // FK a product_product
+	Type        string     `json:"type" gorm:"size:10;not null"` // in, out
+	Quantity    float64    `json:"quantity" gorm:"type:decimal(10,4);not null"`
+	Reason      string     `json:"reason" gorm:"size:50;not null"` // waste, breakage, count_correction, staff_meal, other
+	UnitCost    float64    `json:"unit_cost" gorm:"type:decimal(12,4);default:0;not null"`
+	TotalCost   float64    `json:"total_cost" gorm:"type:decimal(10,2);default:0;not null"`
+	Status      string     `json:"status" gorm:"size:50;default:'pending';not null"` // pending, done, rejected
+	Notes       string     `json:"notes" gorm:"type:text"`
+	CreatedBy   *uint      `json:"created_by"`
+	ApprovedBy  *uint      `json:"approved_by"`
+	ApprovedAt  *time.Time `json:"approved_at"`
+	InventoryID *uint      `json:"inventory_id"` // Movimiento Kardex generado al aplicar
+	OrderID     *uint      `json:"order_id"`     // Venta devuelta que originó la merma
+
+	// Relaciones
+	Warehouse      *Warehouse      `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`
+	Product        *ProductProduct `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	CreatedByUser  *User           `json:"created_by_user,omitempty" gorm:"foreignKey:CreatedBy"`
+	ApprovedByUser *User           `json:"approved_by_user,omitempty" gorm:"foreignKey:ApprovedBy"`
+	Order          *Order          `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+}
+
+func (InventoryAdjustment) TableName() string {
+	return "inventory_adjustments"
+}
@@ -7,7 +7,7 @@ type Journal struct {
 	CompanyID uint   `json:"company_id" gorm:"not null"`
 	Code      string `json:"code" gorm:"size:50;not null;uniqueIndex"`
 	Name      string `json:"name" gorm:"size:255;not null"`
-	Type      string `json:"type" gorm:"size:50;not null"` // sale, purchase, cash, bank
+	Type      string `json:"type" gorm:"size:50;not null"` // sale, purchase, cash, bank, refund (notas de crédito)
 	IsActive  bool   `json:"is_active" gorm:"default:true"`
 
 	// Relaciones
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// KitchenTicketEvent - Evento del feed de cocina (los KDS lo reciben por SSE y lo retoman por ID al reconectar)
+type KitchenTicketEvent struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	KitchenStationID uint      `json:"kitchen_station_id" gorm:"not null;index"`
+	KitchenTicketID  uint      `json:"kitchen_ticket_id" gorm:"not null;index"`
+	Type             string    `json:"type" gorm:"size:30;not null"`      // created, updated, state_changed, voided
+	Payload          string    `json:"payload" gorm:"type:text;not null"` // Ticket completo en JSON (con sus líneas)
+	CreatedAt        time.Time `json:"created_at" gorm:"default:CURRENT_TIMESTAMP"`
+
+	// Relaciones
+	KitchenStation *KitchenStation `json:"kitchen_station,omitempty" gorm:"foreignKey:KitchenStationID"`
+	KitchenTicket  *KitchenTicket  `json:"kitchen_ticket,omitempty" gorm:"foreignKey:KitchenTicketID"`
+}
+
+func (KitchenTicketEvent) TableName() string {
+	return "kitchen_ticket_events"
+}
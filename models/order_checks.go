@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrderCheck - Subcuenta de una orden dividida (se cobra y se cierra por separado)
+type OrderCheck struct {
+	gorm.Model
+	OrderID  uint       `json:"order_id" gorm:"not null;index"`
+	Sequence int        `json:"sequence" gorm:"not null"`                     // 1..N dentro de la orden
+	Name     string     `json:"name" gorm:"size:100;not null"`                // "Cuenta 1", "Asiento 3"
+	Seat     *int       `json:"seat"`                                         // Asiento, si la división fue por asientos
+	Amount   float64    `json:"amount" gorm:"type:decimal(10,2);not null"`    // Importe a cobrar (con impuestos)
+	State    string     `json:"state" gorm:"size:20;default:'open';not null"` // open, done
+	ClosedAt *time.Time `json:"closed_at"`
+
+	// Relaciones
+	Order    *Order           `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	Items    []OrderCheckItem `json:"items,omitempty" gorm:"foreignKey:OrderCheckID"`
+	Payments []OrderPayment   `json:"payments,omitempty" gorm:"foreignKey:OrderCheckID"`
+}
+
+func (OrderCheck) TableName() string {
+	return "order_checks"
+}
+
+// OrderCheckItem - Parte (total o fraccionada) de una línea asignada a una subcuenta
+type OrderCheckItem struct {
+	gorm.Model
+	OrderCheckID uint    `json:"order_check_id" gorm:"not null;index"`
+	OrderItemID  uint    `json:"order_item_id" gorm:"not null;index"`
+	Quantity     float64 `json:"quantity" gorm:"type:decimal(10,4);not null"` // Puede ser una fracción de la línea
+	Amount       float64 `json:"amount" gorm:"type:decimal(10,2);not null"`   // Parte del total con impuestos de la línea
+
+	// Relaciones
+	OrderCheck *OrderCheck `json:"order_check,omitempty" gorm:"foreignKey:OrderCheckID"`
+	OrderItem  *OrderItem  `json:"order_item,omitempty" gorm:"foreignKey:OrderItemID"`
+}
+
+func (OrderCheckItem) TableName() string {
+	return "order_check_items"
+}
@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // OrderItem - Items de una orden (productos vendidos)
 type OrderItem struct {
@@ -8,13 +12,25 @@ type OrderItem struct {
 	OrderID       uint    `json:"order_id" gorm:"not null"`
 	ProductID     uint    `json:"product_id" gorm:"not null"` // FK a product_product (variante)
 	Quantity      float64 `json:"quantity" gorm:"type:decimal(10,2);not null"`
-	PriceUnit     float64 `json:"price_unit" gorm:"type:decimal(10,2);not null"` // Precio histórico
-	PriceSubtotal float64 `json:"price_subtotal" gorm:"type:decimal(10,2);not null"`
-	ProductNotes  string  `json:"product_notes" gorm:"type:text"` // "Sin cebolla", "Extra queso"
+	PriceUnit     float64 `json:"price_unit" gorm:"type:decimal(10,2);not null"`               // Precio histórico
+	PriceSubtotal float64 `json:"price_subtotal" gorm:"type:decimal(10,2);not null"`           // Sin impuestos (calculado)
+	PriceTax      float64 `json:"price_tax" gorm:"type:decimal(10,2);default:0;not null"`      // Calculado
+	PriceTotal    float64 `json:"price_total" gorm:"type:decimal(10,2);default:0;not null"`    // Con impuestos (calculado)
+	ProductNotes  string  `json:"product_notes" gorm:"type:text"`                              // "Sin cebolla", "Extra queso"
+	Seat          int     `json:"seat" gorm:"default:0;not null"`                              // Asiento del comensal (0 = compartido)
+	FiredQuantity float64 `json:"fired_quantity" gorm:"type:decimal(10,2);default:0;not null"` // Cantidad ya enviada a cocina
+
+	// Anulación: la línea se conserva para auditoría pero no suma ni descuenta stock
+	State      string     `json:"state" gorm:"size:20;default:'active';not null"` // active, voided
+	VoidReason string     `json:"void_reason" gorm:"size:255"`
+	VoidedBy   *uint      `json:"voided_by"`
+	VoidedAt   *time.Time `json:"voided_at"`
 
 	// Relaciones
 	Order   *Order          `json:"order,omitempty" gorm:"foreignKey:OrderID"`
 	Product *ProductProduct `json:"product,omitempty" gorm:"foreignKey:ProductID"` // ✅ Directo a product_product
+	Taxes   []Tax           `json:"taxes,omitempty" gorm:"many2many:order_item_taxes;"`
+	Voider  *User           `json:"voider,omitempty" gorm:"foreignKey:VoidedBy"`
 }
 
 func (OrderItem) TableName() string {
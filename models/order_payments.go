@@ -10,14 +10,24 @@ type OrderPayment struct {
 	gorm.Model
 	OrderID         uint      `json:"order_id" gorm:"not null"`
 	PaymentMethodID uint      `json:"payment_method_id" gorm:"not null"`
-	JournalID       uint      `json:"journal_id" gorm:"not null"` // Journal de caja
-	Amount          float64   `json:"amount" gorm:"type:decimal(10,2);not null"`
+	JournalID       uint      `json:"journal_id" gorm:"not null"`                // Journal de caja
+	Amount          float64   `json:"amount" gorm:"type:decimal(10,2);not null"` // En moneda base, aplicado a la orden. Negativo si es una devolución
+	Currency        string    `json:"currency" gorm:"size:3"`                    // Moneda entregada (vacío en pagos anteriores = moneda base)
+	TenderedAmount  float64   `json:"tendered_amount" gorm:"type:decimal(12,2)"` // Monto entregado en esa moneda
+	ExchangeRate    float64   `json:"exchange_rate" gorm:"type:decimal(12,6)"`   // Moneda base por unidad de la entregada
+	ChangeAmount    float64   `json:"change_amount" gorm:"type:decimal(10,2)"`   // Vuelto, siempre en moneda base
 	PaymentDate     time.Time `json:"payment_date" gorm:"type:date;not null"`
+	RefundID        *uint     `json:"refund_id"`                   // Devolución que originó el pago negativo
+	OrderCheckID    *uint     `json:"order_check_id"`              // Subcuenta pagada (órdenes divididas)
+	POSSessionID    *uint     `json:"pos_session_id" gorm:"index"` // Sesión de caja que cobró (puede no ser la de la orden)
 
 	// Relaciones
 	Order         *Order         `json:"order,omitempty" gorm:"foreignKey:OrderID"`
 	PaymentMethod *PaymentMethod `json:"payment_method,omitempty" gorm:"foreignKey:PaymentMethodID"`
 	Journal       *Journal       `json:"journal,omitempty" gorm:"foreignKey:JournalID"`
+	Refund        *Refund        `json:"refund,omitempty" gorm:"foreignKey:RefundID"`
+	OrderCheck    *OrderCheck    `json:"order_check,omitempty" gorm:"foreignKey:OrderCheckID"`
+	POSSession    *POSSession    `json:"pos_session,omitempty" gorm:"foreignKey:POSSessionID"`
 }
 
 func (OrderPayment) TableName() string {
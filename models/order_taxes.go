@@ -0,0 +1,23 @@
+package models
+
+import "gorm.io/gorm"
+
+// OrderTax - Desglose de impuestos de una orden (una línea por impuesto aplicado)
+type OrderTax struct {
+	gorm.Model
+	OrderID          uint    `json:"order_id" gorm:"not null;index"`
+	TaxID            uint    `json:"tax_id" gorm:"not null"`
+	Name             string  `json:"name" gorm:"size:255;not null"`
+	RatePercent      float64 `json:"rate_percent" gorm:"type:decimal(5,2);not null"`
+	IsPriceInclusive bool    `json:"is_price_inclusive" gorm:"default:false;not null"`
+	Base             float64 `json:"base" gorm:"type:decimal(10,2);not null"`   // Suma de subtotales sin impuestos gravados
+	Amount           float64 `json:"amount" gorm:"type:decimal(10,2);not null"` // Suma de impuestos por línea
+
+	// Relaciones
+	Order *Order `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	Tax   *Tax   `json:"tax,omitempty" gorm:"foreignKey:TaxID"`
+}
+
+func (OrderTax) TableName() string {
+	return "order_taxes"
+}
@@ -9,21 +9,36 @@ import (
 // Order - Órdenes de venta del POS
 type Order struct {
 	gorm.Model
-	JournalID   uint      `json:"journal_id" gorm:"not null"`
-	UserID      uint      `json:"user_id" gorm:"not null"`
-	TableID     *uint     `json:"table_id"`                                      // Nullable - null si es para llevar
-	Name        string    `json:"name" gorm:"size:100;not null"`                 // SO/2024/0001
-	State       string    `json:"state" gorm:"size:50;default:'draft';not null"` // draft, confirmed, done, cancelled
-	OrderDate   time.Time `json:"order_date" gorm:"type:date;not null"`
-	TotalAmount float64   `json:"total_amount" gorm:"type:decimal(10,2);default:0;not null"`
-	Note        string    `json:"note" gorm:"type:text"`
+	JournalID    uint       `json:"journal_id" gorm:"not null"`
+	UserID       uint       `json:"user_id" gorm:"not null"`
+	TableID      *uint      `json:"table_id"`                                      // Nullable - null si es para llevar
+	POSID        *uint      `json:"pos_id"`                                        // Terminal que tomó la orden
+	POSSessionID *uint      `json:"pos_session_id" gorm:"index"`                   // Sesión de caja en la que se abrió
+	WarehouseID  *uint      `json:"warehouse_id"`                                  // Almacén del que se descontó el stock
+	Name         string     `json:"name" gorm:"size:100;not null"`                 // SO/2024/0001
+	State        string     `json:"state" gorm:"size:50;default:'draft';not null"` // draft, confirmed, done, cancelled
+	OrderDate    time.Time  `json:"order_date" gorm:"type:date;not null"`
+	Note         string     `json:"note" gorm:"type:text"`
+	MergedIntoID *uint      `json:"merged_into_id"` // Orden a la que se unió (queda cancelada)
+	CompletedAt  *time.Time `json:"completed_at"`   // Cierre (paso a done)
+
+	// Importes calculados por el servidor a partir de las líneas
+	AmountUntaxed float64 `json:"amount_untaxed" gorm:"type:decimal(10,2);default:0;not null"`
+	AmountTax     float64 `json:"amount_tax" gorm:"type:decimal(10,2);default:0;not null"`
+	TotalAmount   float64 `json:"total_amount" gorm:"type:decimal(10,2);default:0;not null"`
 
 	// Relaciones
-	Journal  *Journal        `json:"journal,omitempty" gorm:"foreignKey:JournalID"`
-	User     *User           `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	Items    []OrderItem     `json:"items,omitempty" gorm:"foreignKey:OrderID"`
-	Payments []OrderPayment  `json:"payments,omitempty" gorm:"foreignKey:OrderID"`
-	Tickets  []KitchenTicket `json:"tickets,omitempty" gorm:"foreignKey:OrderID"`
+	Journal    *Journal        `json:"journal,omitempty" gorm:"foreignKey:JournalID"`
+	User       *User           `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	POS        *POS            `json:"pos,omitempty" gorm:"foreignKey:POSID"`
+	POSSession *POSSession     `json:"pos_session,omitempty" gorm:"foreignKey:POSSessionID"`
+	Table      *Table          `json:"table,omitempty" gorm:"foreignKey:TableID"`
+	Warehouse  *Warehouse      `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`
+	Items      []OrderItem     `json:"items,omitempty" gorm:"foreignKey:OrderID"`
+	Payments   []OrderPayment  `json:"payments,omitempty" gorm:"foreignKey:OrderID"`
+	Tickets    []KitchenTicket `json:"tickets,omitempty" gorm:"foreignKey:OrderID"`
+	TaxLines   []OrderTax      `json:"tax_lines,omitempty" gorm:"foreignKey:OrderID"`
+	Checks     []OrderCheck    `json:"checks,omitempty" gorm:"foreignKey:OrderID"`
 }
 
 func (Order) TableName() string {
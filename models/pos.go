@@ -5,18 +5,22 @@ import "gorm.io/gorm"
 // POS - Punto de Venta físico (terminal, computadora, tablet)
 type POS struct {
 	gorm.Model
-	CompanyID        uint   `json:"company_id" gorm:"not null"`
-	Code             string `json:"code" gorm:"size:50;not null;uniqueIndex"`
-	Name             string `json:"name" gorm:"size:255;not null" binding:"required,min=3,max=255"`
-	IPAddress        string `json:"ip_address" gorm:"size:50"`
-	PrinterIP        string `json:"printer_ip" gorm:"size:50"`
-	DefaultJournalID *uint  `json:"default_journal_id"`
-	IsActive         bool   `json:"is_active" gorm:"default:true;not null"`
+	CompanyID          uint    `json:"company_id" gorm:"not null"`
+	Code               string  `json:"code" gorm:"size:50;not null;uniqueIndex"`
+	Name               string  `json:"name" gorm:"size:255;not null" binding:"required,min=3,max=255"`
+	IPAddress          string  `json:"ip_address" gorm:"size:50"`
+	PrinterIP          string  `json:"printer_ip" gorm:"size:50"`
+	DefaultJournalID   *uint   `json:"default_journal_id"`
+	DefaultWarehouseID *uint   `json:"default_warehouse_id"`                                           // Almacén del que descuentan las ventas de este terminal
+	BlindClose         bool    `json:"blind_close" gorm:"default:false;not null"`                      // El cajero cuenta sin ver el esperado
+	RecountThreshold   float64 `json:"recount_threshold" gorm:"type:decimal(10,2);default:0;not null"` // Diferencia que exige reconteo del supervisor (0 = nunca)
+	IsActive           bool    `json:"is_active" gorm:"default:true;not null"`
 
 	// Relaciones
-	Company        *Company     `json:"company,omitempty" gorm:"foreignKey:CompanyID"`
-	DefaultJournal *Journal     `json:"default_journal,omitempty" gorm:"foreignKey:DefaultJournalID"`
-	Sessions       []POSSession `json:"sessions,omitempty" gorm:"foreignKey:POSID"`
+	Company          *Company     `json:"company,omitempty" gorm:"foreignKey:CompanyID"`
+	DefaultJournal   *Journal     `json:"default_journal,omitempty" gorm:"foreignKey:DefaultJournalID"`
+	DefaultWarehouse *Warehouse   `json:"default_warehouse,omitempty" gorm:"foreignKey:DefaultWarehouseID"`
+	Sessions         []POSSession `json:"sessions,omitempty" gorm:"foreignKey:POSID"`
 }
 
 func (POS) TableName() string {
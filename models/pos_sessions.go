@@ -18,7 +18,7 @@ type POSSession struct {
 	ClosedBy        *uint      `json:"closed_by"`
 	OpenedAt        time.Time  `json:"opened_at" gorm:"not null"`
 	ClosedAt        *time.Time `json:"closed_at"`
-	Status          string     `json:"status" gorm:"size:50;default:'open';not null"` // open, closed
+	Status          string     `json:"status" gorm:"size:50;default:'open';not null"` // open, closing (espera reconteo), closed
 	Notes           string     `json:"notes" gorm:"type:text"`
 
 	// Relaciones
@@ -26,6 +26,9 @@ type POSSession struct {
 	OpenedByUser *User          `json:"opened_by_user,omitempty" gorm:"foreignKey:OpenedBy"`
 	ClosedByUser *User          `json:"closed_by_user,omitempty" gorm:"foreignKey:ClosedBy"`
 	Movements    []CashMovement `json:"movements,omitempty" gorm:"foreignKey:POSSessionID"`
+	Orders       []Order        `json:"orders,omitempty" gorm:"foreignKey:POSSessionID"`
+	Payments     []OrderPayment `json:"payments,omitempty" gorm:"foreignKey:POSSessionID"`
+	CashCounts   []CashCount    `json:"cash_counts,omitempty" gorm:"foreignKey:POSSessionID"`
 }
 
 func (POSSession) TableName() string {
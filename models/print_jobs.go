@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PrintJob - Trabajo en la cola de impresión (flujo ESC/POS ya renderizado)
+type PrintJob struct {
+	gorm.Model
+	Type            string     `json:"type" gorm:"size:30;not null"` // kitchen_ticket, receipt, session_report
+	KitchenTicketID *uint      `json:"kitchen_ticket_id" gorm:"index"`
+	OrderID         *uint      `json:"order_id" gorm:"index"`
+	PrinterAddress  string     `json:"printer_address" gorm:"size:100;not null"` // IP:puerto (9100 por defecto)
+	Data            []byte     `json:"-" gorm:"type:bytea;not null"`
+	State           string     `json:"state" gorm:"size:20;default:'pending';not null;index"` // pending, printed, failed
+	Attempts        int        `json:"attempts" gorm:"default:0;not null"`
+	LastError       string     `json:"last_error" gorm:"size:500"`
+	NextAttemptAt   time.Time  `json:"next_attempt_at" gorm:"not null"`
+	PrintedAt       *time.Time `json:"printed_at"`
+	Reprint         bool       `json:"reprint" gorm:"default:false;not null"`
+	RequestedBy     *uint      `json:"requested_by"`
+
+	// Relaciones
+	KitchenTicket *KitchenTicket `json:"kitchen_ticket,omitempty" gorm:"foreignKey:KitchenTicketID"`
+	Order         *Order         `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+}
+
+func (PrintJob) TableName() string {
+	return "print_jobs"
+}
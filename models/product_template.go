@@ -28,6 +28,7 @@ type ProductTemplate struct {
 	Unit              *Unit              `json:"unit,omitempty" gorm:"foreignKey:UnitID"`
 	KitchenStation    *KitchenStation    `json:"kitchen_station,omitempty" gorm:"foreignKey:KitchenStationID"`
 	Variants          []ProductProduct   `json:"variants,omitempty" gorm:"foreignKey:TemplateID"`
+	Taxes             []Tax              `json:"taxes,omitempty" gorm:"many2many:product_template_taxes;"` // Impuestos por defecto de las líneas de venta
 }
 
 func (ProductTemplate) TableName() string {
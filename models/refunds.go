@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Refund - Devolución / nota de crédito de una orden cerrada
+type Refund struct {
+	gorm.Model
+	OrderID         uint      `json:"order_id" gorm:"not null;index"`
+	JournalID       uint      `json:"journal_id" gorm:"not null"`    // Diario que numera la nota de crédito
+	Name            string    `json:"name" gorm:"size:100;not null"` // NC/00001
+	RefundDate      time.Time `json:"refund_date" gorm:"type:date;not null"`
+	Reason          string    `json:"reason" gorm:"type:text"`
+	PaymentMethodID uint      `json:"payment_method_id" gorm:"not null"` // Medio con el que se devuelve el dinero
+	POSSessionID    *uint     `json:"pos_session_id"`                    // Sesión de caja que registró la devolución (de ella sale el efectivo)
+	CreatedBy       *uint     `json:"created_by"`
+
+	AmountUntaxed float64 `json:"amount_untaxed" gorm:"type:decimal(10,2);default:0;not null"`
+	AmountTax     float64 `json:"amount_tax" gorm:"type:decimal(10,2);default:0;not null"`
+	TotalAmount   float64 `json:"total_amount" gorm:"type:decimal(10,2);default:0;not null"`
+
+	// Relaciones
+	Order         *Order         `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	Journal       *Journal       `json:"journal,omitempty" gorm:"foreignKey:JournalID"`
+	PaymentMethod *PaymentMethod `json:"payment_method,omitempty" gorm:"foreignKey:PaymentMethodID"`
+	POSSession    *POSSession    `json:"pos_session,omitempty" gorm:"foreignKey:POSSessionID"`
+	CreatedByUser *User          `json:"created_by_user,omitempty" gorm:"foreignKey:CreatedBy"`
+	Items         []RefundItem   `json:"items,omitempty" gorm:"foreignKey:RefundID"`
+	TaxLines      []RefundTax    `json:"tax_lines,omitempty" gorm:"foreignKey:RefundID"`
+	Payments      []OrderPayment `json:"payments,omitempty" gorm:"foreignKey:RefundID"`
+}
+
+func (Refund) TableName() string {
+	return "refunds"
+}
+
+// RefundItem - Línea devuelta (total o parcial) de una línea de la orden original
+type RefundItem struct {
+	gorm.Model
+	RefundID      uint    `json:"refund_id" gorm:"not null;index"`
+	OrderItemID   uint    `json:"order_item_id" gorm:"not null;index"`
+	ProductID     uint    `json:"product_id" gorm:"not null"`
+	Quantity      float64 `json:"quantity" gorm:"type:decimal(10,2);not null"`
+	PriceUnit     float64 `json:"price_unit" gorm:"type:decimal(10,2);not null"` // Precio de la venta original
+	PriceSubtotal float64 `json:"price_subtotal" gorm:"type:decimal(10,2);not null"`
+	PriceTax      float64 `json:"price_tax" gorm:"type:decimal(10,2);default:0;not null"`
+	PriceTotal    float64 `json:"price_total" gorm:"type:decimal(10,2);default:0;not null"`
+	Waste         bool    `json:"waste" gorm:"default:false;not null"` // No vuelve al stock, se da de baja como merma
+
+	// Relaciones
+	Refund    *Refund         `json:"refund,omitempty" gorm:"foreignKey:RefundID"`
+	OrderItem *OrderItem      `json:"order_item,omitempty" gorm:"foreignKey:OrderItemID"`
+	Product   *ProductProduct `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+}
+
+func (RefundItem) TableName() string {
+	return "refund_items"
+}
+
+// RefundTax - Desglose de impuestos devueltos (mismas tasas que la venta original)
+type RefundTax struct {
+	gorm.Model
+	RefundID         uint    `json:"refund_id" gorm:"not null;index"`
+	TaxID            uint    `json:"tax_id" gorm:"not null"`
+	Name             string  `json:"name" gorm:"size:255;not null"`
+	RatePercent      float64 `json:"rate_percent" gorm:"type:decimal(5,2);not null"`
+	IsPriceInclusive bool    `json:"is_price_inclusive" gorm:"default:false;not null"`
+	Base             float64 `json:"base" gorm:"type:decimal(10,2);not null"`
+	Amount           float64 `json:"amount" gorm:"type:decimal(10,2);not null"`
+
+	// Relaciones
+	Refund *Refund `json:"refund,omitempty" gorm:"foreignKey:RefundID"`
+	Tax    *Tax    `json:"tax,omitempty" gorm:"foreignKey:TaxID"`
+}
+
+func (RefundTax) TableName() string {
+	return "refund_taxes"
+}
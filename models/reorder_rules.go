@@ -0,0 +1,24 @@
+package models
+
+import "gorm.io/gorm"
+
+// ReorderRule - Reglas de reabastecimiento (stock mínimo/máximo) por producto y almacén
+type ReorderRule struct {
+	gorm.Model
+	ProductID       uint    `json:"product_id" gorm:"not null;uniqueIndex:idx_reorder_rule_product_warehouse"` // FK a product_product
+	WarehouseID     uint    `json:"warehouse_id" gorm:"not null;uniqueIndex:idx_reorder_rule_product_warehouse"`
+	MinQuantity     float64 `json:"min_quantity" gorm:"type:decimal(10,4);default:0;not null" binding:"gte=0"`
+	MaxQuantity     float64 `json:"max_quantity" gorm:"type:decimal(10,4);default:0;not null" binding:"gtefield=MinQuantity"`
+	ReorderMultiple float64 `json:"reorder_multiple" gorm:"type:decimal(10,4);default:1;not null" binding:"omitempty,gt=0"` // Se pide en múltiplos de (ej: cajas de 12)
+	PartnerID       *uint   `json:"partner_id"`                                                                             // Proveedor preferido
+	IsActive        bool    `json:"is_active" gorm:"default:true;not null"`
+
+	// Relaciones
+	Product   *ProductProduct `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Warehouse *Warehouse      `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`
+	Partner   *Partner        `json:"partner,omitempty" gorm:"foreignKey:PartnerID"`
+}
+
+func (ReorderRule) TableName() string {
+	return "reorder_rules"
+}
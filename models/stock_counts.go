@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StockCount - Toma de inventario físico de un almacén
+type StockCount struct {
+	gorm.Model
+	WarehouseID         uint       `json:"warehouse_id" gorm:"not null"`
+	Name                string     `json:"name" gorm:"size:100;not null" binding:"required"`
+	State               string     `json:"state" gorm:"size:50;default:'draft';not null"` // draft, counting, review, validated, cancelled
+	InventoryCategoryID *uint      `json:"inventory_category_id"`                         // Limita el conteo a una categoría (opcional)
+	StartedAt           *time.Time `json:"started_at"`                                    // Momento del snapshot del Kardex
+	ValidatedAt         *time.Time `json:"validated_at"`
+	CreatedBy           *uint      `json:"created_by"`
+	ValidatedBy         *uint      `json:"validated_by"`
+	Notes               string     `json:"notes" gorm:"type:text"`
+
+	// Relaciones
+	Warehouse         *Warehouse         `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`
+	InventoryCategory *InventoryCategory `json:"inventory_category,omitempty" gorm:"foreignKey:InventoryCategoryID"`
+	CreatedByUser     *User              `json:"created_by_user,omitempty" gorm:"foreignKey:CreatedBy"`
+	ValidatedByUser   *User              `json:"validated_by_user,omitempty" gorm:"foreignKey:ValidatedBy"`
+	Lines             []StockCountLine   `json:"lines,omitempty" gorm:"foreignKey:StockCountID"`
+}
+
+func (StockCount) TableName() string {
+	return "stock_counts"
+}
+
+// StockCountLine - Producto contado: saldo esperado (snapshot) vs cantidad contada
+type StockCountLine struct {
+	gorm.Model
+	StockCountID     uint     `json:"stock_count_id" gorm:"not null;uniqueIndex:idx_stock_count_line_product"`
+	ProductID        uint     `json:"product_id" gorm:"not null;uniqueIndex:idx_stock_count_line_product"`
+	ExpectedQuantity float64  `json:"expected_quantity" gorm:"type:decimal(10,4);default:0;not null"`
+	CountedQuantity  *float64 `json:"counted_quantity" gorm:"type:decimal(10,4)"` // null = no contado
+	UnitCost         float64  `json:"unit_cost" gorm:"type:decimal(12,4);default:0;not null"`
+	AdjustmentID     *uint    `json:"adjustment_id"` // Ajuste generado al validar
+
+	// Relaciones
+	StockCount *StockCount          `json:"stock_count,omitempty" gorm:"foreignKey:StockCountID"`
+	Product    *ProductProduct      `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Adjustment *InventoryAdjustment `json:"adjustment,omitempty" gorm:"foreignKey:AdjustmentID"`
+	Entries    []StockCountEntry    `json:"entries,omitempty" gorm:"foreignKey:StockCountLineID"`
+}
+
+func (StockCountLine) TableName() string {
+	return "stock_count_lines"
+}
+
+// StockCountEntry - Registro individual de conteo (permite conteos parciales y recuentos de varios usuarios)
+type StockCountEntry struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	StockCountLineID uint      `json:"stock_count_line_id" gorm:"not null;index"`
+	UserID           *uint     `json:"user_id"`
+	Quantity         float64   `json:"quantity" gorm:"type:decimal(10,4);not null"`
+	Mode             string    `json:"mode" gorm:"size:10;default:'add';not null"` // add (suma al conteo), set (recuento, reemplaza)
+	CreatedAt        time.Time `json:"created_at"`
+
+	// Relaciones
+	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+func (StockCountEntry) TableName() string {
+	return "stock_count_entries"
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// StockLayer - Capas de costo FIFO: cada entrada abre una capa y las salidas consumen las más antiguas
+type StockLayer struct {
+	ID                uint    `json:"id" gorm:"primaryKey"`
+	ProductID         uint    `json:"product_id" gorm:"not null;index:idx_stock_layer_product_warehouse"`
+	WarehouseID       uint    `json:"warehouse_id" gorm:"not null;index:idx_stock_layer_product_warehouse"`
+	InventoryID       uint    `json:"inventory_id" gorm:"not null"` // Movimiento de entrada que abrió la capa
+	Quantity          float64 `json:"quantity" gorm:"type:decimal(10,4);not null"`
+	RemainingQuantity float64 `json:"remaining_quantity" gorm:"type:decimal(10,4);not null"`
+	UnitCost          float64 `json:"unit_cost" gorm:"type:decimal(12,4);not null"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relaciones
+	Product   *ProductProduct `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Warehouse *Warehouse      `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`
+	Inventory *Inventory      `json:"inventory,omitempty" gorm:"foreignKey:InventoryID"`
+}
+
+func (StockLayer) TableName() string {
+	return "stock_layers"
+}
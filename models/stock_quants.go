@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// StockQuant - Saldo actual por producto y almacén. Se actualiza en la misma transacción que cada
+// movimiento del Kardex, así las consultas de stock no necesitan recorrer el historial.
+type StockQuant struct {
+	ID                uint    `json:"id" gorm:"primaryKey"`
+	ProductID         uint    `json:"product_id" gorm:"not null;uniqueIndex:idx_stock_quant_product_warehouse"`
+	WarehouseID       uint    `json:"warehouse_id" gorm:"not null;uniqueIndex:idx_stock_quant_product_warehouse"`
+	Quantity          float64 `json:"quantity" gorm:"type:decimal(10,4);default:0;not null"`          // En mano
+	ReservedQuantity  float64 `json:"reserved_quantity" gorm:"type:decimal(10,4);default:0;not null"` // Comprometido (transferencias en tránsito)
+	AvailableQuantity float64 `json:"available_quantity" gorm:"type:decimal(10,4);default:0;not null"`
+	UnitCost          float64 `json:"unit_cost" gorm:"type:decimal(12,4);default:0;not null"`
+	Value             float64 `json:"value" gorm:"type:decimal(12,2);default:0;not null"`
+	LastInventoryID   *uint   `json:"last_inventory_id"` // Último movimiento del Kardex aplicado
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relaciones
+	Product   *ProductProduct `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Warehouse *Warehouse      `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`
+}
+
+func (StockQuant) TableName() string {
+	return "stock_quants"
+}
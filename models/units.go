@@ -1,11 +1,33 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
 
 type Unit struct {
 	gorm.Model
-	Name         string `json:"name" gorm:"not null" binding:"required,min=2,max=100"`
-	Abbreviation string `json:"abbreviation" gorm:"not null" binding:"required,min=1,max=10"`
-	Type         string `json:"type" gorm:"not null" binding:"required,oneof=weight volume unit length area"`
-	IsActive     bool   `json:"is_active" gorm:"default:true"`
+	Name         string  `json:"name" gorm:"not null" binding:"required,min=2,max=100"`
+	Abbreviation string  `json:"abbreviation" gorm:"not null" binding:"required,min=1,max=10"`
+	Type         string  `json:"type" gorm:"not null" binding:"required,oneof=weight volume unit length area"`
+	Factor       float64 `json:"factor" gorm:"type:decimal(12,6);default:1;not null" binding:"omitempty,gt=0"` // Equivalencia respecto a la unidad base de su tipo (kg=1, g=0.001)
+	IsActive     bool    `json:"is_active" gorm:"default:true"`
+}
+
+// ConvertTo convierte una cantidad expresada en esta unidad a la unidad destino
+func (u *Unit) ConvertTo(quantity float64, to *Unit) (float64, error) {
+	if u == nil || to == nil || u.ID == to.ID {
+		return quantity, nil
+	}
+
+	if u.Type != to.Type {
+		return 0, fmt.Errorf("cannot convert from %s (%s) to %s (%s)", u.Abbreviation, u.Type, to.Abbreviation, to.Type)
+	}
+
+	if u.Factor <= 0 || to.Factor <= 0 {
+		return 0, fmt.Errorf("invalid conversion factor between %s and %s", u.Abbreviation, to.Abbreviation)
+	}
+
+	return quantity * u.Factor / to.Factor, nil
 }
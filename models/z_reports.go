@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// ZReport - Reporte Z de cierre de una sesión de caja. Se genera al cerrar la sesión, se numera
+// por terminal y no se modifica: el contenido completo queda congelado en Payload.
+type ZReport struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	POSID        uint      `json:"pos_id" gorm:"not null;uniqueIndex:idx_z_reports_pos_sequence"`
+	POSSessionID uint      `json:"pos_session_id" gorm:"not null;uniqueIndex"`
+	Sequence     int       `json:"sequence" gorm:"not null;uniqueIndex:idx_z_reports_pos_sequence"` // Correlativo del terminal
+	Name         string    `json:"name" gorm:"size:50;not null"`                                    // Z-0001
+	TicketCount  int       `json:"ticket_count" gorm:"not null"`
+	TotalSales   float64   `json:"total_sales" gorm:"type:decimal(12,2);not null"`
+	TotalRefunds float64   `json:"total_refunds" gorm:"type:decimal(12,2);not null"`
+	Payload      string    `json:"-" gorm:"type:text;not null"` // Reporte completo en JSON (se expone decodificado)
+	GeneratedBy  *uint     `json:"generated_by"`
+	CreatedAt    time.Time `json:"created_at" gorm:"default:CURRENT_TIMESTAMP"`
+
+	// Relaciones
+	POS        *POS        `json:"pos,omitempty" gorm:"foreignKey:POSID"`
+	POSSession *POSSession `json:"pos_session,omitempty" gorm:"foreignKey:POSSessionID"`
+}
+
+func (ZReport) TableName() string {
+	return "z_reports"
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"b-resto/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupCurrencyRoutes configura las rutas de monedas y tipos de cambio
+func SetupCurrencyRoutes(router *gin.RouterGroup) {
+	currencies := router.Group("/currencies")
+	{
+		currencies.GET("", controllers.GetCurrencies)
+		currencies.POST("", controllers.CreateCurrency)
+		currencies.PUT("/:id", controllers.UpdateCurrency)
+		currencies.PATCH("/:id/toggle", controllers.ToggleCurrencyStatus)
+	}
+
+	rates := router.Group("/exchange-rates")
+	{
+		rates.GET("", controllers.GetExchangeRates)
+		rates.GET("/current", controllers.GetCurrentExchangeRate)
+		rates.POST("", controllers.SetExchangeRate)
+		rates.DELETE("/:id", controllers.DeleteExchangeRate)
+	}
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"b-resto/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupInventoryAdjustmentRoutes configura las rutas para ajustes de inventario
+func SetupInventoryAdjustmentRoutes(router *gin.RouterGroup) {
+	router.GET("/inventory-adjustments", controllers.GetInventoryAdjustments)
+	router.GET("/inventory-adjustments/:id", controllers.GetInventoryAdjustment)
+	router.PATCH("/inventory-adjustments/:id/approve", controllers.ApproveInventoryAdjustment)
+	router.PATCH("/inventory-adjustments/:id/reject", controllers.RejectInventoryAdjustment)
+}
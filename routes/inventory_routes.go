@@ -7,13 +7,14 @@ import (
 )
 
 // SetupInventoryRoutes configura las rutas para inventories
-func SetupInventoryRoutes(r *gin.Engine) {
-	api := r.Group("/api")
-	{
-		api.GET("/inventories", controllers.GetInventories)
-		api.GET("/inventories/:id", controllers.GetInventory)
-		api.GET("/inventories/warehouse/:warehouse_id/product/:product_id", controllers.GetInventoryByWarehouseAndProduct)
-		api.POST("/inventories/adjust", controllers.AdjustInventory)
-		api.GET("/inventories/low-stock", controllers.GetLowStockProducts)
-	}
+func SetupInventoryRoutes(router *gin.RouterGroup) {
+	router.GET("/inventories", controllers.GetInventories)
+	router.GET("/inventories/stock", controllers.GetStock)
+	router.GET("/inventories/valuation", controllers.GetInventoryValuation)
+	router.GET("/inventories/layers", controllers.GetStockLayers)
+	router.GET("/inventories/:id", controllers.GetInventory)
+	router.GET("/inventories/warehouse/:warehouse_id/product/:product_id", controllers.GetInventoryByWarehouseAndProduct)
+	router.POST("/inventories/adjust", controllers.AdjustInventory)
+	router.GET("/inventories/low-stock", controllers.GetLowStockProducts)
+	router.POST("/inventories/low-stock/purchase-orders", controllers.ProposeReorderPurchaseOrders)
 }
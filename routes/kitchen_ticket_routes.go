@@ -17,5 +17,6 @@ func SetupKitchenTicketRoutes(r *gin.Engine) {
 		api.PATCH("/kitchen-tickets/:id/ready", controllers.MarkTicketReady)
 		api.PATCH("/kitchen-tickets/:id/delivered", controllers.MarkTicketDelivered)
 		api.GET("/kitchen-tickets/station/:station_id", controllers.GetTicketsByStation)
+		api.GET("/kitchen-tickets/station/:station_id/stream", controllers.StreamKitchenStation)
 	}
 }
@@ -7,25 +7,40 @@ import (
 )
 
 // SetupOrderRoutes configura las rutas para orders
-func SetupOrderRoutes(r *gin.Engine) {
-	api := r.Group("/api")
-	{
-		api.GET("/orders", controllers.GetOrders)
-		api.POST("/orders", controllers.CreateOrder)
-
-		// Rutas específicas ANTES de las dinámicas con :id
-		api.GET("/orders/table/:table_id", controllers.GetOrdersByTable)
-
-		// Rutas dinámicas con :id
-		api.GET("/orders/:id", controllers.GetOrder)
-		api.PUT("/orders/:id", controllers.UpdateOrder)
-		api.PATCH("/orders/:id/confirm", controllers.ConfirmOrder)
-		api.PATCH("/orders/:id/cancel", controllers.CancelOrder)
-		api.PATCH("/orders/:id/complete", controllers.CompleteOrder)
-
-		// Pagos de orden - usar :id consistentemente
-		api.GET("/orders/:id/payments", controllers.GetOrderPayments)
-		api.POST("/orders/:id/payments", controllers.CreateOrderPayment)
-		api.DELETE("/orders/:id/payments/:payment_id", controllers.DeleteOrderPayment)
-	}
+func SetupOrderRoutes(router *gin.RouterGroup) {
+	router.GET("/orders", controllers.GetOrders)
+	router.POST("/orders", controllers.CreateOrder)
+
+	// Rutas específicas ANTES de las dinámicas con :id
+	router.GET("/orders/table/:table_id", controllers.GetOrdersByTable)
+
+	// Rutas dinámicas con :id
+	router.GET("/orders/:id", controllers.GetOrder)
+	router.PUT("/orders/:id", controllers.UpdateOrder)
+	router.PATCH("/orders/:id/confirm", controllers.ConfirmOrder)
+	router.PATCH("/orders/:id/cancel", controllers.CancelOrder)
+	router.PATCH("/orders/:id/complete", controllers.CompleteOrder)
+
+	// Mesas: cambiar, unir órdenes y pasar líneas
+	router.PATCH("/orders/:id/move", controllers.MoveOrder)
+	router.POST("/orders/:id/merge", controllers.MergeOrder)
+	router.POST("/orders/:id/items/transfer", controllers.TransferOrderItems)
+
+	// Líneas de orden
+	router.POST("/orders/:id/items", controllers.AddOrderItem)
+	router.PATCH("/orders/:id/items/:item_id", controllers.UpdateOrderItem)
+	router.PATCH("/orders/:id/items/:item_id/void", controllers.VoidOrderItem)
+	router.POST("/orders/:id/items/:item_id/split", controllers.SplitOrderItem)
+
+	// Subcuentas (división de la cuenta)
+	router.GET("/orders/:id/checks", controllers.GetOrderChecks)
+	router.POST("/orders/:id/checks", controllers.SplitOrderChecks)
+	router.DELETE("/orders/:id/checks", controllers.DeleteOrderChecks)
+	router.POST("/orders/:id/checks/:check_id/payments", controllers.CreateOrderCheckPayment)
+	router.PATCH("/orders/:id/checks/:check_id/close", controllers.CloseOrderCheck)
+
+	// Pagos de orden - usar :id consistentemente
+	router.GET("/orders/:id/payments", controllers.GetOrderPayments)
+	router.POST("/orders/:id/payments", controllers.CreateOrderPayment)
+	router.DELETE("/orders/:id/payments/:payment_id", controllers.DeleteOrderPayment)
 }
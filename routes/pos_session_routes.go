@@ -6,20 +6,25 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// SetupPOSSessionRoutes configura las rutas para pos sessions
-func SetupPOSSessionRoutes(r *gin.Engine) {
-	api := r.Group("/api")
-	{
-		api.GET("/pos-sessions", controllers.GetPOSSessions)
-		api.POST("/pos-sessions/open", controllers.OpenPOSSession)
-		api.GET("/pos-sessions/active", controllers.GetActivePOSSessions)
+// SetupPOSSessionRoutes configura las rutas para pos sessions (protegidas: la apertura, el cierre y
+// el reconteo se atribuyen al usuario autenticado)
+func SetupPOSSessionRoutes(router *gin.RouterGroup) {
+	router.GET("/pos-sessions", controllers.GetPOSSessions)
+	router.POST("/pos-sessions/open", controllers.OpenPOSSession)
+	router.GET("/pos-sessions/active", controllers.GetActivePOSSessions)
 
-		// Rutas dinámicas
-		api.GET("/pos-sessions/:id", controllers.GetPOSSession)
-		api.PATCH("/pos-sessions/:id/close", controllers.ClosePOSSession)
-		api.GET("/pos-sessions/:id/movements", controllers.GetSessionMovements)
-		api.GET("/pos-sessions/:id/cash-movements", controllers.GetCashMovements)
-		api.POST("/pos-sessions/:id/cash-movements", controllers.CreateCashMovement)
-		api.DELETE("/pos-sessions/:id/cash-movements/:movement_id", controllers.DeleteCashMovement)
-	}
+	// Rutas dinámicas
+	router.GET("/pos-sessions/:id", controllers.GetPOSSession)
+	router.PATCH("/pos-sessions/:id/close", controllers.ClosePOSSession)
+	router.POST("/pos-sessions/:id/recount", controllers.RecountPOSSession)
+	router.GET("/pos-sessions/:id/cash", controllers.GetSessionCash)
+	router.GET("/pos-sessions/:id/cash-counts", controllers.GetSessionCashCounts)
+	router.GET("/pos-sessions/:id/movements", controllers.GetSessionMovements)
+	router.GET("/pos-sessions/:id/cash-movements", controllers.GetCashMovements)
+	router.POST("/pos-sessions/:id/cash-movements", controllers.CreateCashMovement)
+	router.DELETE("/pos-sessions/:id/cash-movements/:movement_id", controllers.DeleteCashMovement)
+	router.GET("/pos-sessions/:id/x-report", controllers.GetSessionXReport)
+	router.GET("/pos-sessions/:id/z-report", controllers.GetSessionZReport)
+	router.POST("/pos-sessions/:id/reports/print", controllers.PrintSessionReport)
+	router.GET("/z-reports", controllers.GetZReports)
 }
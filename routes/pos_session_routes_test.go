@@ -0,0 +1,146 @@
+package routes
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/testutil"
+	"b-resto/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRecountClosesSessionAboveThreshold cubre el cierre ciego con reconteo: la diferencia del
+// cajero supera el umbral, la sesión queda en closing y solo un supervisor distinto la cierra.
+func TestRecountClosesSessionAboveThreshold(t *testing.T) {
+	db := testutil.OpenDB(t)
+	t.Setenv("ENVIRONMENT", "development")
+	gin.SetMode(gin.TestMode)
+
+	company := models.Company{Name: "Frontera", BusinessName: "Frontera SAC"}
+	if err := db.Create(&company).Error; err != nil {
+		t.Fatal(err)
+	}
+	pos := models.POS{CompanyID: company.ID, Code: "CAJA-1", Name: "Caja 1", BlindClose: true, RecountThreshold: 5, IsActive: true}
+	if err := db.Create(&pos).Error; err != nil {
+		t.Fatal(err)
+	}
+	cashier := models.User{Username: "cajero", Email: "cajero@b-resto.test", Password: "secret", Role: models.UserRole}
+	supervisor := models.User{Username: "supervisor", Email: "supervisor@b-resto.test", Password: "secret", Role: models.AdminRole}
+	for _, user := range []*models.User{&cashier, &supervisor} {
+		if err := db.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := gin.New()
+	SetupRoutes(router)
+
+	request := func(user models.User, method, path string, body interface{}) (int, map[string]interface{}) {
+		t.Helper()
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		token, err := utils.GenerateToken(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		var response map[string]interface{}
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		return recorder.Code, response
+	}
+
+	// opened_by en el cuerpo no cambia quién abre la sesión
+	status, response := request(cashier, http.MethodPost, "/api/pos-sessions/open", gin.H{"pos_id": pos.ID, "opening_balance": 100, "opened_by": supervisor.ID})
+	if status != http.StatusCreated {
+		t.Fatalf("open session: %d %v", status, response)
+	}
+	data := response["data"].(map[string]interface{})
+	if openedBy := uint(data["opened_by"].(float64)); openedBy != cashier.ID {
+		t.Fatalf("session opened_by = %d, want the cashier %d", openedBy, cashier.ID)
+	}
+	sessionID := uint(data["ID"].(float64))
+	sessionPath := fmt.Sprintf("/api/pos-sessions/%d", sessionID)
+
+	// Los cobros por medio delatarían el esperado: el cajero no los ve durante el cierre ciego
+	status, response = request(cashier, http.MethodGet, sessionPath+"?include=totals", nil)
+	if totals, _ := response["totals"].(map[string]interface{}); status != http.StatusOK || totals == nil || totals["payments"] != nil || totals["blind"] != true {
+		t.Fatalf("cashier session totals: %d %v", status, response)
+	}
+	status, response = request(supervisor, http.MethodGet, sessionPath+"?include=totals", nil)
+	if totals, _ := response["totals"].(map[string]interface{}); status != http.StatusOK || totals == nil || totals["payments"] == nil {
+		t.Fatalf("supervisor session totals: %d %v", status, response)
+	}
+
+	// El cajero cuenta 80 de 100 esperados: faltan 20, más que el umbral de 5
+	status, response = request(cashier, http.MethodPatch, sessionPath+"/close", gin.H{
+		"denominations": []gin.H{{"type": "bill", "value": 20, "quantity": 4}},
+	})
+	if status != http.StatusAccepted || response["recount_required"] != true {
+		t.Fatalf("close session: %d %v", status, response)
+	}
+	if count := response["count"].(map[string]interface{}); count["expected"] != nil || count["difference"] != nil {
+		t.Fatalf("blind close exposed the expected cash: %v", count)
+	}
+
+	if status, _ = request(cashier, http.MethodGet, sessionPath+"/cash", nil); status != http.StatusForbidden {
+		t.Fatalf("cashier saw the expected cash during a blind close: %d", status)
+	}
+	if status, _ = request(supervisor, http.MethodGet, sessionPath+"/cash", nil); status != http.StatusOK {
+		t.Fatalf("supervisor could not see the expected cash: %d", status)
+	}
+
+	// Ni el propio cajero ni un usuario sin rol de supervisor pueden recontar
+	if status, _ = request(cashier, http.MethodPost, sessionPath+"/recount", gin.H{"amount": 100}); status != http.StatusBadRequest {
+		t.Fatalf("cashier recount: %d", status)
+	}
+
+	status, response = request(supervisor, http.MethodPost, sessionPath+"/recount", gin.H{
+		"denominations": []gin.H{{"type": "bill", "value": 50, "quantity": 2}},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("supervisor recount: %d %v", status, response)
+	}
+	if response["z_report"] == nil {
+		t.Fatalf("recount did not generate the Z report: %v", response)
+	}
+
+	var session models.POSSession
+	if err := config.DB.First(&session, sessionID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if session.Status != "closed" {
+		t.Fatalf("session status = %s, want closed", session.Status)
+	}
+	if session.ClosedBy == nil || *session.ClosedBy != supervisor.ID {
+		t.Fatalf("session closed_by = %v, want supervisor %d", session.ClosedBy, supervisor.ID)
+	}
+	if session.Difference == nil || *session.Difference != 0 {
+		t.Fatalf("session difference = %v, want 0", session.Difference)
+	}
+
+	var counts []models.CashCount
+	if err := config.DB.Where("pos_session_id = ?", sessionID).Order("id asc").Find(&counts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 3 || counts[1].Kind != "closing" || counts[2].Kind != "recount" {
+		t.Fatalf("cash counts = %+v, want opening, closing and recount", counts)
+	}
+	if counts[1].CountedBy == nil || *counts[1].CountedBy != cashier.ID {
+		t.Fatalf("closing count not attributed to the cashier: %v", counts[1].CountedBy)
+	}
+
+	// El terminal puede abrir una nueva sesión
+	if status, response = request(cashier, http.MethodPost, "/api/pos-sessions/open", gin.H{"pos_id": pos.ID, "opening_balance": 100}); status != http.StatusCreated {
+		t.Fatalf("reopen terminal: %d %v", status, response)
+	}
+}
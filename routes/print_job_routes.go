@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"b-resto/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPrintJobRoutes configura las rutas de la cola de impresión y las reimpresiones
+func SetupPrintJobRoutes(router *gin.RouterGroup) {
+	router.GET("/print-jobs", controllers.GetPrintJobs)
+	router.POST("/print-jobs/:id/retry", controllers.RetryPrintJob)
+	router.POST("/kitchen-tickets/:id/reprint", controllers.ReprintKitchenTicket)
+	router.POST("/orders/:id/receipt", controllers.PrintOrderReceipt)
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"b-resto/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupRefundRoutes configura las rutas para devoluciones (notas de crédito)
+func SetupRefundRoutes(router *gin.RouterGroup) {
+	router.GET("/refunds", controllers.GetRefunds)
+	router.GET("/refunds/:id", controllers.GetRefund)
+	router.POST("/orders/:id/refunds", controllers.CreateOrderRefund)
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"b-resto/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupReorderRuleRoutes configura las rutas para reglas de reabastecimiento
+func SetupReorderRuleRoutes(router *gin.RouterGroup) {
+	router.GET("/reorder-rules", controllers.GetReorderRules)
+	router.GET("/reorder-rules/:id", controllers.GetReorderRule)
+	router.POST("/reorder-rules", controllers.CreateReorderRule)
+	router.PUT("/reorder-rules/:id", controllers.UpdateReorderRule)
+	router.DELETE("/reorder-rules/:id", controllers.DeleteReorderRule)
+}
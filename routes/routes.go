@@ -44,6 +44,8 @@ func SetupRoutes(r *gin.Engine) {
 	if config.GetEnvironment() != "development" {
 		api.Use(middlewares.AuthMiddleware())
 		api.Use(middlewares.CasbinMiddleware())
+	} else {
+		api.Use(middlewares.OptionalAuthMiddleware())
 	}
 
 	{
@@ -68,6 +70,7 @@ func SetupRoutes(r *gin.Engine) {
 			admin.POST("/resources", controllers.CreateResource)
 			admin.DELETE("/resources/:id", controllers.DeleteResource)
 			admin.GET("/users", controllers.GetUsers)
+			admin.POST("/inventories/recompute", controllers.RecomputeKardex)
 		}
 
 		// Rutas API - endpoints
@@ -75,6 +78,7 @@ func SetupRoutes(r *gin.Engine) {
 		SetupCompanyRoutes(api)
 		SetupTaxesRoutes(api)
 		SetupPaymentMethodsRoutes(api)
+		SetupCurrencyRoutes(api)
 
 		// FASE 1: Catálogos Base
 		SetupWarehouseRoutes(r)
@@ -94,12 +98,14 @@ func SetupRoutes(r *gin.Engine) {
 		SetupReservationRoutes(r)
 
 		// FASE 7: Órdenes de Venta (CRÍTICO POS)
-		SetupOrderRoutes(r)
+		SetupOrderRoutes(api)
 		SetupKitchenTicketRoutes(r)
+		SetupRefundRoutes(api)
+		SetupPrintJobRoutes(api)
 
 		// FASE 8: POS y Caja
 		SetupPOSRoutes(r)
-		SetupPOSSessionRoutes(r)
+		SetupPOSSessionRoutes(api)
 
 		// FASE 9: Proveedores/Clientes
 		SetupPartnerRoutes(r)
@@ -109,7 +115,10 @@ func SetupRoutes(r *gin.Engine) {
 		SetupStockTransferRoutes(r)
 
 		// FASE 11: Inventario (Kardex)
-		SetupInventoryRoutes(r)
+		SetupInventoryRoutes(api)
+		SetupInventoryAdjustmentRoutes(api)
+		SetupStockCountRoutes(api)
+		SetupReorderRuleRoutes(api)
 
 		// FASE 3-4: Módulo de Productos (COMPLETO)
 		SetupProductRoutes(r)
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"b-resto/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupStockCountRoutes configura las rutas para tomas de inventario físico
+func SetupStockCountRoutes(router *gin.RouterGroup) {
+	router.GET("/stock-counts", controllers.GetStockCounts)
+	router.POST("/stock-counts", controllers.CreateStockCount)
+	router.GET("/stock-counts/:id", controllers.GetStockCount)
+	router.PATCH("/stock-counts/:id/start", controllers.StartStockCount)
+	router.POST("/stock-counts/:id/counts", controllers.RecordStockCounts)
+	router.PATCH("/stock-counts/:id/review", controllers.ReviewStockCount)
+	router.PATCH("/stock-counts/:id/validate", controllers.ValidateStockCount)
+	router.PATCH("/stock-counts/:id/cancel", controllers.CancelStockCount)
+	router.GET("/stock-counts/:id/variance", controllers.GetStockCountVariance)
+}
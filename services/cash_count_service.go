@@ -0,0 +1,104 @@
+package services
+
+import (
+	"b-resto/models"
+	"b-resto/utils"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Tipos de conteo de efectivo
+const (
+	CashCountOpening = "opening"
+	CashCountClosing = "closing"
+	CashCountRecount = "recount" // Segundo conteo del supervisor
+)
+
+// Denomination - Cantidad contada de un billete o moneda
+type Denomination struct {
+	Currency string  `json:"currency" binding:"omitempty,len=3"` // Vacío = moneda base
+	Type     string  `json:"type" binding:"required,oneof=bill coin"`
+	Value    float64 `json:"value" binding:"required,gt=0"`
+	Quantity int     `json:"quantity" binding:"gte=0"`
+}
+
+// CashCountInput - Efectivo contado: por denominaciones (el total lo calcula el servidor) o solo el total
+type CashCountInput struct {
+	Amount        *float64       `json:"amount" binding:"omitempty,gte=0"`
+	Denominations []Denomination `json:"denominations" binding:"omitempty,dive"`
+	Notes         string         `json:"notes"`
+}
+
+// IsEmpty indica que no se envió ningún conteo
+func (in CashCountInput) IsEmpty() bool {
+	return in.Amount == nil && len(in.Denominations) == 0
+}
+
+// newCashCount arma un conteo a partir de las denominaciones o del total enviado (en moneda base).
+// Con denominaciones, el total enviado se ignora y solo las líneas en moneda base suman al total;
+// las demás monedas se concilian por separado.
+func newCashCount(kind, base string, input CashCountInput, countedBy *uint) (*models.CashCount, error) {
+	if input.IsEmpty() {
+		return nil, errors.New("cash count requires an amount or a denomination breakdown")
+	}
+
+	count := &models.CashCount{
+		Kind:      kind,
+		CountedBy: countedBy,
+		Notes:     input.Notes,
+	}
+
+	if len(input.Denominations) == 0 {
+		count.Total = utils.Round(*input.Amount, 2)
+		return count, nil
+	}
+
+	seen := map[string]bool{}
+	for _, denomination := range input.Denominations {
+		currency := strings.ToUpper(denomination.Currency)
+		if currency == "" {
+			currency = base
+		}
+		key := fmt.Sprintf("%s/%s/%.2f", currency, denomination.Type, denomination.Value)
+		if seen[key] {
+			return nil, fmt.Errorf("denomination %s %s %.2f is repeated", currency, denomination.Type, denomination.Value)
+		}
+		seen[key] = true
+		if denomination.Quantity == 0 {
+			continue
+		}
+
+		amount := utils.Round(denomination.Value*float64(denomination.Quantity), 2)
+		count.Lines = append(count.Lines, models.CashCountLine{
+			Currency:     currency,
+			Type:         denomination.Type,
+			Denomination: denomination.Value,
+			Quantity:     denomination.Quantity,
+			Amount:       amount,
+		})
+		if currency == base {
+			count.Total += amount
+		}
+	}
+	count.Total = utils.Round(count.Total, 2)
+
+	return count, nil
+}
+
+// saveCashCount guarda el conteo de una sesión, con el esperado y la diferencia si se conocen
+func saveCashCount(tx *gorm.DB, sessionID uint, count *models.CashCount, expected *float64) error {
+	count.POSSessionID = sessionID
+	if expected != nil {
+		difference := utils.Round(count.Total-*expected, 2)
+		count.Expected = expected
+		count.Difference = &difference
+	}
+
+	if err := tx.Create(count).Error; err != nil {
+		return fmt.Errorf("failed to save cash count: %w", err)
+	}
+	return nil
+}
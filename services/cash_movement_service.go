@@ -0,0 +1,120 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrCashMovementNotFound indica que el movimiento no existe en la sesión indicada
+var ErrCashMovementNotFound = errors.New("cash movement not found in this session")
+
+// AddCashMovement registra un ingreso o egreso de efectivo en una sesión abierta. El efectivo en
+// otra moneda se envía con currency y tendered_amount; amount se calcula en moneda base con el
+// tipo de cambio del día.
+func (s *POSSessionService) AddCashMovement(movement *models.CashMovement) error {
+	if movement.Type != "in" && movement.Type != "out" {
+		return errors.New("cash movement type must be in or out")
+	}
+
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Con la caja ya contada (closing) o cerrada no se registran más movimientos
+	session, err := lockOpenSession(tx, movement.POSSessionID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	companyID, base, err := sessionCurrency(tx, session)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	currency := strings.ToUpper(movement.Currency)
+	if currency == "" {
+		currency = base
+	}
+
+	if movement.TenderedAmount <= 0 {
+		if currency != base {
+			tx.Rollback()
+			return fmt.Errorf("tendered_amount is required for cash movements in %s", currency)
+		}
+		movement.TenderedAmount = movement.Amount
+	}
+	rate, err := exchangeRate(tx, companyID, currency, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	movement.ID = 0
+	movement.Currency = currency
+	movement.TenderedAmount = utils.Round(movement.TenderedAmount, 2)
+	movement.ExchangeRate = rate
+	movement.Amount = utils.Round(movement.TenderedAmount*rate, 2)
+	if movement.Amount <= 0 {
+		tx.Rollback()
+		return errors.New("cash movement amount must be greater than zero")
+	}
+
+	if err := tx.Create(movement).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to create cash movement: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// DeleteCashMovement elimina un movimiento de una sesión abierta. El efectivo esperado sale de los
+// movimientos, así que una sesión contada o cerrada no los pierde; tampoco se elimina el pago en
+// efectivo de una devolución.
+func (s *POSSessionService) DeleteCashMovement(sessionID, movementID uint) error {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err := lockOpenSession(tx, sessionID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var movement models.CashMovement
+	if err := tx.Where("id = ? AND cash_register_id = ?", movementID, sessionID).First(&movement).Error; err != nil {
+		tx.Rollback()
+		return ErrCashMovementNotFound
+	}
+	if movement.RefundID != nil {
+		tx.Rollback()
+		return fmt.Errorf("cash movement pays out refund %d and cannot be deleted", *movement.RefundID)
+	}
+
+	if err := tx.Delete(&movement).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete cash movement: %w", err)
+	}
+
+	return tx.Commit().Error
+}
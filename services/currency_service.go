@@ -0,0 +1,209 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultCurrency es la moneda base de las compañías que no definen una
+const defaultCurrency = "PEN"
+
+// CurrencyService maneja las monedas y los tipos de cambio
+type CurrencyService struct{}
+
+// NewCurrencyService crea una nueva instancia del servicio
+func NewCurrencyService() *CurrencyService {
+	return &CurrencyService{}
+}
+
+// SetRate registra el tipo de cambio de una moneda para la compañía en la fecha indicada
+// (reemplaza el del mismo día si ya existe)
+func (s *CurrencyService) SetRate(rate *models.ExchangeRate) error {
+	rate.CurrencyCode = strings.ToUpper(rate.CurrencyCode)
+	rate.Date = dateOnly(rate.Date)
+	if rate.Rate <= 0 {
+		return errors.New("exchange rate must be greater than zero")
+	}
+
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	base, err := baseCurrency(tx, rate.CompanyID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if rate.CurrencyCode == base {
+		tx.Rollback()
+		return fmt.Errorf("%s is the base currency of the company", base)
+	}
+
+	var currency models.Currency
+	if err := tx.Where("code = ? AND is_active = ?", rate.CurrencyCode, true).First(&currency).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("currency %s not found or inactive", rate.CurrencyCode)
+	}
+
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "company_id"}, {Name: "currency_code"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at", "deleted_at"}),
+	}).Create(rate).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to save exchange rate: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// Rate devuelve el tipo de cambio vigente de una moneda para la compañía en la fecha (el último
+// registrado hasta ese día). La moneda base vale 1.
+func (s *CurrencyService) Rate(companyID uint, currency string, date time.Time) (float64, error) {
+	return exchangeRate(config.DB, companyID, currency, date)
+}
+
+// baseCurrency obtiene la moneda base de la compañía
+func baseCurrency(tx *gorm.DB, companyID uint) (string, error) {
+	var company models.Company
+	if err := tx.Select("id", "currency_code").First(&company, companyID).Error; err != nil {
+		return "", fmt.Errorf("company %d not found", companyID)
+	}
+	if company.CurrencyCode == "" {
+		return defaultCurrency, nil
+	}
+	return strings.ToUpper(company.CurrencyCode), nil
+}
+
+// sessionCurrency obtiene la compañía y la moneda base del terminal de la sesión
+func sessionCurrency(tx *gorm.DB, session *models.POSSession) (uint, string, error) {
+	companyID := uint(0)
+	if session.POS != nil {
+		companyID = session.POS.CompanyID
+	} else {
+		var pos models.POS
+		if err := tx.Select("id", "company_id").First(&pos, session.POSID).Error; err != nil {
+			return 0, "", fmt.Errorf("POS terminal %d not found", session.POSID)
+		}
+		companyID = pos.CompanyID
+	}
+
+	base, err := baseCurrency(tx, companyID)
+	if err != nil {
+		return 0, "", err
+	}
+	return companyID, base, nil
+}
+
+// exchangeRate busca el último tipo de cambio de la moneda registrado hasta la fecha
+func exchangeRate(tx *gorm.DB, companyID uint, currency string, date time.Time) (float64, error) {
+	currency = strings.ToUpper(currency)
+	base, err := baseCurrency(tx, companyID)
+	if err != nil {
+		return 0, err
+	}
+	if currency == "" || currency == base {
+		return 1, nil
+	}
+
+	var rate models.ExchangeRate
+	result := tx.Where("company_id = ? AND currency_code = ? AND date <= ?", companyID, currency, dateOnly(date)).
+		Order("date desc").Limit(1).Find(&rate)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to load exchange rate: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return 0, fmt.Errorf("there is no exchange rate for %s on %s", currency, dateOnly(date).Format("2006-01-02"))
+	}
+	return rate.Rate, nil
+}
+
+// applyTender convierte a moneda base lo entregado en un pago. Sin monto entregado, el pago es en
+// moneda base por su importe. En efectivo, lo entregado que excede lo pendiente (due) o el importe
+// indicado se devuelve como vuelto en moneda base; los demás medios no admiten vuelto.
+func applyTender(tx *gorm.DB, payment *models.OrderPayment, session *models.POSSession, due float64) error {
+	companyID, base, err := sessionCurrency(tx, session)
+	if err != nil {
+		return err
+	}
+
+	currency := strings.ToUpper(payment.Currency)
+	if currency == "" {
+		currency = base
+	}
+
+	if payment.TenderedAmount <= 0 {
+		if currency != base {
+			return fmt.Errorf("tendered_amount is required for payments in %s", currency)
+		}
+		if payment.Amount <= 0 {
+			return errors.New("payment amount must be greater than zero")
+		}
+		payment.Currency = base
+		payment.Amount = utils.Round(payment.Amount, 2)
+		payment.TenderedAmount = payment.Amount
+		payment.ExchangeRate = 1
+		payment.ChangeAmount = 0
+		return nil
+	}
+
+	rate, err := exchangeRate(tx, companyID, currency, payment.PaymentDate)
+	if err != nil {
+		return err
+	}
+	tendered := utils.Round(payment.TenderedAmount, 2)
+	value := utils.Round(tendered*rate, 2)
+
+	var method models.PaymentMethod
+	if err := tx.First(&method, payment.PaymentMethodID).Error; err != nil {
+		return fmt.Errorf("payment method %d not found", payment.PaymentMethodID)
+	}
+
+	amount := value
+	switch {
+	case payment.Amount > 0:
+		amount = utils.Round(payment.Amount, 2)
+	case method.Type == "cash" && due > 0 && value > due:
+		amount = utils.Round(due, 2)
+	}
+	if amount <= 0 {
+		return errors.New("payment amount must be greater than zero")
+	}
+	if amount > value+0.005 {
+		return fmt.Errorf("payment amount (%.2f) exceeds the tendered value (%.2f %s = %.2f)", amount, tendered, currency, value)
+	}
+
+	change := utils.Round(value-amount, 2)
+	if change > 0 && method.Type != "cash" {
+		return errors.New("change can only be given on cash payments")
+	}
+
+	payment.Currency = currency
+	payment.TenderedAmount = tendered
+	payment.ExchangeRate = rate
+	payment.Amount = amount
+	payment.ChangeAmount = change
+	return nil
+}
+
+// dateOnly trunca la fecha al día (fecha cero = hoy)
+func dateOnly(date time.Time) time.Time {
+	if date.IsZero() {
+		date = time.Now()
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+}
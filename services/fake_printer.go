@@ -0,0 +1,82 @@
+package services
+
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+// FakePrinter simula una impresora de red RAW (puerto 9100) para pruebas: cada conexión es un
+// documento que se guarda completo al cerrarse
+type FakePrinter struct {
+	listener net.Listener
+	mu       sync.Mutex
+	jobs     [][]byte
+	onJob    func([]byte)
+	wg       sync.WaitGroup
+}
+
+// StartFakePrinter levanta la impresora simulada en addr (ej: ":9100" o "127.0.0.1:0").
+// onJob, si no es nil, se llama con cada documento recibido.
+func StartFakePrinter(addr string, onJob func([]byte)) (*FakePrinter, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	printer := &FakePrinter{listener: listener, onJob: onJob}
+	printer.wg.Add(1)
+	go printer.serve()
+	return printer, nil
+}
+
+func (p *FakePrinter) serve() {
+	defer p.wg.Done()
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return // Listener cerrado
+		}
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			defer conn.Close()
+
+			data, err := io.ReadAll(conn)
+			if err != nil || len(data) == 0 {
+				return
+			}
+
+			p.mu.Lock()
+			p.jobs = append(p.jobs, data)
+			p.mu.Unlock()
+
+			if p.onJob != nil {
+				p.onJob(data)
+			}
+		}()
+	}
+}
+
+// Addr devuelve la dirección en la que escucha (útil con el puerto 0)
+func (p *FakePrinter) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// Jobs devuelve una copia de los documentos recibidos
+func (p *FakePrinter) Jobs() [][]byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	jobs := make([][]byte, len(p.jobs))
+	copy(jobs, p.jobs)
+	return jobs
+}
+
+// Close deja de aceptar conexiones y espera a que terminen las recibidas
+func (p *FakePrinter) Close() error {
+	err := p.listener.Close()
+	p.wg.Wait()
+	return err
+}
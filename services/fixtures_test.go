@@ -0,0 +1,90 @@
+package services
+
+import (
+	"b-resto/models"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// saleFixture - Datos mínimos para vender: compañía, cajero, terminal con almacén y sesión abierta,
+// diarios, efectivo y un producto de servicio de 10.00 sin impuestos
+type saleFixture struct {
+	company     models.Company
+	user        models.User
+	warehouse   models.Warehouse
+	pos         models.POS
+	session     models.POSSession
+	journal     models.Journal
+	cashJournal models.Journal
+	cash        models.PaymentMethod
+	product     models.ProductProduct
+}
+
+func newSaleFixture(t *testing.T, db *gorm.DB) *saleFixture {
+	t.Helper()
+	f := &saleFixture{}
+
+	create := func(value interface{}) {
+		t.Helper()
+		if err := db.Create(value).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f.company = models.Company{Name: "Frontera", BusinessName: "Frontera SAC", CurrencyCode: "PEN"}
+	create(&f.company)
+	f.user = models.User{Username: "cajero", Email: "cajero@b-resto.test", Password: "secret", Role: models.UserRole}
+	create(&f.user)
+	f.warehouse = models.Warehouse{CompanyID: f.company.ID, Code: "ALM-1", Name: "Almacén principal", IsActive: true}
+	create(&f.warehouse)
+	f.pos = models.POS{CompanyID: f.company.ID, Code: "CAJA-1", Name: "Caja 1", DefaultWarehouseID: &f.warehouse.ID, IsActive: true}
+	create(&f.pos)
+	f.session = models.POSSession{POSID: f.pos.ID, OpeningBalance: 100, OpenedBy: f.user.ID, OpenedAt: time.Now(), Status: SessionStatusOpen}
+	create(&f.session)
+	f.journal = models.Journal{CompanyID: f.company.ID, Code: "F001", Name: "Ventas", Type: "sale", IsActive: true}
+	create(&f.journal)
+	f.cashJournal = models.Journal{CompanyID: f.company.ID, Code: "CAJA", Name: "Caja", Type: "cash", IsActive: true}
+	create(&f.cashJournal)
+	f.cash = models.PaymentMethod{Code: "CASH", Name: "Efectivo", Type: "cash", IsActive: true}
+	create(&f.cash)
+
+	unit := models.Unit{Name: "Unidad", Abbreviation: "und", Type: "unit", Factor: 1, IsActive: true}
+	create(&unit)
+	category := models.ProductCategory{Name: "Platos"}
+	create(&category)
+	template := models.ProductTemplate{CategoryID: category.ID, UnitID: unit.ID, Name: "Menu del dia", ProductType: "service", CanBeSold: true, SalePrice: 10, IsActive: true}
+	create(&template)
+	f.product = models.ProductProduct{TemplateID: template.ID, SKU: "MENU", IsActive: true}
+	create(&f.product)
+
+	return f
+}
+
+// newOrder abre una orden en la sesión del fixture con las cantidades indicadas del producto
+func (f *saleFixture) newOrder(t *testing.T, name string, quantities ...float64) *models.Order {
+	t.Helper()
+
+	lines := make([]OrderLine, 0, len(quantities))
+	for _, quantity := range quantities {
+		lines = append(lines, OrderLine{ProductID: f.product.ID, Quantity: quantity})
+	}
+
+	order, err := NewOrderService().CreateOrder(OrderRequest{
+		JournalID: f.journal.ID,
+		UserID:    f.user.ID,
+		POSID:     &f.pos.ID,
+		Name:      name,
+		Lines:     lines,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+// cashPayment arma un pago en efectivo en moneda base
+func (f *saleFixture) cashPayment(amount float64) *models.OrderPayment {
+	return &models.OrderPayment{PaymentMethodID: f.cash.ID, JournalID: f.cashJournal.ID, Amount: amount}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CreateAdjustment registra un ajuste de inventario. Si su valor supera el umbral configurado
+// queda pendiente de aprobación; en caso contrario se aplica al Kardex inmediatamente.
+func (s *InventoryService) CreateAdjustment(adjustment *models.InventoryAdjustment) error {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Valorizar: las salidas al costo vigente, las entradas al costo indicado (o el vigente)
+	quant, err := s.stockQuant(tx, adjustment.ProductID, adjustment.WarehouseID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if adjustment.Type == "out" || adjustment.UnitCost <= 0 {
+		adjustment.UnitCost = quant.UnitCost
+	}
+	adjustment.TotalCost = utils.Round(adjustment.Quantity*adjustment.UnitCost, 2)
+
+	threshold := config.GetAdjustmentApprovalThreshold()
+	requiresApproval := threshold > 0 && adjustment.TotalCost > threshold
+
+	adjustment.Status = "pending"
+	if err := tx.Create(adjustment).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to create adjustment: %w", err)
+	}
+
+	if !requiresApproval {
+		if err := s.applyAdjustment(tx, adjustment, adjustment.CreatedBy); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// ApproveAdjustment aprueba un ajuste pendiente y lo aplica al Kardex
+func (s *InventoryService) ApproveAdjustment(adjustmentID uint, approvedBy *uint) (*models.InventoryAdjustment, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var adjustment models.InventoryAdjustment
+	if err := tx.First(&adjustment, adjustmentID).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("adjustment not found")
+	}
+
+	if adjustment.Status != "pending" {
+		tx.Rollback()
+		return nil, fmt.Errorf("adjustment is %s, only pending adjustments can be approved", adjustment.Status)
+	}
+
+	if err := s.applyAdjustment(tx, &adjustment, approvedBy); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &adjustment, nil
+}
+
+// RejectAdjustment rechaza un ajuste pendiente sin tocar el Kardex
+func (s *InventoryService) RejectAdjustment(adjustmentID uint, rejectedBy *uint) (*models.InventoryAdjustment, error) {
+	var adjustment models.InventoryAdjustment
+	if err := config.DB.First(&adjustment, adjustmentID).Error; err != nil {
+		return nil, errors.New("adjustment not found")
+	}
+
+	if adjustment.Status != "pending" {
+		return nil, fmt.Errorf("adjustment is %s, only pending adjustments can be rejected", adjustment.Status)
+	}
+
+	now := time.Now()
+	adjustment.Status = "rejected"
+	adjustment.ApprovedBy = rejectedBy
+	adjustment.ApprovedAt = &now
+
+	if err := config.DB.Save(&adjustment).Error; err != nil {
+		return nil, fmt.Errorf("failed to reject adjustment: %w", err)
+	}
+
+	return &adjustment, nil
+}
+
+// applyAdjustment registra el movimiento IN/OUT del ajuste y lo marca como aplicado
+func (s *InventoryService) applyAdjustment(tx *gorm.DB, adjustment *models.InventoryAdjustment, approvedBy *uint) error {
+	entry := kardexEntry{
+		ProductID:    adjustment.ProductID,
+		WarehouseID:  adjustment.WarehouseID,
+		AdjustmentID: &adjustment.ID,
+		OrderID:      adjustment.OrderID,
+		Detail:       fmt.Sprintf("Ajuste (%s) - Adjustment #%d", adjustment.Reason, adjustment.ID),
+	}
+	if adjustment.Type == "in" {
+		entry.QuantityIn = adjustment.Quantity
+		entry.CostIn = adjustment.UnitCost
+	} else {
+		entry.QuantityOut = adjustment.Quantity
+	}
+
+	kardex, err := s.postKardex(tx, entry)
+	if err != nil {
+		return err
+	}
+
+	// El costo real de la salida lo define el método de valorización
+	if adjustment.Type == "out" {
+		adjustment.UnitCost = kardex.CostOut
+		adjustment.TotalCost = kardex.TotalOut
+	}
+
+	now := time.Now()
+	adjustment.Status = "done"
+	adjustment.InventoryID = &kardex.ID
+	adjustment.ApprovedBy = approvedBy
+	adjustment.ApprovedAt = &now
+
+	if err := tx.Save(adjustment).Error; err != nil {
+		return fmt.Errorf("failed to update adjustment: %w", err)
+	}
+
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"b-resto/models"
 	"errors"
 	"fmt"
+
+	"gorm.io/gorm"
 )
 
 // InventoryService maneja la lógica de Kardex (movimientos de inventario)
@@ -15,7 +17,18 @@ func NewInventoryService() *InventoryService {
 	return &InventoryService{}
 }
 
-// RegisterSale registra salida de inventario por venta
+// saleLine representa un consumo de stock derivado de una línea de orden
+type saleLine struct {
+	OrderItemID uint
+	ProductID   uint
+	WarehouseID uint
+	Quantity    float64
+	Detail      string
+}
+
+// RegisterSale registra salida de inventario por venta.
+// Los productos con receta descuentan sus ingredientes; los almacenables sin receta se descuentan a sí mismos.
+// warehouseID es el almacén por defecto; las estaciones de cocina con almacén propio lo sobrescriben.
 func (s *InventoryService) RegisterSale(orderID uint, items []models.OrderItem, warehouseID uint) error {
 	tx := config.DB.Begin()
 	if tx.Error != nil {
@@ -28,46 +41,179 @@ func (s *InventoryService) RegisterSale(orderID uint, items []models.OrderItem,
 		}
 	}()
 
+	if err := s.registerSale(tx, orderID, items, warehouseID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// registerSale registra la salida por venta dentro de una transacción existente
+func (s *InventoryService) registerSale(tx *gorm.DB, orderID uint, items []models.OrderItem, warehouseID uint) error {
+	lines, err := s.explodeOrderItems(tx, orderID, items, warehouseID)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]stockKey, 0, len(lines))
+	for _, line := range lines {
+		keys = append(keys, stockKey{line.ProductID, line.WarehouseID})
+	}
+	if err := lockStock(tx, keys...); err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		orderItemID := line.OrderItemID
+		if _, err := s.postKardex(tx, kardexEntry{
+			ProductID:   line.ProductID,
+			WarehouseID: line.WarehouseID,
+			OrderID:     &orderID,
+			OrderItemID: &orderItemID,
+			Detail:      line.Detail,
+			QuantityOut: line.Quantity,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// explodeOrderItems convierte las líneas de una orden en consumos de stock usando las recetas (BOM)
+func (s *InventoryService) explodeOrderItems(tx *gorm.DB, orderID uint, items []models.OrderItem, defaultWarehouseID uint) ([]saleLine, error) {
+	var lines []saleLine
+	stationWarehouses := map[uint]uint{}
+
 	for _, item := range items {
-		// Obtener último saldo del producto en este almacén
-		var lastKardex models.Inventory
-		result := tx.Where("product_id = ? AND warehouse_id = ?", item.ProductID, warehouseID).
-			Order("id desc").
-			First(&lastKardex)
+		// Las líneas anuladas no consumen stock
+		if item.State == "voided" {
+			continue
+		}
 
-		// Balance anterior (si no existe, es 0)
-		previousBalance := float64(0)
-		if result.Error == nil {
-			previousBalance = lastKardex.QuantityBalance
+		var product models.ProductProduct
+		if err := tx.Preload("Template").First(&product, item.ProductID).Error; err != nil {
+			return nil, fmt.Errorf("product %d not found", item.ProductID)
 		}
 
-		// Validar stock suficiente
-		if previousBalance < item.Quantity {
-			tx.Rollback()
-			return fmt.Errorf("insufficient stock for product %d: available %.2f, required %.2f",
-				item.ProductID, previousBalance, item.Quantity)
+		warehouseID, err := s.stationWarehouse(tx, product.Template, defaultWarehouseID, stationWarehouses)
+		if err != nil {
+			return nil, err
 		}
 
-		// Crear movimiento de SALIDA
-		kardex := models.Inventory{
-			ProductID:       item.ProductID,
-			WarehouseID:     warehouseID,
-			OrderID:         &orderID,
-			Detail:          fmt.Sprintf("Venta - Order #%d", orderID),
-			QuantityOut:     item.Quantity,
-			QuantityBalance: previousBalance - item.Quantity,
+		var recipes []models.Recipe
+		if err := tx.Where("product_template_id = ?", product.TemplateID).
+			Preload("Unit").
+			Preload("Ingredient.Template.Unit").
+			Find(&recipes).Error; err != nil {
+			return nil, fmt.Errorf("failed to load recipe for product %d: %w", item.ProductID, err)
 		}
 
-		if err := tx.Create(&kardex).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to create kardex entry: %w", err)
+		// Sin receta: solo los productos almacenables descuentan stock propio
+		if len(recipes) == 0 {
+			if product.Template == nil || product.Template.ProductType != "storable" {
+				continue
+			}
+			lines = append(lines, saleLine{
+				OrderItemID: item.ID,
+				ProductID:   item.ProductID,
+				WarehouseID: warehouseID,
+				Quantity:    item.Quantity,
+				Detail:      fmt.Sprintf("Venta - Order #%d", orderID),
+			})
+			continue
+		}
+
+		// Con receta: cada ingrediente se descuenta con su merma, en la unidad de stock del ingrediente
+		for _, recipe := range recipes {
+			if recipe.Ingredient == nil || recipe.Ingredient.Template == nil {
+				return nil, fmt.Errorf("ingredient %d of recipe %d not found", recipe.IngredientID, recipe.ID)
+			}
+
+			quantity := item.Quantity * recipe.Quantity * (1 + recipe.WastePercentage/100)
+			quantity, err := recipe.Unit.ConvertTo(quantity, recipe.Ingredient.Template.Unit)
+			if err != nil {
+				return nil, fmt.Errorf("recipe %d: %w", recipe.ID, err)
+			}
+
+			lines = append(lines, saleLine{
+				OrderItemID: item.ID,
+				ProductID:   recipe.IngredientID,
+				WarehouseID: warehouseID,
+				Quantity:    quantity,
+				Detail:      fmt.Sprintf("Venta (receta) - Order #%d - %s", orderID, product.SKU),
+			})
 		}
 	}
 
-	return tx.Commit().Error
+	return lines, nil
+}
+
+// stationWarehouse devuelve el almacén de la estación de cocina del producto, o el almacén por defecto
+func (s *InventoryService) stationWarehouse(tx *gorm.DB, template *models.ProductTemplate, defaultWarehouseID uint, cache map[uint]uint) (uint, error) {
+	if template == nil || template.KitchenStationID == nil {
+		return defaultWarehouseID, nil
+	}
+
+	stationID := *template.KitchenStationID
+	if warehouseID, ok := cache[stationID]; ok {
+		return warehouseID, nil
+	}
+
+	var station models.KitchenStation
+	if err := tx.First(&station, stationID).Error; err != nil {
+		return 0, fmt.Errorf("kitchen station %d not found", stationID)
+	}
+
+	warehouseID := defaultWarehouseID
+	if station.WarehouseID != nil {
+		warehouseID = *station.WarehouseID
+	}
+	cache[stationID] = warehouseID
+
+	return warehouseID, nil
+}
+
+// ResolveSaleWarehouse determina el almacén por defecto de una venta:
+// almacén ya registrado en la orden → almacén del POS → almacén por defecto de la compañía.
+// Se lee dentro de la transacción de la venta para ver la misma configuración que el resto del cierre.
+func (s *InventoryService) ResolveSaleWarehouse(tx *gorm.DB, order *models.Order) (uint, error) {
+	if order.WarehouseID != nil {
+		return *order.WarehouseID, nil
+	}
+
+	var companyID uint
+	if order.POSID != nil {
+		var pos models.POS
+		if err := tx.First(&pos, *order.POSID).Error; err != nil {
+			return 0, fmt.Errorf("POS terminal %d not found", *order.POSID)
+		}
+		if pos.DefaultWarehouseID != nil {
+			return *pos.DefaultWarehouseID, nil
+		}
+		companyID = pos.CompanyID
+	} else {
+		// Sin terminal, la compañía se toma del diario de ventas
+		var journal models.Journal
+		if err := tx.First(&journal, order.JournalID).Error; err != nil {
+			return 0, fmt.Errorf("journal %d not found", order.JournalID)
+		}
+		companyID = journal.CompanyID
+	}
+
+	var company models.Company
+	if err := tx.First(&company, companyID).Error; err != nil {
+		return 0, fmt.Errorf("company %d not found", companyID)
+	}
+	if company.DefaultWarehouseID != nil {
+		return *company.DefaultWarehouseID, nil
+	}
+
+	return 0, fmt.Errorf("no warehouse configured for order %d: set a default warehouse on the POS or the company", order.ID)
 }
 
-// RegisterPurchase registra entrada de inventario por compra
+// RegisterPurchase registra entrada de inventario por compra (actualiza el costo promedio)
 func (s *InventoryService) RegisterPurchase(purchaseOrderID uint, items []models.PurchaseOrderItem, warehouseID uint) error {
 	tx := config.DB.Begin()
 	if tx.Error != nil {
@@ -80,40 +226,35 @@ func (s *InventoryService) RegisterPurchase(purchaseOrderID uint, items []models
 		}
 	}()
 
+	keys := make([]stockKey, 0, len(items))
 	for _, item := range items {
-		// Obtener último saldo
-		var lastKardex models.Inventory
-		result := tx.Where("product_id = ? AND warehouse_id = ?", item.ProductID, warehouseID).
-			Order("id desc").
-			First(&lastKardex)
-
-		previousBalance := float64(0)
-		if result.Error == nil {
-			previousBalance = lastKardex.QuantityBalance
-		}
+		keys = append(keys, stockKey{item.ProductID, warehouseID})
+	}
+	if err := lockStock(tx, keys...); err != nil {
+		tx.Rollback()
+		return err
+	}
 
+	for _, item := range items {
 		// Crear movimiento de ENTRADA
-		kardex := models.Inventory{
+		if _, err := s.postKardex(tx, kardexEntry{
 			ProductID:       item.ProductID,
 			WarehouseID:     warehouseID,
 			PurchaseOrderID: &purchaseOrderID,
 			Detail:          fmt.Sprintf("Compra - Purchase Order #%d", purchaseOrderID),
 			QuantityIn:      item.Quantity,
 			CostIn:          item.UnitPrice,
-			TotalIn:         item.Quantity * item.UnitPrice,
-			QuantityBalance: previousBalance + item.Quantity,
-		}
-
-		if err := tx.Create(&kardex).Error; err != nil {
+		}); err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to create kardex entry: %w", err)
+			return err
 		}
 	}
 
 	return tx.Commit().Error
 }
 
-// RegisterTransfer registra transferencia entre almacenes (salida + entrada)
+// RegisterTransfer registra transferencia entre almacenes (salida + entrada).
+// La salida se valoriza al costo del origen (promedio o capas FIFO) y la entrada hereda ese costo.
 func (s *InventoryService) RegisterTransfer(transferID uint, fromWarehouseID, toWarehouseID uint, items []models.StockTransferItem) error {
 	tx := config.DB.Begin()
 	if tx.Error != nil {
@@ -126,95 +267,104 @@ func (s *InventoryService) RegisterTransfer(transferID uint, fromWarehouseID, to
 		}
 	}()
 
+	keys := make([]stockKey, 0, len(items)*2)
 	for _, item := range items {
-		// 1. SALIDA del almacén origen
-		var lastKardexFrom models.Inventory
-		result := tx.Where("product_id = ? AND warehouse_id = ?", item.ProductID, fromWarehouseID).
-			Order("id desc").
-			First(&lastKardexFrom)
-
-		previousBalanceFrom := float64(0)
-		if result.Error == nil {
-			previousBalanceFrom = lastKardexFrom.QuantityBalance
-		}
+		keys = append(keys, stockKey{item.ProductID, fromWarehouseID}, stockKey{item.ProductID, toWarehouseID})
+	}
+	if err := lockStock(tx, keys...); err != nil {
+		tx.Rollback()
+		return err
+	}
 
-		// Validar stock en origen
-		if previousBalanceFrom < item.Quantity {
+	// Si la transferencia fue enviada, lo reservado en el origen se libera para poder darle salida
+	var transfer models.StockTransfer
+	if err := tx.Select("id", "status").First(&transfer, transferID).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("stock transfer %d not found", transferID)
+	}
+	if transfer.Status == "in_transit" {
+		if err := s.releaseTransfer(tx, fromWarehouseID, items); err != nil {
 			tx.Rollback()
-			return fmt.Errorf("insufficient stock in source warehouse for product %d", item.ProductID)
+			return err
 		}
+	}
 
-		kardexOut := models.Inventory{
+	for _, item := range items {
+		// 1. SALIDA del almacén origen
+		kardexOut, err := s.postKardex(tx, kardexEntry{
 			ProductID:       item.ProductID,
 			WarehouseID:     fromWarehouseID,
 			StockTransferID: &transferID,
 			Detail:          fmt.Sprintf("Transferencia salida - Transfer #%d", transferID),
 			QuantityOut:     item.Quantity,
-			QuantityBalance: previousBalanceFrom - item.Quantity,
-		}
-
-		if err := tx.Create(&kardexOut).Error; err != nil {
+		})
+		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to create kardex out entry: %w", err)
-		}
-
-		// 2. ENTRADA al almacén destino
-		var lastKardexTo models.Inventory
-		result = tx.Where("product_id = ? AND warehouse_id = ?", item.ProductID, toWarehouseID).
-			Order("id desc").
-			First(&lastKardexTo)
-
-		previousBalanceTo := float64(0)
-		if result.Error == nil {
-			previousBalanceTo = lastKardexTo.QuantityBalance
+			return fmt.Errorf("source warehouse: %w", err)
 		}
 
-		kardexIn := models.Inventory{
+		// 2. ENTRADA al almacén destino con el costo del origen
+		if _, err := s.postKardex(tx, kardexEntry{
 			ProductID:       item.ProductID,
 			WarehouseID:     toWarehouseID,
 			StockTransferID: &transferID,
 			Detail:          fmt.Sprintf("Transferencia entrada - Transfer #%d", transferID),
 			QuantityIn:      item.Quantity,
-			QuantityBalance: previousBalanceTo + item.Quantity,
+			CostIn:          kardexOut.CostOut,
+		}); err != nil {
+			tx.Rollback()
+			return err
 		}
 
-		if err := tx.Create(&kardexIn).Error; err != nil {
+		// Guardar el costo real transferido en la línea
+		if err := tx.Model(&models.StockTransferItem{}).Where("id = ?", item.ID).
+			Update("cost", kardexOut.CostOut).Error; err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to create kardex in entry: %w", err)
+			return fmt.Errorf("failed to update transfer item cost: %w", err)
 		}
 	}
 
 	return tx.Commit().Error
 }
 
-// ValidateStock verifica si hay stock suficiente antes de una venta
+// ValidateStock verifica si hay stock disponible (en mano menos reservado) antes de una venta
 func (s *InventoryService) ValidateStock(productID, warehouseID uint, requiredQty float64) (bool, float64, error) {
-	var lastKardex models.Inventory
-	result := config.DB.Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).
-		Order("id desc").
-		First(&lastKardex)
-
-	if result.Error != nil {
-		// No hay movimientos = stock 0
-		return false, 0, nil
+	quant, err := s.stockQuant(config.DB, productID, warehouseID)
+	if err != nil {
+		return false, 0, err
 	}
 
-	available := lastKardex.QuantityBalance
+	available := quant.AvailableQuantity
 	hasStock := available >= requiredQty
 
 	return hasStock, available, nil
 }
 
-// GetCurrentStock obtiene el stock actual de un producto en un almacén
+// GetCurrentStock obtiene el stock en mano de un producto en un almacén
 func (s *InventoryService) GetCurrentStock(productID, warehouseID uint) (float64, error) {
-	var lastKardex models.Inventory
-	result := config.DB.Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).
-		Order("id desc").
-		First(&lastKardex)
+	quant, err := s.stockQuant(config.DB, productID, warehouseID)
+	if err != nil {
+		return 0, err
+	}
 
-	if result.Error != nil {
+	if quant.LastInventoryID == nil {
 		return 0, errors.New("no inventory records found")
 	}
 
-	return lastKardex.QuantityBalance, nil
+	return quant.Quantity, nil
+}
+
+// GetValuation obtiene el saldo valorizado de cada producto por almacén
+func (s *InventoryService) GetValuation(warehouseID string) ([]models.StockQuant, float64, error) {
+	quants, err := s.ListStock(StockFilter{WarehouseID: warehouseID})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := float64(0)
+	for _, quant := range quants {
+		total += quant.Value
+	}
+
+	return quants, total, nil
 }
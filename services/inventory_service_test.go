@@ -0,0 +1,173 @@
+package services
+
+import (
+	"b-resto/models"
+	"b-resto/testutil"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentKardexKeepsBalanceChain lanza ventas y compras simultáneas del mismo producto en el
+// mismo almacén: cada fila del Kardex debe partir del saldo de la anterior y ninguna venta puede
+// dejar el saldo en negativo.
+func TestConcurrentKardexKeepsBalanceChain(t *testing.T) {
+	db := testutil.OpenDB(t)
+
+	const (
+		purchases        = 10
+		purchaseQuantity = 2.0
+		sales            = 30
+	)
+
+	company := models.Company{Name: "Frontera", BusinessName: "Frontera SAC"}
+	if err := db.Create(&company).Error; err != nil {
+		t.Fatal(err)
+	}
+	warehouse := models.Warehouse{CompanyID: company.ID, Code: "ALM-1", Name: "Almacén principal", IsActive: true}
+	if err := db.Create(&warehouse).Error; err != nil {
+		t.Fatal(err)
+	}
+	unit := models.Unit{Name: "Unidad", Abbreviation: "und", Type: "unit", Factor: 1, IsActive: true}
+	if err := db.Create(&unit).Error; err != nil {
+		t.Fatal(err)
+	}
+	category := models.ProductCategory{Name: "Bebidas"}
+	if err := db.Create(&category).Error; err != nil {
+		t.Fatal(err)
+	}
+	template := models.ProductTemplate{CategoryID: category.ID, UnitID: unit.ID, Name: "Gaseosa", ProductType: "storable", CanBeSold: true, IsActive: true}
+	if err := db.Create(&template).Error; err != nil {
+		t.Fatal(err)
+	}
+	product := models.ProductProduct{TemplateID: template.ID, SKU: "GAS-500", IsActive: true}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	partner := models.Partner{Code: "PROV-1", Name: "Proveedor", TaxID: "20100000001", Email: "proveedor@b-resto.test", Phone: "999999999", IsSupplier: true}
+	if err := db.Create(&partner).Error; err != nil {
+		t.Fatal(err)
+	}
+	journal := models.Journal{CompanyID: company.ID, Code: "SO", Name: "Ventas", Type: "sale"}
+	if err := db.Create(&journal).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: "cajero", Email: "cajero@b-resto.test", Password: "secret", Role: models.UserRole}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	purchaseOrders := make([]models.PurchaseOrder, purchases)
+	for i := range purchaseOrders {
+		purchaseOrders[i] = models.PurchaseOrder{
+			OrderNumber: fmt.Sprintf("PO/%04d", i+1),
+			CompanyID:   company.ID,
+			WarehouseID: warehouse.ID,
+			PartnerID:   partner.ID,
+			OrderDate:   time.Now(),
+			Status:      "received",
+			Items:       []models.PurchaseOrderItem{{ProductID: product.ID, Quantity: purchaseQuantity, UnitPrice: 3, Subtotal: 6}},
+		}
+		if err := db.Create(&purchaseOrders[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	orders := make([]models.Order, sales)
+	for i := range orders {
+		orders[i] = models.Order{
+			JournalID: journal.ID,
+			UserID:    user.ID,
+			Name:      fmt.Sprintf("SO/%04d", i+1),
+			State:     "confirmed",
+			OrderDate: time.Now(),
+			Items:     []models.OrderItem{{ProductID: product.ID, Quantity: 1, PriceUnit: 5, PriceSubtotal: 5, PriceTotal: 5}},
+		}
+		if err := db.Create(&orders[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	service := NewInventoryService()
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		sold      int
+		failures  []error
+		start     = make(chan struct{})
+		recordErr = func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failures = append(failures, err)
+		}
+	)
+
+	for i := range purchaseOrders {
+		wg.Add(1)
+		go func(order models.PurchaseOrder) {
+			defer wg.Done()
+			<-start
+			if err := service.RegisterPurchase(order.ID, order.Items, warehouse.ID); err != nil {
+				recordErr(fmt.Errorf("purchase %d: %w", order.ID, err))
+			}
+		}(purchaseOrders[i])
+	}
+	for i := range orders {
+		wg.Add(1)
+		go func(order models.Order) {
+			defer wg.Done()
+			<-start
+			err := service.RegisterSale(order.ID, order.Items, warehouse.ID)
+			switch {
+			case err == nil:
+				mu.Lock()
+				sold++
+				mu.Unlock()
+			case !strings.Contains(err.Error(), "insufficient stock"):
+				recordErr(fmt.Errorf("sale %d: %w", order.ID, err))
+			}
+		}(orders[i])
+	}
+	close(start)
+	wg.Wait()
+
+	for _, err := range failures {
+		t.Error(err)
+	}
+
+	var rows []models.Inventory
+	if err := db.Where("product_id = ? AND warehouse_id = ?", product.ID, warehouse.ID).
+		Order("id asc").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != purchases+sold {
+		t.Fatalf("kardex rows = %d, want %d purchases + %d sales", len(rows), purchases, sold)
+	}
+
+	balance := 0.0
+	for _, row := range rows {
+		balance += row.QuantityIn - row.QuantityOut
+		if math.Abs(row.QuantityBalance-balance) > quantityEpsilon {
+			t.Fatalf("kardex row %d balance = %.4f, want %.4f from the previous row", row.ID, row.QuantityBalance, balance)
+		}
+		if row.QuantityBalance < -quantityEpsilon {
+			t.Fatalf("kardex row %d oversold: balance %.4f", row.ID, row.QuantityBalance)
+		}
+	}
+
+	want := purchases*purchaseQuantity - float64(sold)
+	if math.Abs(balance-want) > quantityEpsilon {
+		t.Fatalf("final balance = %.4f, want %.4f", balance, want)
+	}
+
+	var quant models.StockQuant
+	if err := db.Where("product_id = ? AND warehouse_id = ?", product.ID, warehouse.ID).First(&quant).Error; err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(quant.Quantity-want) > quantityEpsilon {
+		t.Fatalf("stock quant = %.4f, want %.4f", quant.Quantity, want)
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"b-resto/models"
+	"b-resto/utils"
+	"fmt"
+	"math"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// quantityEpsilon tolera errores de redondeo al comparar cantidades
+const quantityEpsilon = 1e-6
+
+// kardexEntry describe un movimiento a registrar en el Kardex
+type kardexEntry struct {
+	ProductID       uint
+	WarehouseID     uint
+	OrderID         *uint
+	OrderItemID     *uint
+	PurchaseOrderID *uint
+	StockTransferID *uint
+	AdjustmentID    *uint
+	ReversedID      *uint // Salida de venta que compensa esta entrada
+	Detail          string
+	QuantityIn      float64
+	CostIn          float64 // Costo unitario de la entrada
+	QuantityOut     float64
+}
+
+// stockKey identifica el saldo de un producto en un almacén
+type stockKey struct {
+	ProductID   uint
+	WarehouseID uint
+}
+
+// lockStock serializa la escritura del Kardex por producto/almacén con advisory locks de transacción
+// (se liberan en el commit/rollback). Las claves se bloquean siempre en el mismo orden para que dos
+// transacciones con varios productos no se bloqueen mutuamente.
+func lockStock(tx *gorm.DB, keys ...stockKey) error {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ProductID != keys[j].ProductID {
+			return keys[i].ProductID < keys[j].ProductID
+		}
+		return keys[i].WarehouseID < keys[j].WarehouseID
+	})
+
+	for i, key := range keys {
+		if i > 0 && key == keys[i-1] {
+			continue
+		}
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", int32(key.ProductID), int32(key.WarehouseID)).Error; err != nil {
+			return fmt.Errorf("failed to lock stock for product %d: %w", key.ProductID, err)
+		}
+	}
+
+	return nil
+}
+
+// lastKardex obtiene el último movimiento de un producto en un almacén (zero value si no hay).
+// Se ordena como el recálculo (created_at, id) para que el saldo vigente sea el mismo en ambos.
+func (s *InventoryService) lastKardex(tx *gorm.DB, productID, warehouseID uint) models.Inventory {
+	var last models.Inventory
+	tx.Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).
+		Order("created_at desc, id desc").
+		Limit(1).
+		Find(&last)
+	return last
+}
+
+// latestKardexIDs devuelve una subconsulta con el ID del último movimiento de cada producto/almacén
+func latestKardexIDs(tx *gorm.DB) *gorm.DB {
+	return tx.Model(&models.Inventory{}).
+		Select("DISTINCT ON (product_id, warehouse_id) id").
+		Order("product_id, warehouse_id, created_at desc, id desc")
+}
+
+// postKardex registra un movimiento valorizado según el método de costeo del producto.
+// Promedio ponderado: las entradas recalculan el promedio y las salidas se valorizan a él.
+// FIFO: las salidas se valorizan con el costo de las capas más antiguas que consumen.
+func (s *InventoryService) postKardex(tx *gorm.DB, entry kardexEntry) (*models.Inventory, error) {
+	// El saldo se lee y escribe bajo lock: otra terminal no puede leer el mismo saldo a la vez
+	if err := lockStock(tx, stockKey{entry.ProductID, entry.WarehouseID}); err != nil {
+		return nil, err
+	}
+
+	last := s.lastKardex(tx, entry.ProductID, entry.WarehouseID)
+
+	quant, err := s.stockQuant(tx, entry.ProductID, entry.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+
+	method, err := s.costMethod(tx, entry.ProductID, entry.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+
+	row := &models.Inventory{
+		ProductID:           entry.ProductID,
+		WarehouseID:         entry.WarehouseID,
+		OrderID:             entry.OrderID,
+		OrderItemID:         entry.OrderItemID,
+		PurchaseOrderID:     entry.PurchaseOrderID,
+		StockTransferID:     entry.StockTransferID,
+		AdjustmentID:        entry.AdjustmentID,
+		Detail:              entry.Detail,
+		ReversedInventoryID: entry.ReversedID,
+	}
+
+	quantity := last.QuantityBalance
+	total := last.TotalBalance
+	cost := last.CostBalance
+
+	if entry.QuantityIn > 0 {
+		row.QuantityIn = entry.QuantityIn
+		row.CostIn = utils.Round(entry.CostIn, 4)
+		row.TotalIn = utils.Round(entry.QuantityIn*entry.CostIn, 2)
+
+		quantity += entry.QuantityIn
+		total += row.TotalIn
+		if quantity > quantityEpsilon {
+			cost = total / quantity
+		}
+	}
+
+	if entry.QuantityOut > 0 {
+		// Lo reservado (transferencias en tránsito) no está disponible para otras salidas
+		available := quantity - quant.ReservedQuantity
+		if available+quantityEpsilon < entry.QuantityOut {
+			return nil, fmt.Errorf("insufficient stock for product %d: available %.4f, required %.4f",
+				entry.ProductID, available, entry.QuantityOut)
+		}
+
+		// Las capas se consumen siempre para mantenerlas al día aunque el método sea promedio
+		layersValue, err := s.consumeLayers(tx, entry.ProductID, entry.WarehouseID, entry.QuantityOut, cost)
+		if err != nil {
+			return nil, err
+		}
+
+		row.QuantityOut = entry.QuantityOut
+		if method == models.CostMethodFIFO {
+			row.TotalOut = utils.Round(layersValue, 2)
+			row.CostOut = utils.Round(layersValue/entry.QuantityOut, 4)
+		} else {
+			row.CostOut = utils.Round(cost, 4)
+			row.TotalOut = utils.Round(entry.QuantityOut*cost, 2)
+		}
+
+		quantity -= entry.QuantityOut
+		total -= row.TotalOut
+		if method == models.CostMethodFIFO && quantity > quantityEpsilon {
+			cost = total / quantity
+		}
+	}
+
+	// Sin existencias no queda valor residual por redondeo
+	if math.Abs(quantity) < quantityEpsilon {
+		quantity = 0
+		total = 0
+	}
+
+	row.QuantityBalance = quantity
+	row.CostBalance = utils.Round(cost, 4)
+	row.TotalBalance = utils.Round(total, 2)
+
+	if err := tx.Create(row).Error; err != nil {
+		return nil, fmt.Errorf("failed to create kardex entry: %w", err)
+	}
+
+	if err := s.applyQuant(tx, quant, row); err != nil {
+		return nil, err
+	}
+
+	// Cada entrada abre una capa de costo
+	if row.QuantityIn > 0 {
+		layer := models.StockLayer{
+			ProductID:         row.ProductID,
+			WarehouseID:       row.WarehouseID,
+			InventoryID:       row.ID,
+			Quantity:          row.QuantityIn,
+			RemainingQuantity: row.QuantityIn,
+			UnitCost:          row.CostIn,
+		}
+		if err := tx.Create(&layer).Error; err != nil {
+			return nil, fmt.Errorf("failed to create stock layer: %w", err)
+		}
+	}
+
+	return row, nil
+}
+
+// consumeLayers descuenta una cantidad de las capas más antiguas y devuelve el valor consumido.
+// Si las capas no alcanzan (stock previo a las capas), el resto se valoriza al costo de respaldo.
+func (s *InventoryService) consumeLayers(tx *gorm.DB, productID, warehouseID uint, quantity, fallbackCost float64) (float64, error) {
+	var layers []models.StockLayer
+	if err := tx.Where("product_id = ? AND warehouse_id = ? AND remaining_quantity > 0", productID, warehouseID).
+		Order("id asc").
+		Find(&layers).Error; err != nil {
+		return 0, fmt.Errorf("failed to load stock layers: %w", err)
+	}
+
+	pending := quantity
+	value := float64(0)
+	for _, layer := range layers {
+		if pending <= quantityEpsilon {
+			break
+		}
+
+		taken := math.Min(layer.RemainingQuantity, pending)
+		remaining := layer.RemainingQuantity - taken
+		if remaining < quantityEpsilon {
+			remaining = 0
+		}
+
+		if err := tx.Model(&models.StockLayer{}).Where("id = ?", layer.ID).
+			Update("remaining_quantity", remaining).Error; err != nil {
+			return 0, fmt.Errorf("failed to update stock layer: %w", err)
+		}
+
+		value += taken * layer.UnitCost
+		pending -= taken
+	}
+
+	if pending > quantityEpsilon {
+		value += pending * fallbackCost
+	}
+
+	return value, nil
+}
+
+// costMethod determina el método de costeo: categoría de inventario (o sus padres) → compañía del almacén
+func (s *InventoryService) costMethod(tx *gorm.DB, productID, warehouseID uint) (string, error) {
+	var product models.ProductProduct
+	if err := tx.Preload("Template").First(&product, productID).Error; err != nil {
+		return "", fmt.Errorf("product %d not found", productID)
+	}
+
+	if product.Template != nil {
+		visited := map[uint]bool{}
+		categoryID := product.Template.InventoryCategoryID
+		for categoryID != nil && !visited[*categoryID] {
+			visited[*categoryID] = true
+			var category models.InventoryCategory
+			if err := tx.First(&category, *categoryID).Error; err != nil {
+				break
+			}
+			if category.CostMethod != "" {
+				return category.CostMethod, nil
+			}
+			categoryID = category.ParentID
+		}
+	}
+
+	var warehouse models.Warehouse
+	if err := tx.Preload("Company").First(&warehouse, warehouseID).Error; err != nil {
+		return "", fmt.Errorf("warehouse %d not found", warehouseID)
+	}
+	if warehouse.Company != nil && warehouse.Company.CostMethod != "" {
+		return warehouse.Company.CostMethod, nil
+	}
+
+	return models.CostMethodAverage, nil
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/utils"
+	"fmt"
+	"math"
+
+	"gorm.io/gorm"
+)
+
+// KardexFigures - Importes de un movimiento que el recálculo puede reescribir
+type KardexFigures struct {
+	CostIn          float64 `json:"cost_in"`
+	TotalIn         float64 `json:"total_in"`
+	CostOut         float64 `json:"cost_out"`
+	TotalOut        float64 `json:"total_out"`
+	QuantityBalance float64 `json:"quantity_balance"`
+	CostBalance     float64 `json:"cost_balance"`
+	TotalBalance    float64 `json:"total_balance"`
+}
+
+// KardexChange - Movimiento cuyo saldo o costo cambió al recalcular
+type KardexChange struct {
+	InventoryID uint          `json:"inventory_id"`
+	ProductID   uint          `json:"product_id"`
+	WarehouseID uint          `json:"warehouse_id"`
+	Before      KardexFigures `json:"before"`
+	After       KardexFigures `json:"after"`
+}
+
+// KardexRecomputeResult - Resultado de un recálculo del Kardex
+type KardexRecomputeResult struct {
+	DryRun    bool           `json:"dry_run"`
+	Movements int            `json:"movements"` // Movimientos recorridos
+	Changes   []KardexChange `json:"changes"`
+}
+
+// replayLayer es una capa de costo reconstruida en memoria
+type replayLayer struct {
+	inventoryID uint
+	quantity    float64
+	remaining   float64
+	unitCost    float64
+}
+
+// replayState es el saldo acumulado de un producto/almacén durante el recálculo
+type replayState struct {
+	method   string
+	quantity float64
+	total    float64
+	cost     float64
+	lastID   uint
+	layers   []*replayLayer
+}
+
+// consume descuenta de las capas más antiguas (igual que consumeLayers) y devuelve el valor consumido
+func (st *replayState) consume(quantity float64) float64 {
+	pending := quantity
+	value := float64(0)
+	for _, layer := range st.layers {
+		if pending <= quantityEpsilon {
+			break
+		}
+		if layer.remaining <= 0 {
+			continue
+		}
+
+		taken := math.Min(layer.remaining, pending)
+		layer.remaining -= taken
+		if layer.remaining < quantityEpsilon {
+			layer.remaining = 0
+		}
+
+		value += taken * layer.unitCost
+		pending -= taken
+	}
+
+	if pending > quantityEpsilon {
+		value += pending * st.cost
+	}
+
+	return value
+}
+
+// RecomputeKardex reprocesa en orden cronológico los movimientos de un producto y/o almacén
+// (0 = todos) y reescribe saldos, costos, capas FIFO y stock quants. Con dryRun solo informa
+// qué movimientos cambiarían.
+func (s *InventoryService) RecomputeKardex(productID, warehouseID uint, dryRun bool) (*KardexRecomputeResult, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	scope := func(query *gorm.DB) *gorm.DB {
+		if productID != 0 {
+			query = query.Where("product_id = ?", productID)
+		}
+		if warehouseID != 0 {
+			query = query.Where("warehouse_id = ?", warehouseID)
+		}
+		return query
+	}
+
+	// Claves afectadas: las que tienen movimientos y las que tienen saldo (por si se borraron todos)
+	var keys []stockKey
+	if err := tx.Raw("(?) UNION (?)",
+		scope(tx.Model(&models.Inventory{}).Select("product_id, warehouse_id")),
+		scope(tx.Model(&models.StockQuant{}).Select("product_id, warehouse_id")),
+	).Scan(&keys).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to load stock keys: %w", err)
+	}
+	if err := lockStock(tx, keys...); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var rows []models.Inventory
+	if err := scope(tx.Model(&models.Inventory{})).Order("created_at asc, id asc").Find(&rows).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to load kardex: %w", err)
+	}
+
+	result := &KardexRecomputeResult{DryRun: dryRun, Movements: len(rows), Changes: []KardexChange{}}
+	states := map[stockKey]*replayState{}
+	transferCosts := map[[2]uint]float64{} // (transferencia, producto) → costo de la salida recalculada
+	outputCosts := map[uint]float64{}      // movimiento de salida → costo recalculado (para devoluciones)
+
+	for i := range rows {
+		row := &rows[i]
+		key := stockKey{row.ProductID, row.WarehouseID}
+
+		st, ok := states[key]
+		if !ok {
+			method, err := s.costMethod(tx, row.ProductID, row.WarehouseID)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			st = &replayState{method: method}
+			states[key] = st
+		}
+
+		before := kardexFigures(row)
+
+		if row.QuantityIn > 0 {
+			// La entrada de una transferencia hereda el costo de su salida
+			if row.StockTransferID != nil {
+				cost, err := s.transferOutCost(tx, transferCosts, *row.StockTransferID, row.ProductID, row.CostIn)
+				if err != nil {
+					tx.Rollback()
+					return nil, err
+				}
+				row.CostIn = utils.Round(cost, 4)
+				row.TotalIn = utils.Round(row.QuantityIn*cost, 2)
+			}
+
+			// La devolución de una venta vuelve al costo con que salió
+			if row.ReversedInventoryID != nil {
+				if cost, ok := outputCosts[*row.ReversedInventoryID]; ok {
+					row.CostIn = cost
+					row.TotalIn = utils.Round(row.QuantityIn*cost, 2)
+				}
+			}
+
+			st.quantity += row.QuantityIn
+			st.total += row.TotalIn
+			if st.quantity > quantityEpsilon {
+				st.cost = st.total / st.quantity
+			}
+			st.layers = append(st.layers, &replayLayer{
+				inventoryID: row.ID,
+				quantity:    row.QuantityIn,
+				remaining:   row.QuantityIn,
+				unitCost:    row.CostIn,
+			})
+		}
+
+		if row.QuantityOut > 0 {
+			layersValue := st.consume(row.QuantityOut)
+			if st.method == models.CostMethodFIFO {
+				row.TotalOut = utils.Round(layersValue, 2)
+				row.CostOut = utils.Round(layersValue/row.QuantityOut, 4)
+			} else {
+				row.CostOut = utils.Round(st.cost, 4)
+				row.TotalOut = utils.Round(row.QuantityOut*st.cost, 2)
+			}
+
+			st.quantity -= row.QuantityOut
+			st.total -= row.TotalOut
+			if st.method == models.CostMethodFIFO && st.quantity > quantityEpsilon {
+				st.cost = st.total / st.quantity
+			}
+
+			if row.StockTransferID != nil {
+				transferCosts[[2]uint{*row.StockTransferID, row.ProductID}] = row.CostOut
+			}
+			outputCosts[row.ID] = row.CostOut
+		}
+
+		if math.Abs(st.quantity) < quantityEpsilon {
+			st.quantity = 0
+			st.total = 0
+		}
+
+		// El siguiente movimiento parte del saldo tal como queda guardado
+		st.quantity = utils.Round(st.quantity, 4)
+		st.cost = utils.Round(st.cost, 4)
+		st.total = utils.Round(st.total, 2)
+		st.lastID = row.ID
+
+		row.QuantityBalance = st.quantity
+		row.CostBalance = st.cost
+		row.TotalBalance = st.total
+
+		after := kardexFigures(row)
+		if before == after {
+			continue
+		}
+
+		result.Changes = append(result.Changes, KardexChange{
+			InventoryID: row.ID,
+			ProductID:   row.ProductID,
+			WarehouseID: row.WarehouseID,
+			Before:      before,
+			After:       after,
+		})
+
+		if dryRun {
+			continue
+		}
+		if err := tx.Model(&models.Inventory{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+			"cost_in":          row.CostIn,
+			"total_in":         row.TotalIn,
+			"cost_out":         row.CostOut,
+			"total_out":        row.TotalOut,
+			"quantity_balance": row.QuantityBalance,
+			"cost_balance":     row.CostBalance,
+			"total_balance":    row.TotalBalance,
+		}).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to update kardex entry %d: %w", row.ID, err)
+		}
+	}
+
+	if dryRun {
+		tx.Rollback()
+		return result, nil
+	}
+
+	for _, key := range keys {
+		st := states[key]
+		if st == nil {
+			st = &replayState{}
+		}
+		if err := s.rebuildStock(tx, key, st); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// transferOutCost obtiene el costo de la salida de una transferencia: el recalculado si ya se
+// reprocesó o el guardado si el almacén origen queda fuera del recálculo
+func (s *InventoryService) transferOutCost(tx *gorm.DB, recomputed map[[2]uint]float64, transferID, productID uint, fallback float64) (float64, error) {
+	if cost, ok := recomputed[[2]uint{transferID, productID}]; ok {
+		return cost, nil
+	}
+
+	var out models.Inventory
+	result := tx.Where("stock_transfer_id = ? AND product_id = ? AND quantity_out > 0", transferID, productID).
+		Order("id desc").
+		Limit(1).
+		Find(&out)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to load transfer %d output: %w", transferID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fallback, nil
+	}
+
+	return out.CostOut, nil
+}
+
+// rebuildStock reemplaza las capas de costo y el stock quant de un producto/almacén con el resultado del recálculo
+func (s *InventoryService) rebuildStock(tx *gorm.DB, key stockKey, st *replayState) error {
+	if err := tx.Where("product_id = ? AND warehouse_id = ?", key.ProductID, key.WarehouseID).
+		Delete(&models.StockLayer{}).Error; err != nil {
+		return fmt.Errorf("failed to clear stock layers: %w", err)
+	}
+
+	for _, layer := range st.layers {
+		if err := tx.Create(&models.StockLayer{
+			ProductID:         key.ProductID,
+			WarehouseID:       key.WarehouseID,
+			InventoryID:       layer.inventoryID,
+			Quantity:          layer.quantity,
+			RemainingQuantity: utils.Round(layer.remaining, 4),
+			UnitCost:          layer.unitCost,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to create stock layer: %w", err)
+		}
+	}
+
+	quant, err := s.stockQuant(tx, key.ProductID, key.WarehouseID)
+	if err != nil {
+		return err
+	}
+
+	quant.Quantity = st.quantity
+	quant.AvailableQuantity = st.quantity - quant.ReservedQuantity
+	quant.UnitCost = st.cost
+	quant.Value = st.total
+	quant.LastInventoryID = nil
+	if st.lastID != 0 {
+		quant.LastInventoryID = &st.lastID
+	}
+
+	if err := tx.Save(quant).Error; err != nil {
+		return fmt.Errorf("failed to update stock quant: %w", err)
+	}
+	return nil
+}
+
+// kardexFigures extrae los importes recalculables de un movimiento
+func kardexFigures(row *models.Inventory) KardexFigures {
+	return KardexFigures{
+		CostIn:          row.CostIn,
+		TotalIn:         row.TotalIn,
+		CostOut:         row.CostOut,
+		TotalOut:        row.TotalOut,
+		QuantityBalance: row.QuantityBalance,
+		CostBalance:     row.CostBalance,
+		TotalBalance:    row.TotalBalance,
+	}
+}
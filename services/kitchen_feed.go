@@ -0,0 +1,237 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tipos de evento del feed de cocina
+const (
+	KitchenEventCreated      = "created"
+	KitchenEventUpdated      = "updated"
+	KitchenEventStateChanged = "state_changed"
+	KitchenEventVoided       = "voided"
+)
+
+// kitchenChannel es el canal de LISTEN/NOTIFY; el aviso lleva el ID de la estación
+const kitchenChannel = "kitchen_ticket_events"
+
+// kitchenBroker avisa a los streams abiertos de una estación que hay eventos nuevos.
+// Los eventos se leen siempre de la tabla, así un aviso perdido solo retrasa la entrega.
+type kitchenBroker struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[chan struct{}]struct{}
+}
+
+var kitchenFeed = &kitchenBroker{subscribers: map[uint]map[chan struct{}]struct{}{}}
+
+// SubscribeKitchenStation registra un stream de la estación. Devuelve el canal de avisos y la
+// función para darse de baja.
+func SubscribeKitchenStation(stationID uint) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	kitchenFeed.mu.Lock()
+	if kitchenFeed.subscribers[stationID] == nil {
+		kitchenFeed.subscribers[stationID] = map[chan struct{}]struct{}{}
+	}
+	kitchenFeed.subscribers[stationID][ch] = struct{}{}
+	kitchenFeed.mu.Unlock()
+
+	return ch, func() {
+		kitchenFeed.mu.Lock()
+		delete(kitchenFeed.subscribers[stationID], ch)
+		if len(kitchenFeed.subscribers[stationID]) == 0 {
+			delete(kitchenFeed.subscribers, stationID)
+		}
+		kitchenFeed.mu.Unlock()
+	}
+}
+
+// notify despierta los streams de una estación (0 = todas)
+func (b *kitchenBroker) notify(stationID uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for id, subscribers := range b.subscribers {
+		if stationID != 0 && id != stationID {
+			continue
+		}
+		for ch := range subscribers {
+			select {
+			case ch <- struct{}{}:
+			default: // Ya tiene un aviso pendiente
+			}
+		}
+	}
+}
+
+// ListenKitchenEvents escucha los avisos de Postgres y despierta los streams de cada estación.
+// NOTIFY se entrega al confirmar la transacción, así el stream nunca lee un evento sin confirmar.
+// Se reconecta ante cualquier error.
+func ListenKitchenEvents(dsn string) {
+	for {
+		if err := listenKitchenEvents(dsn); err != nil {
+			log.Printf("⚠️ Kitchen feed listener: %v", err)
+		}
+		// Al reconectar, los streams vuelven a leer por si se perdió algún aviso
+		kitchenFeed.notify(0)
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func listenKitchenEvents(dsn string) error {
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, "LISTEN "+kitchenChannel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		stationID, err := strconv.ParseUint(notification.Payload, 10, 64)
+		if err != nil {
+			continue
+		}
+		kitchenFeed.notify(uint(stationID))
+	}
+}
+
+// recordKitchenEvent guarda un evento con el ticket completo y avisa a la estación. La estación
+// queda bloqueada hasta el fin de la transacción: los eventos de una estación se confirman en el
+// orden de su ID y un stream que retoma desde un ID no se salta ninguno.
+func recordKitchenEvent(tx *gorm.DB, ticketID uint, eventType string) error {
+	var ticket models.KitchenTicket
+	if err := tx.Preload("Order").Preload("Items.OrderItem.Product").First(&ticket, ticketID).Error; err != nil {
+		return fmt.Errorf("kitchen ticket %d not found", ticketID)
+	}
+
+	var station models.KitchenStation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&station, ticket.KitchenStationID).Error; err != nil {
+		return fmt.Errorf("kitchen station %d not found", ticket.KitchenStationID)
+	}
+
+	payload, err := json.Marshal(ticket)
+	if err != nil {
+		return fmt.Errorf("failed to encode kitchen ticket: %w", err)
+	}
+
+	event := models.KitchenTicketEvent{
+		KitchenStationID: ticket.KitchenStationID,
+		KitchenTicketID:  ticket.ID,
+		Type:             eventType,
+		Payload:          string(payload),
+	}
+	if err := tx.Create(&event).Error; err != nil {
+		return fmt.Errorf("failed to create kitchen event: %w", err)
+	}
+
+	if err := tx.Exec("SELECT pg_notify(?, ?)", kitchenChannel, strconv.FormatUint(uint64(ticket.KitchenStationID), 10)).Error; err != nil {
+		return fmt.Errorf("failed to notify kitchen event: %w", err)
+	}
+
+	return nil
+}
+
+// RecordKitchenEvent registra un evento de ticket fuera de una transacción de servicio
+// (ej: cambios de estado desde el KDS)
+func RecordKitchenEvent(ticketID uint, eventType string) error {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := recordKitchenEvent(tx, ticketID, eventType); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// openTicketIDs obtiene los tickets de una orden que la cocina aún no entregó, por estación
+// (mismo orden de bloqueo que fireKitchenTickets)
+func openTicketIDs(tx *gorm.DB, orderID uint) ([]uint, error) {
+	var ticketIDs []uint
+	if err := tx.Model(&models.KitchenTicket{}).
+		Where("order_id = ? AND state IN ?", orderID, []string{"pending", "preparing", "ready"}).
+		Order("kitchen_station_id asc, id asc").
+		Pluck("id", &ticketIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to load kitchen tickets: %w", err)
+	}
+	return ticketIDs, nil
+}
+
+// recordTicketsEvent registra el mismo evento para varios tickets
+func recordTicketsEvent(tx *gorm.DB, ticketIDs []uint, eventType string) error {
+	for _, ticketID := range ticketIDs {
+		if err := recordKitchenEvent(tx, ticketID, eventType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// voidOrderTickets retira de cocina los tickets pendientes de una orden cancelada
+func voidOrderTickets(tx *gorm.DB, orderID uint) error {
+	ticketIDs, err := openTicketIDs(tx, orderID)
+	if err != nil || len(ticketIDs) == 0 {
+		return err
+	}
+
+	if err := tx.Model(&models.KitchenTicket{}).Where("id IN ?", ticketIDs).Update("state", "voided").Error; err != nil {
+		return fmt.Errorf("failed to void kitchen tickets: %w", err)
+	}
+
+	return recordTicketsEvent(tx, ticketIDs, KitchenEventVoided)
+}
+
+// KitchenEventsSince obtiene los eventos de una estación posteriores a afterID
+func KitchenEventsSince(stationID, afterID uint, limit int) ([]models.KitchenTicketEvent, error) {
+	var events []models.KitchenTicketEvent
+	if err := config.DB.Where("kitchen_station_id = ? AND id > ?", stationID, afterID).
+		Order("id asc").
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to load kitchen events: %w", err)
+	}
+	return events, nil
+}
+
+// LastKitchenEventID devuelve el último evento de una estación (punto de partida de un stream nuevo)
+func LastKitchenEventID(stationID uint) (uint, error) {
+	var lastID uint
+	if err := config.DB.Model(&models.KitchenTicketEvent{}).
+		Where("kitchen_station_id = ?", stationID).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&lastID).Error; err != nil {
+		return 0, fmt.Errorf("failed to load kitchen events: %w", err)
+	}
+	return lastID, nil
+}
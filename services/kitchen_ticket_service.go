@@ -0,0 +1,135 @@
+package services
+
+import (
+	"b-resto/models"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// fireKitchenTickets envía a cocina lo que cambió desde el último envío: agrupa por estación la
+// diferencia entre la cantidad de cada línea y la ya enviada (FiredQuantity) y crea un ticket
+// numerado por estación. Las líneas anuladas o reducidas generan cantidades negativas para que
+// la cocina las retire. Los productos sin estación no se envían.
+func (s *OrderService) fireKitchenTickets(tx *gorm.DB, orderID uint) ([]models.KitchenTicket, error) {
+	var items []models.OrderItem
+	if err := tx.Preload("Product.Template").Where("order_id = ?", orderID).Order("id asc").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to load order items: %w", err)
+	}
+
+	pending := map[uint][]models.KitchenTicketItem{}
+	fired := map[uint]float64{}
+	for _, item := range items {
+		if item.Product == nil || item.Product.Template == nil || item.Product.Template.KitchenStationID == nil {
+			continue
+		}
+
+		target := item.Quantity
+		if item.State == "voided" {
+			target = 0
+		}
+		delta := target - item.FiredQuantity
+		if math.Abs(delta) < quantityEpsilon {
+			continue
+		}
+
+		stationID := *item.Product.Template.KitchenStationID
+		pending[stationID] = append(pending[stationID], models.KitchenTicketItem{
+			OrderItemID: item.ID,
+			Quantity:    delta,
+		})
+		fired[item.ID] = target
+	}
+
+	stationIDs := make([]uint, 0, len(pending))
+	for stationID := range pending {
+		stationIDs = append(stationIDs, stationID)
+	}
+	sort.Slice(stationIDs, func(i, j int) bool { return stationIDs[i] < stationIDs[j] })
+
+	tickets := make([]models.KitchenTicket, 0, len(stationIDs))
+	for _, stationID := range stationIDs {
+		number, err := nextTicketNumber(tx, stationID)
+		if err != nil {
+			return nil, err
+		}
+
+		ticket := models.KitchenTicket{
+			OrderID:          orderID,
+			KitchenStationID: stationID,
+			TicketNumber:     number,
+			State:            "pending",
+			CreatedDate:      time.Now(),
+			Items:            pending[stationID],
+		}
+		if err := tx.Create(&ticket).Error; err != nil {
+			return nil, fmt.Errorf("failed to create kitchen ticket: %w", err)
+		}
+
+		// Un ticket que solo retira cantidades es una anulación para la cocina
+		eventType := KitchenEventVoided
+		for _, item := range ticket.Items {
+			if item.Quantity > 0 {
+				eventType = KitchenEventCreated
+				break
+			}
+		}
+		if err := recordKitchenEvent(tx, ticket.ID, eventType); err != nil {
+			return nil, err
+		}
+		// Las estaciones sin impresora trabajan solo con el KDS
+		if _, err := queueKitchenTicketPrint(tx, ticket.ID, false, nil); err != nil && !errors.Is(err, ErrNoPrinter) {
+			return nil, err
+		}
+		tickets = append(tickets, ticket)
+	}
+
+	for itemID, quantity := range fired {
+		if err := tx.Model(&models.OrderItem{}).Where("id = ?", itemID).Update("fired_quantity", quantity).Error; err != nil {
+			return nil, fmt.Errorf("failed to update order item %d: %w", itemID, err)
+		}
+	}
+
+	return tickets, nil
+}
+
+// fireIfConfirmed envía a cocina los cambios de una orden ya confirmada (las órdenes en borrador
+// se envían al confirmarse)
+func (s *OrderService) fireIfConfirmed(tx *gorm.DB, order *models.Order) error {
+	if order.State != OrderStateConfirmed {
+		return nil
+	}
+	_, err := s.fireKitchenTickets(tx, order.ID)
+	return err
+}
+
+// nextTicketNumber toma el siguiente número de ticket de la estación (bloquea la estación hasta
+// el fin de la transacción para no repetir números)
+func nextTicketNumber(tx *gorm.DB, stationID uint) (string, error) {
+	var station models.KitchenStation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&station, stationID).Error; err != nil {
+		return "", fmt.Errorf("kitchen station %d not found", stationID)
+	}
+
+	number := station.NextTicketNumber
+	if number < 1 {
+		number = 1
+	}
+	if err := tx.Model(&station).Update("next_ticket_number", number+1).Error; err != nil {
+		return "", fmt.Errorf("failed to update kitchen station: %w", err)
+	}
+
+	return fmt.Sprintf("%04d", number), nil
+}
+
+// splitFired reparte la cantidad ya enviada a cocina al separar una línea: la parte separada se
+// lleva lo enviado hasta su cantidad, así la división no genera tickets nuevos
+func splitFired(item *models.OrderItem, quantity float64) (remaining, moved float64) {
+	moved = math.Min(quantity, item.FiredQuantity)
+	return item.FiredQuantity - moved, moved
+}
@@ -0,0 +1,480 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/utils"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Formas de dividir una orden en subcuentas
+const (
+	CheckSplitItems = "items" // Cada subcuenta recibe líneas (o fracciones de línea)
+	CheckSplitSeats = "seats" // Una subcuenta por asiento; lo compartido se reparte entre todos
+	CheckSplitEven  = "even"  // El total se reparte en partes iguales
+)
+
+// CheckItemShare - Cantidad de una línea asignada a una subcuenta
+type CheckItemShare struct {
+	OrderItemID uint    `json:"order_item_id" binding:"required"`
+	Quantity    float64 `json:"quantity" binding:"required,gt=0"`
+}
+
+// CheckSplit - Cómo dividir la orden
+type CheckSplit struct {
+	Mode   string             // items, seats, even
+	Parts  int                // Número de partes (even)
+	Checks [][]CheckItemShare // Líneas de cada subcuenta (items)
+}
+
+// checkDraft es una subcuenta calculada antes de guardarla
+type checkDraft struct {
+	name   string
+	seat   *int
+	amount float64
+	items  []models.OrderCheckItem
+}
+
+// splittableOrder bloquea la orden y verifica que siga abierta
+func (s *OrderService) splittableOrder(tx *gorm.DB, orderID uint) (*models.Order, error) {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		return nil, errors.New("order not found")
+	}
+
+	if order.State != OrderStateDraft && order.State != OrderStateConfirmed {
+		return nil, fmt.Errorf("order is %s and can no longer be split", order.State)
+	}
+
+	return &order, nil
+}
+
+// SplitChecks divide una orden abierta en subcuentas (reemplaza una división anterior sin pagos)
+func (s *OrderService) SplitChecks(orderID uint, split CheckSplit) ([]models.OrderCheck, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	order, err := s.splittableOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := s.clearChecks(tx, order.ID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Un pago sobre la orden entera no pertenece a ninguna subcuenta y se cobraría dos veces
+	var orderPayments int64
+	if err := tx.Model(&models.OrderPayment{}).
+		Where("order_id = ? AND order_check_id IS NULL", order.ID).
+		Count(&orderPayments).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to check payments: %w", err)
+	}
+	if orderPayments > 0 {
+		tx.Rollback()
+		return nil, errors.New("order already has payments, remove them before splitting it into checks")
+	}
+
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ? AND state <> ?", order.ID, "voided").Order("id asc").Find(&items).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to load order items: %w", err)
+	}
+	if len(items) == 0 {
+		tx.Rollback()
+		return nil, errors.New("order has no items")
+	}
+
+	var drafts []*checkDraft
+	switch split.Mode {
+	case CheckSplitItems:
+		drafts, err = splitByItems(items, split.Checks)
+	case CheckSplitSeats:
+		drafts, err = splitBySeats(items)
+	case CheckSplitEven:
+		drafts, err = splitEvenly(order.TotalAmount, split.Parts)
+	default:
+		err = fmt.Errorf("unknown split mode %q", split.Mode)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	checks := make([]models.OrderCheck, 0, len(drafts))
+	for i, draft := range drafts {
+		check := models.OrderCheck{
+			OrderID:  order.ID,
+			Sequence: i + 1,
+			Name:     draft.name,
+			Seat:     draft.seat,
+			Amount:   utils.Round(draft.amount, 2),
+			State:    "open",
+			Items:    draft.items,
+		}
+		if err := tx.Create(&check).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to create order check: %w", err)
+		}
+		checks = append(checks, check)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return checks, nil
+}
+
+// RemoveChecks deshace la división de una orden (solo si ninguna subcuenta tiene pagos)
+func (s *OrderService) RemoveChecks(orderID uint) error {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err := s.splittableOrder(tx, orderID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := s.clearChecks(tx, orderID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// clearChecks elimina las subcuentas de una orden; falla si alguna ya recibió pagos
+func (s *OrderService) clearChecks(tx *gorm.DB, orderID uint) error {
+	var paid int64
+	if err := tx.Model(&models.OrderPayment{}).
+		Where("order_id = ? AND order_check_id IS NOT NULL", orderID).
+		Count(&paid).Error; err != nil {
+		return fmt.Errorf("failed to check payments: %w", err)
+	}
+	if paid > 0 {
+		return errors.New("order checks already have payments, remove them before changing the split")
+	}
+
+	checkIDs := tx.Model(&models.OrderCheck{}).Select("id").Where("order_id = ?", orderID)
+	if err := tx.Unscoped().Where("order_check_id IN (?)", checkIDs).Delete(&models.OrderCheckItem{}).Error; err != nil {
+		return fmt.Errorf("failed to clear order check items: %w", err)
+	}
+	if err := tx.Unscoped().Where("order_id = ?", orderID).Delete(&models.OrderCheck{}).Error; err != nil {
+		return fmt.Errorf("failed to clear order checks: %w", err)
+	}
+
+	return nil
+}
+
+// PayCheck registra un pago sobre una subcuenta abierta, en la sesión de caja que cobra (con
+// conversión de moneda y vuelto como en AddPayment)
+func (s *OrderService) PayCheck(orderID, checkID uint, payment *models.OrderPayment) error {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	order, err := s.splittableOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	check, err := s.openCheck(tx, orderID, checkID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	session, err := s.sessionFor(tx, order, payment.POSSessionID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	payment.ID = 0
+	payment.POSSessionID = &session.ID
+	payment.OrderID = orderID
+	payment.OrderCheckID = &check.ID
+	payment.RefundID = nil
+	if payment.PaymentDate.IsZero() {
+		payment.PaymentDate = time.Now()
+	}
+
+	var paid float64
+	if err := tx.Model(&models.OrderPayment{}).
+		Where("order_check_id = ?", check.ID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&paid).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to sum check payments: %w", err)
+	}
+	if err := applyTender(tx, payment, session, check.Amount-paid); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Create(payment).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to create payment: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// CloseCheck cierra una subcuenta cuyos pagos cubren su importe
+func (s *OrderService) CloseCheck(orderID, checkID uint) (*models.OrderCheck, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err := s.splittableOrder(tx, orderID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	check, err := s.openCheck(tx, orderID, checkID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var paid float64
+	if err := tx.Model(&models.OrderPayment{}).
+		Where("order_check_id = ?", check.ID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&paid).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to sum check payments: %w", err)
+	}
+	if paid+0.005 < check.Amount {
+		tx.Rollback()
+		return nil, fmt.Errorf("payments (%.2f) do not cover the check amount (%.2f)", paid, check.Amount)
+	}
+
+	now := time.Now()
+	check.State = "done"
+	check.ClosedAt = &now
+	if err := tx.Model(check).Updates(map[string]interface{}{
+		"state":     check.State,
+		"closed_at": check.ClosedAt,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to close order check: %w", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	config.DB.Preload("Items").Preload("Payments").First(check, check.ID)
+	return check, nil
+}
+
+// openCheck obtiene una subcuenta de la orden que aún no se cerró
+func (s *OrderService) openCheck(tx *gorm.DB, orderID, checkID uint) (*models.OrderCheck, error) {
+	var check models.OrderCheck
+	if err := tx.Where("order_id = ?", orderID).First(&check, checkID).Error; err != nil {
+		return nil, errors.New("order check not found")
+	}
+
+	if check.State != "open" {
+		return nil, errors.New("order check is already closed")
+	}
+
+	return &check, nil
+}
+
+// splitByItems arma una subcuenta por grupo de líneas. Cada línea activa debe quedar asignada
+// completa; el importe de una línea fraccionada se reparte en proporción a la cantidad.
+func splitByItems(items []models.OrderItem, groups [][]CheckItemShare) ([]*checkDraft, error) {
+	if len(groups) < 2 {
+		return nil, errors.New("at least two checks are required")
+	}
+
+	byID := map[uint]*models.OrderItem{}
+	for i := range items {
+		byID[items[i].ID] = &items[i]
+	}
+
+	assigned := map[uint]float64{}
+	for _, group := range groups {
+		if len(group) == 0 {
+			return nil, errors.New("every check needs at least one item")
+		}
+		for _, share := range group {
+			if _, ok := byID[share.OrderItemID]; !ok {
+				return nil, fmt.Errorf("order item %d not found or voided", share.OrderItemID)
+			}
+			if share.Quantity <= 0 {
+				return nil, fmt.Errorf("order item %d: quantity must be greater than zero", share.OrderItemID)
+			}
+			assigned[share.OrderItemID] += share.Quantity
+		}
+	}
+
+	for _, item := range items {
+		if diff := item.Quantity - assigned[item.ID]; diff > quantityEpsilon || diff < -quantityEpsilon {
+			return nil, fmt.Errorf("order item %d: assigned %.4g of %.4g", item.ID, assigned[item.ID], item.Quantity)
+		}
+	}
+
+	drafts := make([]*checkDraft, len(groups))
+	for i, group := range groups {
+		drafts[i] = &checkDraft{name: fmt.Sprintf("Cuenta %d", i+1)}
+		for _, share := range group {
+			drafts[i].items = append(drafts[i].items, models.OrderCheckItem{
+				OrderItemID: share.OrderItemID,
+				Quantity:    share.Quantity,
+			})
+		}
+	}
+
+	distributeItemAmounts(byID, drafts)
+	return drafts, nil
+}
+
+// splitBySeats arma una subcuenta por asiento; las líneas compartidas (asiento 0) se dividen
+// en partes iguales entre todos los asientos
+func splitBySeats(items []models.OrderItem) ([]*checkDraft, error) {
+	var seats []int
+	bySeat := map[int]*checkDraft{}
+	var shared []models.OrderItem
+
+	for _, item := range items {
+		if item.Seat <= 0 {
+			shared = append(shared, item)
+			continue
+		}
+
+		draft, ok := bySeat[item.Seat]
+		if !ok {
+			seat := item.Seat
+			draft = &checkDraft{name: fmt.Sprintf("Asiento %d", seat), seat: &seat}
+			bySeat[seat] = draft
+			seats = append(seats, seat)
+		}
+		draft.items = append(draft.items, models.OrderCheckItem{OrderItemID: item.ID, Quantity: item.Quantity})
+	}
+
+	if len(seats) < 2 {
+		return nil, errors.New("at least two seats with items are required to split by seat")
+	}
+	sort.Ints(seats)
+
+	drafts := make([]*checkDraft, 0, len(seats))
+	for _, seat := range seats {
+		drafts = append(drafts, bySeat[seat])
+	}
+
+	for _, item := range shared {
+		part := item.Quantity / float64(len(drafts))
+		for _, draft := range drafts {
+			draft.items = append(draft.items, models.OrderCheckItem{OrderItemID: item.ID, Quantity: utils.Round(part, 4)})
+		}
+	}
+
+	byID := map[uint]*models.OrderItem{}
+	for i := range items {
+		byID[items[i].ID] = &items[i]
+	}
+	distributeItemAmounts(byID, drafts)
+	return drafts, nil
+}
+
+// splitEvenly reparte el total en partes iguales en céntimos; los céntimos sobrantes se reparten
+// de uno en uno entre las primeras partes, así ninguna difiere de otra en más de un céntimo
+func splitEvenly(total float64, parts int) ([]*checkDraft, error) {
+	if parts < 2 {
+		return nil, errors.New("at least two parts are required")
+	}
+
+	cents := int64(math.Round(total * 100))
+	if int64(parts) > cents {
+		return nil, fmt.Errorf("cannot split %.2f into %d parts: each part must be at least 0.01", total, parts)
+	}
+
+	share := cents / int64(parts)
+	leftover := cents % int64(parts)
+	drafts := make([]*checkDraft, parts)
+	for i := range drafts {
+		amount := share
+		if int64(i) < leftover {
+			amount++
+		}
+		drafts[i] = &checkDraft{name: fmt.Sprintf("Cuenta %d", i+1), amount: float64(amount) / 100}
+	}
+
+	return drafts, nil
+}
+
+// distributeItemAmounts reparte el total con impuestos de cada línea entre sus fracciones
+// (la última fracción absorbe el redondeo) y suma el importe de cada subcuenta
+func distributeItemAmounts(items map[uint]*models.OrderItem, drafts []*checkDraft) {
+	remaining := map[uint]float64{}
+	pending := map[uint]int{}
+	for id, item := range items {
+		remaining[id] = item.PriceTotal
+	}
+	for _, draft := range drafts {
+		for _, share := range draft.items {
+			pending[share.OrderItemID]++
+		}
+	}
+
+	for _, draft := range drafts {
+		for i := range draft.items {
+			share := &draft.items[i]
+			item := items[share.OrderItemID]
+
+			pending[item.ID]--
+			if pending[item.ID] == 0 {
+				share.Amount = utils.Round(remaining[item.ID], 2)
+			} else {
+				share.Amount = utils.Round(item.PriceTotal*share.Quantity/item.Quantity, 2)
+			}
+			remaining[item.ID] -= share.Amount
+			draft.amount += share.Amount
+		}
+	}
+}
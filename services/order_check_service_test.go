@@ -0,0 +1,74 @@
+package services
+
+import (
+	"b-resto/testutil"
+	"strings"
+	"testing"
+)
+
+func TestSplitEvenlySpreadsLeftoverCents(t *testing.T) {
+	drafts, err := splitEvenly(100, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []float64{33.34, 33.33, 33.33}
+	for i, draft := range drafts {
+		if draft.amount != want[i] {
+			t.Fatalf("part %d = %.2f, want %.2f", i+1, draft.amount, want[i])
+		}
+	}
+
+	drafts, err = splitEvenly(0.05, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := 0.0
+	for _, draft := range drafts {
+		if draft.amount < 0.01 {
+			t.Fatalf("part of %.2f below one cent", draft.amount)
+		}
+		sum += draft.amount
+	}
+	if sum < 0.0499 || sum > 0.0501 {
+		t.Fatalf("parts add up to %.4f, want 0.05", sum)
+	}
+}
+
+func TestSplitEvenlyRejectsMorePartsThanCents(t *testing.T) {
+	if _, err := splitEvenly(0.03, 4); err == nil {
+		t.Fatal("split 0.03 into 4 parts")
+	}
+	if _, err := splitEvenly(0, 2); err == nil {
+		t.Fatal("split a zero total")
+	}
+}
+
+// TestSplitChecksRejectsOrderPayments verifica que una orden con pagos a nivel de orden no se divide:
+// las subcuentas volverían a cobrar el total completo.
+func TestSplitChecksRejectsOrderPayments(t *testing.T) {
+	db := testutil.OpenDB(t)
+	f := newSaleFixture(t, db)
+	service := NewOrderService()
+
+	order := f.newOrder(t, "SO/0001", 2)
+	payment := f.cashPayment(5)
+	if err := service.AddPayment(order.ID, payment); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.SplitChecks(order.ID, CheckSplit{Mode: CheckSplitEven, Parts: 2}); err == nil || !strings.Contains(err.Error(), "already has payments") {
+		t.Fatalf("split of a partly paid order error = %v", err)
+	}
+
+	if err := service.DeletePayment(order.ID, payment.ID); err != nil {
+		t.Fatal(err)
+	}
+	checks, err := service.SplitChecks(order.ID, CheckSplit{Mode: CheckSplitEven, Parts: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 2 || checks[0].Amount+checks[1].Amount != 20 {
+		t.Fatalf("checks = %+v, want two checks adding up to 20.00", checks)
+	}
+}
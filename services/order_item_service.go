@@ -0,0 +1,306 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderItemUpdate - Cambios permitidos sobre una línea existente
+type OrderItemUpdate struct {
+	Quantity     *float64
+	ProductNotes *string
+	Seat         *int
+}
+
+// editableOrder bloquea la orden y verifica que sus líneas aún se puedan modificar
+func (s *OrderService) editableOrder(tx *gorm.DB, orderID uint) (*models.Order, error) {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		return nil, errors.New("order not found")
+	}
+
+	if order.State != OrderStateDraft && order.State != OrderStateConfirmed {
+		return nil, fmt.Errorf("order is %s and its items can no longer be modified", order.State)
+	}
+
+	// Las subcuentas se calcularon con las líneas actuales
+	var checks int64
+	if err := tx.Model(&models.OrderCheck{}).Where("order_id = ?", orderID).Count(&checks).Error; err != nil {
+		return nil, fmt.Errorf("failed to check order split: %w", err)
+	}
+	if checks > 0 {
+		return nil, errors.New("order is split into checks, undo the split before changing its items")
+	}
+
+	return &order, nil
+}
+
+// activeItem obtiene una línea no anulada de la orden
+func (s *OrderService) activeItem(tx *gorm.DB, orderID, itemID uint) (*models.OrderItem, error) {
+	var item models.OrderItem
+	if err := tx.Preload("Taxes").Where("order_id = ?", orderID).First(&item, itemID).Error; err != nil {
+		return nil, errors.New("order item not found")
+	}
+
+	if item.State == "voided" {
+		return nil, errors.New("order item is voided")
+	}
+
+	return &item, nil
+}
+
+// AddItem agrega una línea a una orden abierta con el precio del producto y recalcula los totales
+func (s *OrderService) AddItem(orderID uint, item *models.OrderItem) error {
+	if item.Quantity <= 0 {
+		return errors.New("quantity must be greater than zero")
+	}
+
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	order, err := s.editableOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	item.ID = 0
+	item.OrderID = orderID
+	item.State = "active"
+	items := []models.OrderItem{*item}
+	if err := s.prepareItems(tx, items); err != nil {
+		tx.Rollback()
+		return err
+	}
+	*item = items[0]
+
+	if err := tx.Create(item).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to create order item: %w", err)
+	}
+
+	if err := s.ComputeTotals(tx, orderID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := s.fireIfConfirmed(tx, order); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// UpdateItem cambia la cantidad o las notas de una línea y recalcula los totales
+func (s *OrderService) UpdateItem(orderID, itemID uint, update OrderItemUpdate) (*models.OrderItem, error) {
+	if update.Quantity != nil && *update.Quantity <= 0 {
+		return nil, errors.New("quantity must be greater than zero, void the item instead")
+	}
+	if update.Seat != nil && *update.Seat < 0 {
+		return nil, errors.New("seat cannot be negative")
+	}
+
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	order, err := s.editableOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	item, err := s.activeItem(tx, orderID, itemID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	changes := map[string]interface{}{}
+	if update.Quantity != nil {
+		changes["quantity"] = *update.Quantity
+	}
+	if update.ProductNotes != nil {
+		changes["product_notes"] = *update.ProductNotes
+	}
+	if update.Seat != nil {
+		changes["seat"] = *update.Seat
+	}
+	if len(changes) > 0 {
+		if err := tx.Model(item).Updates(changes).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to update order item: %w", err)
+		}
+	}
+
+	if err := s.ComputeTotals(tx, orderID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := s.fireIfConfirmed(tx, order); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	config.DB.Preload("Taxes").First(item, item.ID)
+	return item, nil
+}
+
+// VoidItem anula una línea (queda registrada con el motivo) y recalcula los totales
+func (s *OrderService) VoidItem(orderID, itemID uint, reason string, voidedBy *uint) (*models.OrderItem, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	order, err := s.editableOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	item, err := s.activeItem(tx, orderID, itemID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	now := time.Now()
+	item.State = "voided"
+	item.VoidReason = reason
+	item.VoidedBy = voidedBy
+	item.VoidedAt = &now
+	if err := tx.Model(item).Updates(map[string]interface{}{
+		"state":       item.State,
+		"void_reason": item.VoidReason,
+		"voided_by":   item.VoidedBy,
+		"voided_at":   item.VoidedAt,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to void order item: %w", err)
+	}
+
+	if err := s.ComputeTotals(tx, orderID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := s.fireIfConfirmed(tx, order); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// SplitItem separa parte de la cantidad de una línea en una línea nueva con el mismo producto,
+// precio, notas e impuestos (ej: para cobrar o anular por separado)
+func (s *OrderService) SplitItem(orderID, itemID uint, quantity float64) (*models.OrderItem, *models.OrderItem, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	order, err := s.editableOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	item, err := s.activeItem(tx, orderID, itemID)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	if quantity <= 0 || quantity >= item.Quantity {
+		tx.Rollback()
+		return nil, nil, fmt.Errorf("split quantity must be between 0 and %.2f", item.Quantity)
+	}
+
+	remainingFired, movedFired := splitFired(item, quantity)
+	if err := tx.Model(item).Updates(map[string]interface{}{
+		"quantity":       item.Quantity - quantity,
+		"fired_quantity": remainingFired,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to update order item: %w", err)
+	}
+
+	newItem := &models.OrderItem{
+		OrderID:       item.OrderID,
+		ProductID:     item.ProductID,
+		Quantity:      quantity,
+		PriceUnit:     item.PriceUnit,
+		ProductNotes:  item.ProductNotes,
+		Seat:          item.Seat,
+		FiredQuantity: movedFired,
+		State:         "active",
+		Taxes:         item.Taxes,
+	}
+	if err := tx.Create(newItem).Error; err != nil {
+		tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to create order item: %w", err)
+	}
+
+	if err := s.ComputeTotals(tx, orderID); err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	if err := s.fireIfConfirmed(tx, order); err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, nil, err
+	}
+
+	config.DB.Preload("Taxes").First(item, item.ID)
+	config.DB.Preload("Taxes").First(newItem, newItem.ID)
+	return item, newItem, nil
+}
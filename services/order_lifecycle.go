@@ -0,0 +1,293 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Estados de una orden de venta
+const (
+	OrderStateDraft     = "draft"
+	OrderStateConfirmed = "confirmed"
+	OrderStateDone      = "done"
+	OrderStateCancelled = "cancelled"
+)
+
+// Códigos de error de transición
+const (
+	TransitionIllegal       = "illegal_transition"
+	TransitionNoItems       = "no_items"
+	TransitionUnpaid        = "unpaid"
+	TransitionHasPayments   = "has_payments"
+	TransitionNoOpenSession = "no_open_session"
+	TransitionInventory     = "inventory_error"
+	TransitionOpenChecks    = "open_checks"
+	TransitionKitchen       = "kitchen_error"
+)
+
+// orderTransitions define los cambios de estado permitidos
+var orderTransitions = map[string][]string{
+	OrderStateDraft:     {OrderStateConfirmed, OrderStateDone, OrderStateCancelled},
+	OrderStateConfirmed: {OrderStateDone, OrderStateCancelled},
+	OrderStateDone:      {OrderStateCancelled}, // Devuelve el inventario consumido
+	OrderStateCancelled: {},
+}
+
+// TransitionError - Cambio de estado rechazado (transición no permitida o precondición incumplida)
+type TransitionError struct {
+	OrderID uint   `json:"order_id"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *TransitionError) Error() string {
+	return e.Message
+}
+
+// CanTransition indica si el cambio de estado está permitido
+func CanTransition(from, to string) bool {
+	for _, state := range orderTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Confirm pasa la orden a confirmed (requiere líneas y una sesión de caja abierta) y envía a
+// cocina las líneas pendientes. Reconfirmar una orden confirmada solo envía la diferencia.
+func (s *OrderService) Confirm(orderID uint) (*models.Order, error) {
+	return s.transition(orderID, OrderStateConfirmed, func(tx *gorm.DB, order *models.Order) error {
+		if _, err := s.fireKitchenTickets(tx, order.ID); err != nil {
+			return &TransitionError{
+				OrderID: order.ID,
+				From:    order.State,
+				To:      OrderStateConfirmed,
+				Code:    TransitionKitchen,
+				Message: err.Error(),
+			}
+		}
+		return nil
+	})
+}
+
+// Cancel cancela la orden (sus pagos deben estar eliminados o reembolsados). Una orden abierta
+// retira de cocina sus tickets pendientes. Si ya estaba cerrada, devuelve al stock lo que
+// descontó la venta; con waste, lo devuelto se da de baja como merma.
+func (s *OrderService) Cancel(orderID uint, waste bool, cancelledBy *uint) (*models.Order, error) {
+	return s.transition(orderID, OrderStateCancelled, func(tx *gorm.DB, order *models.Order) error {
+		if order.State != OrderStateDone {
+			return voidOrderTickets(tx, order.ID)
+		}
+		return NewInventoryService().reverseOrder(tx, order, waste, cancelledBy)
+	})
+}
+
+// Complete cierra la orden: valida pagos y sesión, descuenta el inventario, encola el comprobante
+// y la pasa a done
+func (s *OrderService) Complete(orderID uint) (*models.Order, error) {
+	return s.transition(orderID, OrderStateDone, func(tx *gorm.DB, order *models.Order) error {
+		inventoryService := NewInventoryService()
+
+		// Almacén: orden → POS → compañía (las estaciones de cocina pueden sobrescribirlo por producto)
+		warehouseID, err := inventoryService.ResolveSaleWarehouse(tx, order)
+		if err != nil {
+			return err
+		}
+
+		if err := inventoryService.registerSale(tx, order.ID, order.Items, warehouseID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		order.WarehouseID = &warehouseID
+		order.CompletedAt = &now
+		if err := tx.Model(order).Updates(map[string]interface{}{
+			"warehouse_id": warehouseID,
+			"completed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Comprobante del cliente (si el terminal tiene impresora)
+		if _, err := queueReceiptPrint(tx, order.ID, false, nil); err != nil && !errors.Is(err, ErrNoPrinter) {
+			return err
+		}
+		return nil
+	})
+}
+
+// transition valida y aplica un cambio de estado en una transacción.
+// apply permite registrar los efectos del cambio (ej: inventario) antes de guardar el estado.
+func (s *OrderService) transition(orderID uint, to string, apply func(tx *gorm.DB, order *models.Order) error) (*models.Order, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("order %d not found", orderID)
+	}
+	if err := tx.Preload("Taxes").Where("order_id = ?", order.ID).Find(&order.Items).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to load order items: %w", err)
+	}
+
+	if err := s.checkTransition(tx, &order, to); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if apply != nil {
+		if err := apply(tx, &order); err != nil {
+			tx.Rollback()
+			if _, ok := err.(*TransitionError); ok {
+				return nil, err
+			}
+			return nil, &TransitionError{
+				OrderID: order.ID,
+				From:    order.State,
+				To:      to,
+				Code:    TransitionInventory,
+				Message: err.Error(),
+			}
+		}
+	}
+
+	if err := tx.Model(&order).Update("state", to).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to update order state: %w", err)
+	}
+	order.State = to
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+// checkTransition verifica que el cambio esté permitido y que se cumplan sus precondiciones
+func (s *OrderService) checkTransition(tx *gorm.DB, order *models.Order, to string) error {
+	fail := func(code, format string, args ...interface{}) error {
+		return &TransitionError{
+			OrderID: order.ID,
+			From:    order.State,
+			To:      to,
+			Code:    code,
+			Message: fmt.Sprintf(format, args...),
+		}
+	}
+
+	reconfirm := order.State == OrderStateConfirmed && to == OrderStateConfirmed
+	if !reconfirm && !CanTransition(order.State, to) {
+		return fail(TransitionIllegal, "order cannot go from %s to %s", order.State, to)
+	}
+
+	switch to {
+	case OrderStateConfirmed, OrderStateDone:
+		active := 0
+		for _, item := range order.Items {
+			if item.State != "voided" {
+				active++
+			}
+		}
+		if active == 0 {
+			return fail(TransitionNoItems, "order has no items")
+		}
+
+		session, err := s.openSession(tx, order)
+		if err != nil {
+			return err
+		}
+		if session == nil {
+			return fail(TransitionNoOpenSession, "there is no open cash session for this order")
+		}
+
+		if to == OrderStateDone {
+			paid, err := s.paidAmount(tx, order.ID)
+			if err != nil {
+				return err
+			}
+			if paid+0.005 < order.TotalAmount {
+				return fail(TransitionUnpaid, "payments (%.2f) do not cover the order total (%.2f)", paid, order.TotalAmount)
+			}
+
+			// Una orden dividida se cierra cuando todas sus subcuentas están cerradas
+			var openChecks int64
+			if err := tx.Model(&models.OrderCheck{}).
+				Where("order_id = ? AND state <> ?", order.ID, "done").
+				Count(&openChecks).Error; err != nil {
+				return fmt.Errorf("failed to check order checks: %w", err)
+			}
+			if openChecks > 0 {
+				return fail(TransitionOpenChecks, "order has %d unsettled checks", openChecks)
+			}
+		}
+
+	case OrderStateCancelled:
+		paid, err := s.paidAmount(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		if paid > 0.005 {
+			return fail(TransitionHasPayments, "order has %.2f in payments, remove or refund them before cancelling", paid)
+		}
+	}
+
+	return nil
+}
+
+// paidAmount suma los pagos registrados de una orden
+func (s *OrderService) paidAmount(tx *gorm.DB, orderID uint) (float64, error) {
+	var paid float64
+	if err := tx.Model(&models.OrderPayment{}).
+		Where("order_id = ?", orderID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&paid).Error; err != nil {
+		return 0, fmt.Errorf("failed to sum order payments: %w", err)
+	}
+	return paid, nil
+}
+
+// openSession obtiene la sesión de caja abierta del POS de la orden (o, sin POS, de algún
+// terminal de la compañía del diario). Devuelve nil si no hay ninguna.
+func (s *OrderService) openSession(tx *gorm.DB, order *models.Order) (*models.POSSession, error) {
+	query := tx.Where("status = ?", "open")
+
+	if order.POSID != nil {
+		query = query.Where("pos_id = ?", *order.POSID)
+	} else {
+		var journal models.Journal
+		if err := tx.First(&journal, order.JournalID).Error; err != nil {
+			return nil, fmt.Errorf("journal %d not found", order.JournalID)
+		}
+		query = query.Where("pos_id IN (?)", tx.Model(&models.POS{}).Select("id").Where("company_id = ?", journal.CompanyID))
+	}
+
+	var session models.POSSession
+	result := query.Order("opened_at desc").Limit(1).Find(&session)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to check cash session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &session, nil
+}
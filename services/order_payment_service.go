@@ -0,0 +1,157 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// Errores al registrar o eliminar un pago: ErrPaymentNotFound es un 404, los demás son conflictos (409)
+var (
+	ErrPaymentNotFound      = errors.New("payment not found in this order")
+	ErrPaymentOrderState    = errors.New("payments can only be added to or deleted from draft or confirmed orders")
+	ErrRefundPayment        = errors.New("refund payments cannot be deleted")
+	ErrPaymentCheckClosed   = errors.New("the payment belongs to a closed check")
+	ErrPaymentSessionClosed = errors.New("the payment belongs to a closed cash session")
+)
+
+// AddPayment registra un pago sobre una orden abierta (borrador o confirmada) sin subcuentas. El pago queda en la sesión de caja
+// que cobra: la indicada en el pago o la sesión abierta del terminal de la orden. Lo entregado en
+// otra moneda se convierte con el tipo de cambio del día y el excedente en efectivo es vuelto.
+func (s *OrderService) AddPayment(orderID uint, payment *models.OrderPayment) error {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// La orden se bloquea: ni se cierra ni se divide mientras se registra el pago
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("order %d not found", orderID)
+	}
+	if order.State != OrderStateDraft && order.State != OrderStateConfirmed {
+		tx.Rollback()
+		return ErrPaymentOrderState
+	}
+
+	// Una orden dividida se cobra por subcuenta
+	var checks int64
+	if err := tx.Model(&models.OrderCheck{}).Where("order_id = ?", order.ID).Count(&checks).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to check order checks: %w", err)
+	}
+	if checks > 0 {
+		tx.Rollback()
+		return errors.New("order is split into checks, pay each check instead")
+	}
+
+	session, err := s.sessionFor(tx, &order, payment.POSSessionID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	payment.ID = 0
+	payment.OrderID = order.ID
+	payment.OrderCheckID = nil
+	payment.RefundID = nil
+	payment.POSSessionID = &session.ID
+	if payment.PaymentDate.IsZero() {
+		payment.PaymentDate = time.Now()
+	}
+
+	paid, err := s.paidAmount(tx, order.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := applyTender(tx, payment, session, order.TotalAmount-paid); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Create(payment).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to create payment: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// DeletePayment elimina un pago de una orden en borrador o confirmada. La orden se bloquea para que
+// no se cierre mientras tanto y la sesión del pago se bloquea en modo compartido: lo cobrado en una
+// sesión que se está cerrando ya forma parte de su arqueo.
+func (s *OrderService) DeletePayment(orderID, paymentID uint) error {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("order %d not found", orderID)
+	}
+	if order.State != "draft" && order.State != "confirmed" {
+		tx.Rollback()
+		return ErrPaymentOrderState
+	}
+
+	var payment models.OrderPayment
+	result := tx.Where("id = ? AND order_id = ?", paymentID, order.ID).Limit(1).Find(&payment)
+	if result.Error != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to load payment: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return ErrPaymentNotFound
+	}
+
+	// El pago de una devolución forma parte de su nota de crédito
+	if payment.RefundID != nil {
+		tx.Rollback()
+		return ErrRefundPayment
+	}
+
+	// Una subcuenta cerrada conserva sus pagos
+	if payment.OrderCheckID != nil {
+		var check models.OrderCheck
+		if err := tx.First(&check, *payment.OrderCheckID).Error; err == nil && check.State == "done" {
+			tx.Rollback()
+			return ErrPaymentCheckClosed
+		}
+	}
+
+	if payment.POSSessionID != nil {
+		var session models.POSSession
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).First(&session, *payment.POSSessionID).Error; err == nil && session.Status != SessionStatusOpen {
+			tx.Rollback()
+			return ErrPaymentSessionClosed
+		}
+	}
+
+	if err := tx.Delete(&payment).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete payment: %w", err)
+	}
+
+	return tx.Commit().Error
+}
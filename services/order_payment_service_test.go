@@ -0,0 +1,44 @@
+package services
+
+import (
+	"b-resto/models"
+	"b-resto/testutil"
+	"errors"
+	"testing"
+)
+
+// TestAddPaymentRejectsClosedOrders verifica que una orden cerrada o cancelada ya no recibe pagos:
+// cambiaría una venta cerrada y el efectivo de la sesión.
+func TestAddPaymentRejectsClosedOrders(t *testing.T) {
+	db := testutil.OpenDB(t)
+	f := newSaleFixture(t, db)
+	service := NewOrderService()
+
+	order := f.newOrder(t, "SO/0001", 2)
+	if err := service.AddPayment(order.ID, f.cashPayment(20)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Complete(order.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.AddPayment(order.ID, f.cashPayment(5)); !errors.Is(err, ErrPaymentOrderState) {
+		t.Fatalf("payment on a done order error = %v, want ErrPaymentOrderState", err)
+	}
+
+	cancelled := f.newOrder(t, "SO/0002", 1)
+	if _, err := service.Cancel(cancelled.ID, false, &f.user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.AddPayment(cancelled.ID, f.cashPayment(10)); !errors.Is(err, ErrPaymentOrderState) {
+		t.Fatalf("payment on a cancelled order error = %v, want ErrPaymentOrderState", err)
+	}
+
+	var payments []models.OrderPayment
+	if err := db.Where("order_id IN ?", []uint{order.ID, cancelled.ID}).Find(&payments).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(payments) != 1 || payments[0].Amount != 20 {
+		t.Fatalf("payments = %+v, want only the 20.00 taken before completing", payments)
+	}
+}
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderService maneja la lógica de órdenes de venta (líneas, impuestos y totales)
+type OrderService struct{}
+
+// NewOrderService crea una nueva instancia del servicio
+func NewOrderService() *OrderService {
+	return &OrderService{}
+}
+
+// OrderLine - Línea enviada al crear o editar una orden. Sin ID es una línea nueva; con ID reemplaza
+// la cantidad, las notas y el asiento de una línea de la misma orden.
+type OrderLine struct {
+	ID           uint    `json:"id"`
+	ProductID    uint    `json:"product_id"`
+	Quantity     float64 `json:"quantity" binding:"gt=0"`
+	ProductNotes string  `json:"product_notes"`
+	Seat         int     `json:"seat" binding:"min=0"` // 0 = compartido
+}
+
+// OrderRequest - Cabecera y líneas de una orden. Pagos, subcuentas, impuestos y almacén no se
+// reciben del cliente: tienen sus propios flujos (AddPayment, SplitChecks, ComputeTotals, Complete).
+type OrderRequest struct {
+	JournalID    uint        `json:"journal_id"`
+	UserID       uint        `json:"user_id"`
+	TableID      *uint       `json:"table_id"`       // Solo al crear; luego MoveOrder
+	POSID        *uint       `json:"pos_id"`         // Solo al crear
+	POSSessionID *uint       `json:"pos_session_id"` // Solo al crear
+	Name         string      `json:"name"`
+	OrderDate    time.Time   `json:"order_date"`
+	Note         string      `json:"note"`
+	Lines        []OrderLine `json:"items" binding:"dive"`
+}
+
+// lineTax es el importe de un impuesto en una línea
+type lineTax struct {
+	Tax    models.Tax
+	Amount float64
+}
+
+// computeLine calcula el subtotal sin impuestos y el impuesto de cada tasa de una línea.
+// Los impuestos incluidos se extraen del precio; los no incluidos se suman sobre la base sin impuestos.
+// Cada importe se redondea a 2 decimales por línea; con impuestos incluidos el total de la línea
+// coincide siempre con cantidad × precio.
+func computeLine(quantity, priceUnit float64, taxes []models.Tax) (float64, []lineTax) {
+	amount := quantity * priceUnit
+
+	inclusiveRate := float64(0)
+	for _, tax := range taxes {
+		if tax.IsPriceInclusive {
+			inclusiveRate += tax.RatePercent
+		}
+	}
+
+	untaxed := amount / (1 + inclusiveRate/100)
+
+	lines := make([]lineTax, 0, len(taxes))
+	inclusiveAmount := float64(0)
+	for _, tax := range taxes {
+		taxAmount := utils.Round(untaxed*tax.RatePercent/100, 2)
+		if tax.IsPriceInclusive {
+			inclusiveAmount += taxAmount
+		}
+		lines = append(lines, lineTax{Tax: tax, Amount: taxAmount})
+	}
+
+	subtotal := utils.Round(untaxed, 2)
+	if inclusiveRate > 0 {
+		subtotal = utils.Round(amount, 2) - inclusiveAmount
+	}
+
+	return utils.Round(subtotal, 2), lines
+}
+
+// prepareItems completa las líneas nuevas: el precio se resuelve siempre en el servidor
+// (variante → template) y, si no se enviaron impuestos, se toman los activos del producto
+func (s *OrderService) prepareItems(tx *gorm.DB, items []models.OrderItem) error {
+	for i := range items {
+		if items[i].ID != 0 {
+			continue
+		}
+
+		// Una cantidad negativa se leería en cocina como anulación
+		if items[i].Quantity <= 0 {
+			return errors.New("quantity must be greater than zero")
+		}
+
+		var product models.ProductProduct
+		if err := tx.Preload("Template.Taxes", "is_active = ?", true).First(&product, items[i].ProductID).Error; err != nil {
+			return fmt.Errorf("product %d not found", items[i].ProductID)
+		}
+		if product.Template == nil {
+			return fmt.Errorf("product %d has no template", items[i].ProductID)
+		}
+
+		// Las líneas nuevas aún no se enviaron a cocina
+		items[i].FiredQuantity = 0
+
+		items[i].PriceUnit = product.Template.SalePrice
+		if product.SalePrice != nil {
+			items[i].PriceUnit = *product.SalePrice
+		}
+
+		if items[i].Taxes == nil {
+			items[i].Taxes = product.Template.Taxes
+			if items[i].Taxes == nil {
+				items[i].Taxes = []models.Tax{}
+			}
+		}
+	}
+
+	return nil
+}
+
+// CreateOrder crea la orden con sus líneas en la sesión de caja abierta y calcula sus totales
+func (s *OrderService) CreateOrder(request OrderRequest) (*models.Order, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Las órdenes nacen en borrador; el estado solo cambia por el ciclo de vida
+	order := &models.Order{
+		JournalID:    request.JournalID,
+		UserID:       request.UserID,
+		TableID:      request.TableID,
+		POSID:        request.POSID,
+		POSSessionID: request.POSSessionID,
+		Name:         request.Name,
+		State:        OrderStateDraft,
+		OrderDate:    request.OrderDate,
+		Note:         request.Note,
+	}
+	if order.OrderDate.IsZero() {
+		order.OrderDate = time.Now()
+	}
+
+	// Toda orden se abre en una sesión de caja abierta (por defecto la de su terminal)
+	session, err := s.sessionFor(tx, order, order.POSSessionID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	order.POSSessionID = &session.ID
+	if order.POSID == nil {
+		order.POSID = &session.POSID
+	}
+
+	// Una mesa tiene a lo sumo una orden abierta: si ya tiene una se rechaza (unir con MergeOrders)
+	if order.TableID != nil {
+		if _, err := s.freeTable(tx, *order.TableID, 0); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Omit(clause.Associations).Create(order).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	if err := s.saveLines(tx, order.ID, request.Lines); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := s.ComputeTotals(tx, order.ID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// UpdateOrder actualiza la cabecera y las líneas enviadas de una orden abierta y recalcula sus totales.
+// El estado no se edita directamente: usar Confirm, Cancel o Complete. La mesa tampoco: usar
+// MoveOrder, que verifica que la mesa destino esté libre. El terminal y la sesión quedan fijados al crear.
+func (s *OrderService) UpdateOrder(orderID uint, request OrderRequest) error {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	current, err := s.editableOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	changes := map[string]interface{}{}
+	if request.JournalID != 0 {
+		changes["journal_id"] = request.JournalID
+	}
+	if request.UserID != 0 {
+		changes["user_id"] = request.UserID
+	}
+	if request.Name != "" {
+		changes["name"] = request.Name
+	}
+	if !request.OrderDate.IsZero() {
+		changes["order_date"] = request.OrderDate
+	}
+	if request.Note != "" {
+		changes["note"] = request.Note
+	}
+	if len(changes) > 0 {
+		if err := tx.Model(&models.Order{}).Where("id = ?", current.ID).Updates(changes).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to update order: %w", err)
+		}
+	}
+
+	if err := s.saveLines(tx, current.ID, request.Lines); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := s.ComputeTotals(tx, current.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := s.fireIfConfirmed(tx, current); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// saveLines escribe las líneas enviadas de una orden. Las nuevas toman el precio y los impuestos del
+// producto; las existentes deben ser líneas activas de la misma orden y solo cambian cantidad, notas y asiento.
+func (s *OrderService) saveLines(tx *gorm.DB, orderID uint, lines []OrderLine) error {
+	var items []models.OrderItem
+	for _, line := range lines {
+		if line.Seat < 0 {
+			return errors.New("seat cannot be negative")
+		}
+
+		if line.ID == 0 {
+			items = append(items, models.OrderItem{
+				OrderID:      orderID,
+				ProductID:    line.ProductID,
+				Quantity:     line.Quantity,
+				ProductNotes: line.ProductNotes,
+				Seat:         line.Seat,
+				State:        "active",
+			})
+			continue
+		}
+
+		if line.Quantity <= 0 {
+			return errors.New("quantity must be greater than zero, void the item instead")
+		}
+		item, err := s.activeItem(tx, orderID, line.ID)
+		if err != nil {
+			return fmt.Errorf("order item %d does not belong to order %d: %w", line.ID, orderID, err)
+		}
+		if err := tx.Model(item).Updates(map[string]interface{}{
+			"quantity":      line.Quantity,
+			"product_notes": line.ProductNotes,
+			"seat":          line.Seat,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update order item %d: %w", item.ID, err)
+		}
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	if err := s.prepareItems(tx, items); err != nil {
+		return err
+	}
+	if err := tx.Create(&items).Error; err != nil {
+		return fmt.Errorf("failed to create order items: %w", err)
+	}
+
+	return nil
+}
+
+// ComputeTotals recalcula las líneas, el desglose de impuestos y los totales de la orden
+func (s *OrderService) ComputeTotals(tx *gorm.DB, orderID uint) error {
+	var order models.Order
+	if err := tx.Preload("Items.Taxes").First(&order, orderID).Error; err != nil {
+		return fmt.Errorf("order %d not found", orderID)
+	}
+
+	var breakdown []*models.OrderTax
+	byTax := map[uint]*models.OrderTax{}
+	untaxed := float64(0)
+	taxTotal := float64(0)
+
+	for _, item := range order.Items {
+		// Las líneas anuladas conservan sus importes pero no suman
+		if item.State == "voided" {
+			continue
+		}
+
+		subtotal, taxes := computeLine(item.Quantity, item.PriceUnit, item.Taxes)
+
+		priceTax := float64(0)
+		for _, line := range taxes {
+			priceTax += line.Amount
+
+			orderTax, ok := byTax[line.Tax.ID]
+			if !ok {
+				orderTax = &models.OrderTax{
+					OrderID:          order.ID,
+					TaxID:            line.Tax.ID,
+					Name:             line.Tax.Name,
+					RatePercent:      line.Tax.RatePercent,
+					IsPriceInclusive: line.Tax.IsPriceInclusive,
+				}
+				byTax[line.Tax.ID] = orderTax
+				breakdown = append(breakdown, orderTax)
+			}
+			orderTax.Base += subtotal
+			orderTax.Amount += line.Amount
+		}
+		priceTax = utils.Round(priceTax, 2)
+
+		if err := tx.Model(&models.OrderItem{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+			"price_subtotal": subtotal,
+			"price_tax":      priceTax,
+			"price_total":    utils.Round(subtotal+priceTax, 2),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update order item %d: %w", item.ID, err)
+		}
+
+		untaxed += subtotal
+		taxTotal += priceTax
+	}
+
+	if err := tx.Unscoped().Where("order_id = ?", order.ID).Delete(&models.OrderTax{}).Error; err != nil {
+		return fmt.Errorf("failed to clear order taxes: %w", err)
+	}
+	for _, orderTax := range breakdown {
+		orderTax.Base = utils.Round(orderTax.Base, 2)
+		orderTax.Amount = utils.Round(orderTax.Amount, 2)
+		if err := tx.Create(orderTax).Error; err != nil {
+			return fmt.Errorf("failed to create order tax: %w", err)
+		}
+	}
+
+	untaxed = utils.Round(untaxed, 2)
+	taxTotal = utils.Round(taxTotal, 2)
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+		"amount_untaxed": untaxed,
+		"amount_tax":     taxTotal,
+		"total_amount":   utils.Round(untaxed+taxTotal, 2),
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update order totals: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"b-resto/models"
+	"b-resto/testutil"
+	"testing"
+)
+
+// TestOrderLinesStayOnTheirOrder verifica que crear o editar una orden no acepta líneas de otra
+// orden: el upsert de GORM las movería de mesa.
+func TestOrderLinesStayOnTheirOrder(t *testing.T) {
+	db := testutil.OpenDB(t)
+	f := newSaleFixture(t, db)
+	service := NewOrderService()
+
+	first := f.newOrder(t, "SO/0001", 2)
+	second := f.newOrder(t, "SO/0002", 1)
+
+	var line models.OrderItem
+	if err := db.Where("order_id = ?", first.ID).First(&line).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.UpdateOrder(second.ID, OrderRequest{Lines: []OrderLine{{ID: line.ID, ProductID: f.product.ID, Quantity: 5}}}); err == nil {
+		t.Fatal("updated a line of another order")
+	}
+	if _, err := service.CreateOrder(OrderRequest{
+		JournalID: f.journal.ID,
+		UserID:    f.user.ID,
+		POSID:     &f.pos.ID,
+		Name:      "SO/0003",
+		Lines:     []OrderLine{{ID: line.ID, ProductID: f.product.ID, Quantity: 5}},
+	}); err == nil {
+		t.Fatal("created an order with a line of another order")
+	}
+
+	if err := db.First(&line, line.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if line.OrderID != first.ID || line.Quantity != 2 {
+		t.Fatalf("line %d moved to order %d with quantity %.2f", line.ID, line.OrderID, line.Quantity)
+	}
+
+	// Las líneas propias sí se editan y los totales se recalculan
+	if err := service.UpdateOrder(first.ID, OrderRequest{Lines: []OrderLine{{ID: line.ID, ProductID: f.product.ID, Quantity: 3}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(first, first.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if first.TotalAmount != 30 {
+		t.Fatalf("order total = %.2f, want 30.00", first.TotalAmount)
+	}
+
+	for _, quantity := range []float64{0, -1} {
+		if err := service.UpdateOrder(first.ID, OrderRequest{Lines: []OrderLine{{ProductID: f.product.ID, Quantity: quantity}}}); err == nil {
+			t.Fatalf("added a line with quantity %.0f", quantity)
+		}
+	}
+}
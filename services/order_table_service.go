@@ -0,0 +1,354 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ItemTransfer - Cantidad de una línea que se pasa a otra orden
+type ItemTransfer struct {
+	OrderItemID uint    `json:"order_item_id" binding:"required"`
+	Quantity    float64 `json:"quantity" binding:"required,gt=0"`
+}
+
+// lockTable bloquea la mesa (serializa los cambios de ocupación) y verifica que esté activa
+func (s *OrderService) lockTable(tx *gorm.DB, tableID uint) (*models.Table, error) {
+	var table models.Table
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&table, tableID).Error; err != nil {
+		return nil, fmt.Errorf("table %d not found", tableID)
+	}
+	if !table.IsActive {
+		return nil, fmt.Errorf("table %s is not active", table.Number)
+	}
+	return &table, nil
+}
+
+// freeTable bloquea la mesa y verifica que no tenga otra orden abierta
+func (s *OrderService) freeTable(tx *gorm.DB, tableID, exceptOrderID uint) (*models.Table, error) {
+	table, err := s.lockTable(tx, tableID)
+	if err != nil {
+		return nil, err
+	}
+
+	open, err := s.tableOrder(tx, tableID)
+	if err != nil {
+		return nil, err
+	}
+	if open != nil && open.ID != exceptOrderID {
+		return nil, fmt.Errorf("table %s already has the open order %s, merge the orders instead", table.Number, open.Name)
+	}
+
+	return table, nil
+}
+
+// tableOrder obtiene la orden abierta (draft o confirmed) de una mesa; nil si está libre
+func (s *OrderService) tableOrder(tx *gorm.DB, tableID uint) (*models.Order, error) {
+	var order models.Order
+	result := tx.Where("table_id = ? AND state IN ?", tableID, []string{OrderStateDraft, OrderStateConfirmed}).
+		Order("id asc").
+		Limit(1).
+		Find(&order)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to load table orders: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &order, nil
+}
+
+// MoveOrder cambia una orden abierta a otra mesa libre
+func (s *OrderService) MoveOrder(orderID, tableID uint) (*models.Order, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	order, err := s.splittableOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if _, err := s.freeTable(tx, tableID, order.ID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Model(order).Update("table_id", tableID).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to move order: %w", err)
+	}
+
+	// La cocina muestra la mesa de cada ticket
+	ticketIDs, err := openTicketIDs(tx, order.ID)
+	if err == nil {
+		err = recordTicketsEvent(tx, ticketIDs, KitchenEventUpdated)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// MergeOrders pasa las líneas, pagos y tickets de cocina de la orden origen a la destino
+// y cancela la origen (queda registrada a qué orden se unió)
+func (s *OrderService) MergeOrders(targetID, sourceID uint) (*models.Order, error) {
+	if targetID == sourceID {
+		return nil, errors.New("an order cannot be merged into itself")
+	}
+
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	target, source, err := s.lockOrderPair(tx, targetID, sourceID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	ticketIDs, err := openTicketIDs(tx, source.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	for _, model := range []interface{}{&models.OrderItem{}, &models.OrderPayment{}, &models.KitchenTicket{}} {
+		if err := tx.Model(model).Where("order_id = ?", source.ID).Update("order_id", target.ID).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to merge orders: %w", err)
+		}
+	}
+
+	if err := recordTicketsEvent(tx, ticketIDs, KitchenEventUpdated); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	note := fmt.Sprintf("Unida a la orden %s", target.Name)
+	if source.Note != "" {
+		note = source.Note + "\n" + note
+	}
+	if err := tx.Model(source).Updates(map[string]interface{}{
+		"state":          OrderStateCancelled,
+		"merged_into_id": target.ID,
+		"note":           note,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to close merged order: %w", err)
+	}
+
+	for _, id := range []uint{source.ID, target.ID} {
+		if err := s.ComputeTotals(tx, id); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	// Lo que la orden origen aún no había enviado sale ahora con la destino
+	if err := s.fireIfConfirmed(tx, target); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// TransferItems pasa líneas (o parte de su cantidad) de una orden a otra. Si la mesa destino no
+// tiene una orden abierta se crea una con el mismo diario, mozo y terminal que la origen.
+func (s *OrderService) TransferItems(sourceID uint, targetOrderID, targetTableID *uint, transfers []ItemTransfer) (*models.Order, *models.Order, error) {
+	if len(transfers) == 0 {
+		return nil, nil, errors.New("no items to transfer")
+	}
+	if (targetOrderID == nil) == (targetTableID == nil) {
+		return nil, nil, errors.New("indicate either a target order or a target table")
+	}
+
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Con mesa destino se usa su orden abierta o se abre una nueva
+	if targetTableID != nil {
+		if _, err := s.lockTable(tx, *targetTableID); err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
+		open, err := s.tableOrder(tx, *targetTableID)
+		if err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
+		if open != nil {
+			targetOrderID = &open.ID
+		}
+	}
+
+	var target, source *models.Order
+	var err error
+	if targetOrderID != nil {
+		target, source, err = s.lockOrderPair(tx, *targetOrderID, sourceID)
+	} else {
+		source, err = s.editableOrder(tx, sourceID)
+		if err == nil {
+			target, err = s.openTableOrder(tx, source, *targetTableID)
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	for _, transfer := range transfers {
+		item, err := s.activeItem(tx, source.ID, transfer.OrderItemID)
+		if err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
+		if transfer.Quantity > item.Quantity+quantityEpsilon {
+			tx.Rollback()
+			return nil, nil, fmt.Errorf("order item %d: cannot transfer %.2f of %.2f", item.ID, transfer.Quantity, item.Quantity)
+		}
+
+		// Línea completa: cambia de orden conservando sus tickets de cocina
+		if item.Quantity-transfer.Quantity <= quantityEpsilon {
+			if err := tx.Model(item).Update("order_id", target.ID).Error; err != nil {
+				tx.Rollback()
+				return nil, nil, fmt.Errorf("failed to transfer order item: %w", err)
+			}
+			continue
+		}
+
+		// Parte de la línea: se separa en una línea nueva de la orden destino
+		remainingFired, movedFired := splitFired(item, transfer.Quantity)
+		if err := tx.Model(item).Updates(map[string]interface{}{
+			"quantity":       item.Quantity - transfer.Quantity,
+			"fired_quantity": remainingFired,
+		}).Error; err != nil {
+			tx.Rollback()
+			return nil, nil, fmt.Errorf("failed to update order item: %w", err)
+		}
+		newItem := &models.OrderItem{
+			OrderID:       target.ID,
+			ProductID:     item.ProductID,
+			Quantity:      transfer.Quantity,
+			PriceUnit:     item.PriceUnit,
+			ProductNotes:  item.ProductNotes,
+			Seat:          item.Seat,
+			FiredQuantity: movedFired,
+			State:         "active",
+			Taxes:         item.Taxes,
+		}
+		if err := tx.Create(newItem).Error; err != nil {
+			tx.Rollback()
+			return nil, nil, fmt.Errorf("failed to create order item: %w", err)
+		}
+	}
+
+	for _, order := range []*models.Order{source, target} {
+		if err := s.ComputeTotals(tx, order.ID); err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
+		if err := s.fireIfConfirmed(tx, order); err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, nil, err
+	}
+
+	return source, target, nil
+}
+
+// lockOrderPair bloquea dos órdenes editables en orden de ID (evita interbloqueos entre
+// operaciones cruzadas) y las devuelve como destino y origen
+func (s *OrderService) lockOrderPair(tx *gorm.DB, targetID, sourceID uint) (*models.Order, *models.Order, error) {
+	if targetID == sourceID {
+		return nil, nil, errors.New("source and target orders must be different")
+	}
+
+	first, second := targetID, sourceID
+	if first > second {
+		first, second = second, first
+	}
+
+	locked := map[uint]*models.Order{}
+	for _, id := range []uint{first, second} {
+		order, err := s.editableOrder(tx, id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("order %d: %w", id, err)
+		}
+		locked[id] = order
+	}
+
+	return locked[targetID], locked[sourceID], nil
+}
+
+// openTableOrder abre una orden en borrador en la mesa indicada, con los datos de la orden origen
+func (s *OrderService) openTableOrder(tx *gorm.DB, source *models.Order, tableID uint) (*models.Order, error) {
+	name, err := nextSequenceNumber(tx, source.JournalID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.sessionFor(tx, source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &models.Order{
+		JournalID:    source.JournalID,
+		UserID:       source.UserID,
+		TableID:      &tableID,
+		POSID:        &session.POSID,
+		POSSessionID: &session.ID,
+		Name:         name,
+		State:        OrderStateDraft,
+		OrderDate:    time.Now(),
+	}
+	if err := tx.Create(order).Error; err != nil {
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	return order, nil
+}
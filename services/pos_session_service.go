@@ -0,0 +1,666 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/utils"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Estados de una sesión de caja
+const (
+	SessionStatusOpen    = "open"
+	SessionStatusClosing = "closing" // Contada, espera el reconteo del supervisor
+	SessionStatusClosed  = "closed"
+)
+
+// ErrNoOpenSession indica que el terminal no tiene una sesión de caja abierta
+var ErrNoOpenSession = errors.New("there is no open cash session for this POS terminal")
+
+// POSSessionService maneja la apertura y el cierre de las sesiones de caja
+type POSSessionService struct{}
+
+// NewPOSSessionService crea una nueva instancia del servicio
+func NewPOSSessionService() *POSSessionService {
+	return &POSSessionService{}
+}
+
+// SessionCash es el arqueo teórico del efectivo de una sesión. Los importes son los del cajón en
+// moneda base; Drawers detalla el cajón de cada moneda (el de moneda base primero).
+type SessionCash struct {
+	Currency       string           `json:"currency"` // Moneda base
+	OpeningBalance float64          `json:"opening_balance"`
+	CashPayments   float64          `json:"cash_payments"` // Efectivo recibido por cobros en la sesión
+	ChangeGiven    float64          `json:"change_given"`  // Vuelto entregado (también el de cobros en otra moneda)
+	CashIn         float64          `json:"cash_in"`       // Ingresos manuales
+	CashOut        float64          `json:"cash_out"`      // Retiros, gastos y devoluciones en efectivo
+	Expected       float64          `json:"expected"`
+	Drawers        []CurrencyDrawer `json:"drawers"`
+}
+
+// CurrencyDrawer - Efectivo de una moneda en la caja, en esa moneda. Counted y Difference salen del
+// último conteo de cierre o reconteo.
+type CurrencyDrawer struct {
+	Currency       string   `json:"currency"`
+	OpeningBalance float64  `json:"opening_balance"`
+	CashPayments   float64  `json:"cash_payments"`
+	ChangeGiven    float64  `json:"change_given"`
+	CashIn         float64  `json:"cash_in"`
+	CashOut        float64  `json:"cash_out"`
+	Expected       float64  `json:"expected"`
+	Counted        *float64 `json:"counted"`
+	Difference     *float64 `json:"difference"` // Contado - esperado
+}
+
+// CloseResult es el resultado de un cierre o reconteo de caja
+type CloseResult struct {
+	Session         *models.POSSession `json:"session"`
+	Count           *models.CashCount  `json:"count"`
+	ZReport         *models.ZReport    `json:"z_report,omitempty"`
+	RecountRequired bool               `json:"recount_required"` // La diferencia supera el umbral: falta el conteo del supervisor
+}
+
+// OpenSession abre una sesión en el terminal si no tiene otra abierta. Con un conteo por
+// denominaciones, el fondo inicial es el total contado.
+func (s *POSSessionService) OpenSession(session *models.POSSession, input CashCountInput) error {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// El terminal queda bloqueado para que dos aperturas simultáneas no pasen la verificación
+	var pos models.POS
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&pos, session.POSID).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("POS terminal %d not found", session.POSID)
+	}
+
+	var open int64
+	if err := tx.Model(&models.POSSession{}).
+		Where("pos_id = ? AND status IN ?", pos.ID, []string{SessionStatusOpen, SessionStatusClosing}).
+		Count(&open).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to check open sessions: %w", err)
+	}
+	if open > 0 {
+		tx.Rollback()
+		return errors.New("there is already an open session on this POS terminal")
+	}
+
+	var count *models.CashCount
+	if !input.IsEmpty() {
+		base, err := baseCurrency(tx, pos.CompanyID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		count, err = newCashCount(CashCountOpening, base, input, &session.OpenedBy)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		session.OpeningBalance = count.Total
+	}
+
+	session.Status = SessionStatusOpen
+	session.OpenedAt = time.Now()
+	session.ClosingBalance = nil
+	session.ExpectedBalance = nil
+	session.Difference = nil
+	session.ClosedBy = nil
+	session.ClosedAt = nil
+
+	if err := tx.Create(session).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to open POS session: %w", err)
+	}
+
+	if count != nil {
+		if err := saveCashCount(tx, session.ID, count, nil); err != nil {
+			tx.Rollback()
+			return err
+		}
+		session.CashCounts = []models.CashCount{*count}
+	}
+
+	return tx.Commit().Error
+}
+
+// CloseSession registra el conteo de cierre y compara con el efectivo esperado de cada moneda. Si
+// la diferencia de algún cajón supera el umbral del terminal, la sesión queda en closing hasta el
+// reconteo del supervisor; si no, se cierra: guarda esperado, contado, diferencia (positiva =
+// sobrante, en moneda base) y quién cerró, y genera el reporte Z. No se cierra mientras la sesión
+// tenga órdenes abiertas.
+func (s *POSSessionService) CloseSession(sessionID uint, input CashCountInput, closedBy *uint) (*CloseResult, error) {
+	if closedBy == nil {
+		return nil, errors.New("the user closing the session is required")
+	}
+	if input.IsEmpty() {
+		return nil, errors.New("cash count requires an amount or a denomination breakdown")
+	}
+
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var session models.POSSession
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("POS").First(&session, sessionID).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("POS session %d not found", sessionID)
+	}
+	switch session.Status {
+	case SessionStatusOpen:
+	case SessionStatusClosing:
+		tx.Rollback()
+		return nil, errors.New("session is waiting for a supervisor recount")
+	default:
+		tx.Rollback()
+		return nil, errors.New("session is already closed")
+	}
+
+	var openOrders int64
+	if err := tx.Model(&models.Order{}).
+		Scopes(inSession(&session, time.Now(), "orders", "orders.pos_id", "orders.created_at")).
+		Where("state IN ?", []string{OrderStateDraft, OrderStateConfirmed}).
+		Count(&openOrders).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to check open orders: %w", err)
+	}
+	if openOrders > 0 {
+		tx.Rollback()
+		return nil, fmt.Errorf("there are %d open orders in this session, complete or cancel them before closing", openOrders)
+	}
+
+	cash, err := sessionCash(tx, &session, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	count, err := newCashCount(CashCountClosing, cash.Currency, input, closedBy)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := saveCashCount(tx, session.ID, count, &cash.Expected); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if input.Notes != "" {
+		session.Notes = input.Notes
+	}
+
+	result := &CloseResult{Session: &session, Count: count}
+
+	recount, err := needsRecount(tx, &session, cash, count)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if recount {
+		// Nadie más opera en la sesión hasta que el supervisor vuelva a contar
+		session.Status = SessionStatusClosing
+		if err := tx.Model(&session).Updates(map[string]interface{}{
+			"status": session.Status,
+			"notes":  session.Notes,
+		}).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to update POS session: %w", err)
+		}
+		result.RecountRequired = true
+	} else {
+		result.ZReport, err = finishClose(tx, &session, count.Total, cash.Expected, closedBy)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Recount registra el segundo conteo de un supervisor (administrador distinto del cajero) sobre
+// una sesión en closing y la cierra con ese conteo
+func (s *POSSessionService) Recount(sessionID uint, input CashCountInput, supervisorID *uint) (*CloseResult, error) {
+	if supervisorID == nil {
+		return nil, errors.New("a supervisor is required to recount")
+	}
+
+	var supervisor models.User
+	if err := config.DB.First(&supervisor, *supervisorID).Error; err != nil {
+		return nil, fmt.Errorf("user %d not found", *supervisorID)
+	}
+	if supervisor.Role != models.AdminRole {
+		return nil, errors.New("only a supervisor can recount the cash")
+	}
+
+	if input.IsEmpty() {
+		return nil, errors.New("cash count requires an amount or a denomination breakdown")
+	}
+
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var session models.POSSession
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, sessionID).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("POS session %d not found", sessionID)
+	}
+	if session.Status != SessionStatusClosing {
+		tx.Rollback()
+		return nil, errors.New("session is not waiting for a recount")
+	}
+
+	var closing models.CashCount
+	if err := tx.Where("pos_session_id = ? AND kind = ?", session.ID, CashCountClosing).
+		Order("id desc").First(&closing).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("session has no closing count")
+	}
+	if closing.CountedBy != nil && *closing.CountedBy == *supervisorID {
+		tx.Rollback()
+		return nil, errors.New("the recount must be done by a different user than the cashier")
+	}
+
+	cash, err := sessionCash(tx, &session, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	count, err := newCashCount(CashCountRecount, cash.Currency, input, supervisorID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := saveCashCount(tx, session.ID, count, &cash.Expected); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if input.Notes != "" {
+		session.Notes = strings.TrimSpace(session.Notes + "\n" + input.Notes)
+	}
+
+	zReport, err := finishClose(tx, &session, count.Total, cash.Expected, supervisorID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &CloseResult{Session: &session, Count: count, ZReport: zReport}, nil
+}
+
+// finishClose cierra la sesión con el conteo final y genera su reporte Z
+func finishClose(tx *gorm.DB, session *models.POSSession, counted, expected float64, closedBy *uint) (*models.ZReport, error) {
+	now := time.Now()
+	difference := utils.Round(counted-expected, 2)
+
+	session.Status = SessionStatusClosed
+	session.ClosedAt = &now
+	session.ClosedBy = closedBy
+	session.ClosingBalance = &counted
+	session.ExpectedBalance = &expected
+	session.Difference = &difference
+
+	if err := tx.Model(session).Updates(map[string]interface{}{
+		"status":           session.Status,
+		"closed_at":        session.ClosedAt,
+		"closed_by":        session.ClosedBy,
+		"closing_balance":  session.ClosingBalance,
+		"expected_balance": session.ExpectedBalance,
+		"difference":       session.Difference,
+		"notes":            session.Notes,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to close POS session: %w", err)
+	}
+
+	return createZReport(tx, session, closedBy)
+}
+
+// SessionCash calcula el efectivo esperado de una sesión (a la fecha de cierre, o a ahora si sigue abierta)
+func (s *POSSessionService) SessionCash(sessionID uint) (*SessionCash, error) {
+	var session models.POSSession
+	if err := config.DB.First(&session, sessionID).Error; err != nil {
+		return nil, fmt.Errorf("POS session %d not found", sessionID)
+	}
+
+	until := time.Now()
+	if session.ClosedAt != nil {
+		until = *session.ClosedAt
+	}
+	return sessionCash(config.DB, &session, until)
+}
+
+// sessionCash arma el efectivo esperado de cada moneda: fondo inicial, más lo entregado en los
+// cobros en efectivo, menos el vuelto (siempre en moneda base), más ingresos y menos egresos. Las
+// devoluciones en efectivo no se toman de los pagos negativos sino de su movimiento de salida,
+// para no restarlas dos veces. Los pagos y movimientos anteriores a las monedas (sin moneda ni
+// monto entregado) cuentan en moneda base por su importe.
+func sessionCash(tx *gorm.DB, session *models.POSSession, until time.Time) (*SessionCash, error) {
+	_, base, err := sessionCurrency(tx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	drawers := map[string]*CurrencyDrawer{base: {Currency: base, OpeningBalance: session.OpeningBalance}}
+	drawer := func(currency string) *CurrencyDrawer {
+		currency = strings.ToUpper(currency)
+		if currency == "" {
+			currency = base
+		}
+		if drawers[currency] == nil {
+			drawers[currency] = &CurrencyDrawer{Currency: currency}
+		}
+		return drawers[currency]
+	}
+
+	// Fondo inicial en otras monedas: líneas del conteo de apertura
+	var opening []struct {
+		Currency string
+		Total    float64
+	}
+	if err := tx.Model(&models.CashCountLine{}).
+		Joins("JOIN cash_counts ON cash_counts.id = cash_count_lines.cash_count_id AND cash_counts.deleted_at IS NULL").
+		Where("cash_counts.pos_session_id = ? AND cash_counts.kind = ? AND cash_count_lines.currency <> ?", session.ID, CashCountOpening, base).
+		Select("cash_count_lines.currency AS currency, COALESCE(SUM(cash_count_lines.amount), 0) AS total").
+		Group("cash_count_lines.currency").
+		Scan(&opening).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum opening count: %w", err)
+	}
+	for _, line := range opening {
+		drawer(line.Currency).OpeningBalance += line.Total
+	}
+
+	var payments []struct {
+		Currency    string
+		Tendered    float64
+		ChangeGiven float64
+	}
+	if err := tx.Model(&models.OrderPayment{}).
+		Joins("JOIN orders ON orders.id = order_payments.order_id AND orders.deleted_at IS NULL").
+		Joins("JOIN payment_methods ON payment_methods.id = order_payments.payment_method_id").
+		Scopes(inSession(session, until, "order_payments", "orders.pos_id", "order_payments.created_at")).
+		Where("payment_methods.type = ? AND order_payments.refund_id IS NULL", "cash").
+		Select("order_payments.currency AS currency, " +
+			"COALESCE(SUM(CASE WHEN COALESCE(order_payments.tendered_amount, 0) = 0 THEN order_payments.amount ELSE order_payments.tendered_amount END), 0) AS tendered, " +
+			"COALESCE(SUM(order_payments.change_amount), 0) AS change_given").
+		Group("order_payments.currency").
+		Scan(&payments).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum cash payments: %w", err)
+	}
+	for _, payment := range payments {
+		drawer(payment.Currency).CashPayments += payment.Tendered
+		drawers[base].ChangeGiven += payment.ChangeGiven
+	}
+
+	var movements []struct {
+		Type     string
+		Currency string
+		Total    float64
+	}
+	// Un movimiento con tarjeta o transferencia no pasa por el cajón; sin medio es efectivo
+	if err := tx.Model(&models.CashMovement{}).
+		Where("cash_register_id = ? AND COALESCE(payment_method, '') IN ?", session.ID, []string{"", "cash"}).
+		Select("type, currency, COALESCE(SUM(CASE WHEN COALESCE(tendered_amount, 0) = 0 THEN amount ELSE tendered_amount END), 0) AS total").
+		Group("type, currency").
+		Scan(&movements).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum cash movements: %w", err)
+	}
+	for _, movement := range movements {
+		switch movement.Type {
+		case "in":
+			drawer(movement.Currency).CashIn += movement.Total
+		case "out":
+			drawer(movement.Currency).CashOut += movement.Total
+		}
+	}
+
+	cash := &SessionCash{Currency: base, Drawers: make([]CurrencyDrawer, 0, len(drawers))}
+	for _, d := range drawers {
+		d.OpeningBalance = utils.Round(d.OpeningBalance, 2)
+		d.CashPayments = utils.Round(d.CashPayments, 2)
+		d.ChangeGiven = utils.Round(d.ChangeGiven, 2)
+		d.CashIn = utils.Round(d.CashIn, 2)
+		d.CashOut = utils.Round(d.CashOut, 2)
+		d.Expected = utils.Round(d.OpeningBalance+d.CashPayments-d.ChangeGiven+d.CashIn-d.CashOut, 2)
+		cash.Drawers = append(cash.Drawers, *d)
+	}
+	sortDrawers(cash)
+
+	var count models.CashCount
+	result := tx.Preload("Lines").
+		Where("pos_session_id = ? AND kind IN ?", session.ID, []string{CashCountClosing, CashCountRecount}).
+		Order("id desc").Limit(1).Find(&count)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to load cash count: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		reconcileDrawers(cash, &count)
+	}
+
+	baseDrawer := cash.Drawers[0]
+	cash.OpeningBalance = baseDrawer.OpeningBalance
+	cash.CashPayments = baseDrawer.CashPayments
+	cash.ChangeGiven = baseDrawer.ChangeGiven
+	cash.CashIn = baseDrawer.CashIn
+	cash.CashOut = baseDrawer.CashOut
+	cash.Expected = baseDrawer.Expected
+	return cash, nil
+}
+
+// reconcileDrawers compara el conteo con el esperado de cada moneda. Un conteo solo con total
+// concilia la moneda base; uno por denominaciones concilia todas (la moneda sin líneas cuenta 0).
+func reconcileDrawers(cash *SessionCash, count *models.CashCount) {
+	counted := map[string]float64{cash.Currency: count.Total}
+	for _, line := range count.Lines {
+		if line.Currency != cash.Currency {
+			counted[line.Currency] += line.Amount
+		}
+	}
+
+	known := map[string]bool{}
+	for _, d := range cash.Drawers {
+		known[d.Currency] = true
+	}
+	for currency := range counted {
+		if !known[currency] {
+			cash.Drawers = append(cash.Drawers, CurrencyDrawer{Currency: currency})
+		}
+	}
+	sortDrawers(cash)
+
+	for i := range cash.Drawers {
+		d := &cash.Drawers[i]
+		value, ok := counted[d.Currency]
+		if !ok && len(count.Lines) == 0 {
+			continue
+		}
+		value = utils.Round(value, 2)
+		difference := utils.Round(value-d.Expected, 2)
+		d.Counted = &value
+		d.Difference = &difference
+	}
+}
+
+// sortDrawers deja primero el cajón de moneda base y los demás por código
+func sortDrawers(cash *SessionCash) {
+	sort.Slice(cash.Drawers, func(i, j int) bool {
+		if (cash.Drawers[i].Currency == cash.Currency) != (cash.Drawers[j].Currency == cash.Currency) {
+			return cash.Drawers[i].Currency == cash.Currency
+		}
+		return cash.Drawers[i].Currency < cash.Drawers[j].Currency
+	})
+}
+
+// needsRecount indica si la diferencia de algún cajón supera el umbral del terminal. Las
+// diferencias en otra moneda se valorizan en moneda base con el tipo de cambio del día.
+func needsRecount(tx *gorm.DB, session *models.POSSession, cash *SessionCash, count *models.CashCount) (bool, error) {
+	if session.POS == nil || session.POS.RecountThreshold <= 0 {
+		return false, nil
+	}
+
+	reconcileDrawers(cash, count)
+	for _, d := range cash.Drawers {
+		if d.Difference == nil {
+			continue
+		}
+		rate, err := exchangeRate(tx, session.POS.CompanyID, d.Currency, time.Now())
+		if err != nil {
+			return false, err
+		}
+		if math.Abs(*d.Difference)*rate > session.POS.RecountThreshold+0.005 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// lockOpenSession bloquea la sesión en modo compartido y verifica que siga abierta. El cierre la
+// bloquea en exclusiva, así ninguna orden ni pago entra en una sesión que se está cerrando.
+func lockOpenSession(tx *gorm.DB, sessionID uint) (*models.POSSession, error) {
+	var session models.POSSession
+	if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).First(&session, sessionID).Error; err != nil {
+		return nil, fmt.Errorf("POS session %d not found", sessionID)
+	}
+	if session.Status != SessionStatusOpen {
+		return nil, fmt.Errorf("POS session %d is closed", sessionID)
+	}
+	return &session, nil
+}
+
+// sessionFor resuelve la sesión abierta en la que se registra una orden o un pago: la indicada
+// (puede ser de otro terminal, ej: se cobra en otra caja) o la sesión abierta del terminal de la orden
+func (s *OrderService) sessionFor(tx *gorm.DB, order *models.Order, requested *uint) (*models.POSSession, error) {
+	if requested != nil && *requested != 0 {
+		return lockOpenSession(tx, *requested)
+	}
+
+	session, err := s.openSession(tx, order)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrNoOpenSession
+	}
+	return lockOpenSession(tx, session.ID)
+}
+
+// inSession filtra los registros de una tabla con pos_session_id que pertenecen a la sesión. Los
+// registros anteriores a la asociación con sesiones se asignan por terminal y fecha.
+func inSession(session *models.POSSession, until time.Time, table, posColumn, dateColumn string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(
+			fmt.Sprintf("(%[1]s.pos_session_id = ? OR (%[1]s.pos_session_id IS NULL AND %[2]s = ? AND %[3]s BETWEEN ? AND ?))", table, posColumn, dateColumn),
+			session.ID, session.POSID, session.OpenedAt, until,
+		)
+	}
+}
+
+// SessionTotals resume lo registrado en una sesión: órdenes, ventas cerradas y pagos por medio
+type SessionTotals struct {
+	POSSessionID uint                `json:"pos_session_id"`
+	OrderCount   int                 `json:"order_count"`
+	SalesTotal   float64             `json:"sales_total"`    // Total de las órdenes cerradas
+	PaymentTotal float64             `json:"payments_total"` // Cobrado neto (las devoluciones restan)
+	Payments     []ReportPaymentLine `json:"payments"`
+	Blind        bool                `json:"blind,omitempty"` // Cobros ocultos por cierre ciego
+}
+
+// Totals calcula los totales de varias sesiones con una consulta por concepto
+func (s *POSSessionService) Totals(sessionIDs []uint) ([]SessionTotals, error) {
+	totals := make([]SessionTotals, len(sessionIDs))
+	index := map[uint]int{}
+	for i, id := range sessionIDs {
+		totals[i] = SessionTotals{POSSessionID: id, Payments: []ReportPaymentLine{}}
+		index[id] = i
+	}
+	if len(sessionIDs) == 0 {
+		return totals, nil
+	}
+
+	var orders []struct {
+		POSSessionID uint
+		Count        int
+		Sales        float64
+	}
+	if err := config.DB.Model(&models.Order{}).
+		Where("pos_session_id IN ?", sessionIDs).
+		Select("pos_session_id, COUNT(*) AS count, COALESCE(SUM(CASE WHEN state = ? THEN total_amount ELSE 0 END), 0) AS sales", OrderStateDone).
+		Group("pos_session_id").
+		Scan(&orders).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum session orders: %w", err)
+	}
+	for _, row := range orders {
+		total := &totals[index[row.POSSessionID]]
+		total.OrderCount = row.Count
+		total.SalesTotal = utils.Round(row.Sales, 2)
+	}
+
+	var payments []struct {
+		POSSessionID    uint
+		PaymentMethodID uint
+		Name            string
+		Count           int
+		Amount          float64
+	}
+	if err := config.DB.Model(&models.OrderPayment{}).
+		Joins("JOIN payment_methods ON payment_methods.id = order_payments.payment_method_id").
+		Where("order_payments.pos_session_id IN ?", sessionIDs).
+		Select("order_payments.pos_session_id, payment_methods.id AS payment_method_id, payment_methods.name AS name, " +
+			"COUNT(*) AS count, COALESCE(SUM(order_payments.amount), 0) AS amount").
+		Group("order_payments.pos_session_id, payment_methods.id, payment_methods.name").
+		Order("payment_methods.name").
+		Scan(&payments).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum session payments: %w", err)
+	}
+	for _, row := range payments {
+		total := &totals[index[row.POSSessionID]]
+		amount := utils.Round(row.Amount, 2)
+		total.Payments = append(total.Payments, ReportPaymentLine{
+			PaymentMethodID: row.PaymentMethodID,
+			Name:            row.Name,
+			Count:           row.Count,
+			Amount:          amount,
+		})
+		total.PaymentTotal = utils.Round(total.PaymentTotal+amount, 2)
+	}
+
+	return totals, nil
+}
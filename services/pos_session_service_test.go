@@ -0,0 +1,36 @@
+package services
+
+import (
+	"b-resto/models"
+	"b-resto/testutil"
+	"testing"
+)
+
+// TestSessionCashCountsOnlyCashMovements verifica que el esperado del cajón ignora los movimientos
+// con tarjeta o transferencia, como ya ignora los cobros que no son en efectivo.
+func TestSessionCashCountsOnlyCashMovements(t *testing.T) {
+	db := testutil.OpenDB(t)
+	f := newSaleFixture(t, db)
+
+	movements := []models.CashMovement{
+		{Type: "in", Concept: "Fondo extra", Amount: 20, PaymentMethod: "cash"},
+		{Type: "in", Concept: "Anterior al medio", Amount: 5},
+		{Type: "in", Concept: "Propina con tarjeta", Amount: 50, PaymentMethod: "card"},
+		{Type: "out", Concept: "Proveedor", Amount: 30, PaymentMethod: "transfer"},
+	}
+	for i := range movements {
+		movements[i].POSSessionID = f.session.ID
+		movements[i].UserID = f.user.ID
+		if err := db.Create(&movements[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cash, err := NewPOSSessionService().SessionCash(f.session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cash.CashIn != 25 || cash.CashOut != 0 || cash.Expected != 125 {
+		t.Fatalf("cash in %.2f, out %.2f, expected %.2f; want 25.00, 0.00 and 125.00", cash.CashIn, cash.CashOut, cash.Expected)
+	}
+}
@@ -0,0 +1,497 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/utils"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tipos de trabajo de impresión
+const (
+	PrintJobKitchenTicket = "kitchen_ticket"
+	PrintJobReceipt       = "receipt"
+	PrintJobReport        = "session_report"
+)
+
+const (
+	printerPort       = "9100"           // Puerto RAW de las impresoras de red
+	printPaperWidth   = 48               // Caracteres por línea en papel de 80 mm
+	maxPrintAttempts  = 5                // Intentos antes de marcar el trabajo como fallido
+	printQueueTick    = 2 * time.Second  // Frecuencia con que la cola busca trabajos pendientes
+	printerTimeout    = 5 * time.Second  // Conexión con la impresora
+	printWriteTimeout = 10 * time.Second // Envío del documento
+	printClaimLease   = 30 * time.Second // Plazo de un trabajo reclamado antes de volver a la cola
+)
+
+// ErrNoPrinter indica que la estación o el terminal no tienen impresora configurada
+var ErrNoPrinter = errors.New("no printer configured")
+
+// PrintService - Impresión ESC/POS de tickets de cocina y comprobantes
+type PrintService struct{}
+
+// NewPrintService crea una nueva instancia del servicio
+func NewPrintService() *PrintService {
+	return &PrintService{}
+}
+
+// ReprintKitchenTicket vuelve a encolar un ticket de cocina (marcado como reimpresión)
+func (s *PrintService) ReprintKitchenTicket(ticketID uint, requestedBy *uint) (*models.PrintJob, error) {
+	return queueKitchenTicketPrint(config.DB, ticketID, true, requestedBy)
+}
+
+// PrintReceipt encola el comprobante de una orden cerrada; las copias posteriores salen como reimpresión
+func (s *PrintService) PrintReceipt(orderID uint, requestedBy *uint) (*models.PrintJob, error) {
+	var order models.Order
+	if err := config.DB.First(&order, orderID).Error; err != nil {
+		return nil, fmt.Errorf("order %d not found", orderID)
+	}
+	if order.State != OrderStateDone {
+		return nil, fmt.Errorf("order is %s, only done orders have a receipt", order.State)
+	}
+
+	var printed int64
+	if err := config.DB.Model(&models.PrintJob{}).
+		Where("order_id = ? AND type = ?", orderID, PrintJobReceipt).
+		Count(&printed).Error; err != nil {
+		return nil, fmt.Errorf("failed to check previous receipts: %w", err)
+	}
+
+	return queueReceiptPrint(config.DB, orderID, printed > 0, requestedBy)
+}
+
+// PrintSessionReport encola el reporte X (sesión abierta) o Z (sesión cerrada) en la impresora del terminal
+func (s *PrintService) PrintSessionReport(sessionID uint, reportType string, requestedBy *uint) (*models.PrintJob, error) {
+	reportService := NewSessionReportService()
+
+	var report *SessionReport
+	var err error
+	if reportType == ReportTypeZ {
+		_, report, err = reportService.ZReport(sessionID)
+	} else {
+		report, err = reportService.XReport(sessionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pos models.POS
+	if err := config.DB.First(&pos, report.POSID).Error; err != nil {
+		return nil, fmt.Errorf("POS terminal %d not found", report.POSID)
+	}
+	if pos.PrinterIP == "" {
+		return nil, ErrNoPrinter
+	}
+
+	p := utils.NewESCPOS(printPaperWidth)
+	for _, line := range SessionReportLines(report, printPaperWidth) {
+		p.Line(line)
+	}
+	p.Feed(3).Cut()
+
+	job := models.PrintJob{
+		Type:           PrintJobReport,
+		PrinterAddress: printerAddress(pos.PrinterIP),
+		Data:           p.Bytes(),
+		State:          "pending",
+		NextAttemptAt:  time.Now(),
+		RequestedBy:    requestedBy,
+	}
+	if err := config.DB.Create(&job).Error; err != nil {
+		return nil, fmt.Errorf("failed to queue print job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// RetryJob vuelve a poner en cola un trabajo fallido
+func (s *PrintService) RetryJob(jobID uint) (*models.PrintJob, error) {
+	var job models.PrintJob
+	if err := config.DB.First(&job, jobID).Error; err != nil {
+		return nil, fmt.Errorf("print job %d not found", jobID)
+	}
+	if job.State == "printed" {
+		return nil, errors.New("print job was already printed, use reprint instead")
+	}
+
+	job.State = "pending"
+	job.Attempts = 0
+	job.NextAttemptAt = time.Now()
+	if err := config.DB.Model(&job).Updates(map[string]interface{}{
+		"state":           job.State,
+		"attempts":        job.Attempts,
+		"next_attempt_at": job.NextAttemptAt,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to retry print job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// queueKitchenTicketPrint renderiza un ticket de cocina y lo encola para la impresora de su estación.
+// Devuelve ErrNoPrinter si la estación no tiene impresora.
+func queueKitchenTicketPrint(tx *gorm.DB, ticketID uint, reprint bool, requestedBy *uint) (*models.PrintJob, error) {
+	var ticket models.KitchenTicket
+	if err := tx.Preload("KitchenStation").
+		Preload("Order.Table").
+		Preload("Items.OrderItem.Product.Template").
+		Preload("Items.OrderItem.Product.AttributeValues").
+		First(&ticket, ticketID).Error; err != nil {
+		return nil, fmt.Errorf("kitchen ticket %d not found", ticketID)
+	}
+	if ticket.KitchenStation == nil || ticket.KitchenStation.PrinterIP == "" {
+		return nil, ErrNoPrinter
+	}
+
+	job := models.PrintJob{
+		Type:            PrintJobKitchenTicket,
+		KitchenTicketID: &ticket.ID,
+		OrderID:         &ticket.OrderID,
+		PrinterAddress:  printerAddress(ticket.KitchenStation.PrinterIP),
+		Data:            renderKitchenTicket(&ticket, reprint),
+		State:           "pending",
+		NextAttemptAt:   time.Now(),
+		Reprint:         reprint,
+		RequestedBy:     requestedBy,
+	}
+	if err := tx.Create(&job).Error; err != nil {
+		return nil, fmt.Errorf("failed to queue print job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// queueReceiptPrint renderiza el comprobante de una orden y lo encola para la impresora de su terminal.
+// Devuelve ErrNoPrinter si la orden no tiene terminal o el terminal no tiene impresora.
+func queueReceiptPrint(tx *gorm.DB, orderID uint, reprint bool, requestedBy *uint) (*models.PrintJob, error) {
+	var order models.Order
+	if err := tx.Preload("POS").
+		Preload("Journal.Company").
+		Preload("Table").
+		Preload("Items", "state <> ?", "voided").
+		Preload("Items.Product.Template").
+		Preload("Items.Product.AttributeValues").
+		Preload("TaxLines").
+		Preload("Payments.PaymentMethod").
+		First(&order, orderID).Error; err != nil {
+		return nil, fmt.Errorf("order %d not found", orderID)
+	}
+	if order.POS == nil || order.POS.PrinterIP == "" {
+		return nil, ErrNoPrinter
+	}
+
+	job := models.PrintJob{
+		Type:           PrintJobReceipt,
+		OrderID:        &order.ID,
+		PrinterAddress: printerAddress(order.POS.PrinterIP),
+		Data:           renderReceipt(&order, reprint),
+		State:          "pending",
+		NextAttemptAt:  time.Now(),
+		Reprint:        reprint,
+		RequestedBy:    requestedBy,
+	}
+	if err := tx.Create(&job).Error; err != nil {
+		return nil, fmt.Errorf("failed to queue print job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// renderKitchenTicket arma el ticket de cocina: estación, número, orden y mesa, y las líneas con
+// sus notas. Las cantidades negativas salen como ANULAR.
+func renderKitchenTicket(ticket *models.KitchenTicket, reprint bool) []byte {
+	p := utils.NewESCPOS(printPaperWidth)
+
+	p.Align(utils.AlignCenter).Bold(true).DoubleSize(true)
+	if ticket.KitchenStation != nil {
+		p.Line(ticket.KitchenStation.Name)
+	}
+	p.Line("#" + ticket.TicketNumber).DoubleSize(false).Bold(false)
+	if reprint {
+		p.Bold(true).Line("*** REIMPRESIÓN ***").Bold(false)
+	}
+
+	p.Align(utils.AlignLeft)
+	if ticket.Order != nil {
+		p.Line("Orden: " + ticket.Order.Name)
+		if ticket.Order.Table != nil {
+			p.Bold(true).DoubleSize(true).Line("Mesa " + ticket.Order.Table.Number).DoubleSize(false).Bold(false)
+		} else {
+			p.Bold(true).Line("PARA LLEVAR").Bold(false)
+		}
+	}
+	p.Line("Hora: " + ticket.CreatedDate.Format("02/01/2006 15:04"))
+	p.Separator()
+
+	for _, item := range ticket.Items {
+		name := ""
+		notes := ""
+		seat := 0
+		if item.OrderItem != nil {
+			name = productLabel(item.OrderItem.Product)
+			notes = item.OrderItem.ProductNotes
+			seat = item.OrderItem.Seat
+		}
+
+		line := fmt.Sprintf("%s x %s", formatQuantity(item.Quantity), name)
+		if item.Quantity < 0 {
+			line = fmt.Sprintf("ANULAR %s x %s", formatQuantity(-item.Quantity), name)
+		}
+		if seat > 0 {
+			line += fmt.Sprintf(" (A%d)", seat)
+		}
+
+		p.Bold(true).DoubleSize(true).Wrap(line, "").DoubleSize(false).Bold(false)
+		if notes != "" {
+			p.Wrap(notes, "   * ")
+		}
+	}
+
+	p.Separator().Feed(3).Cut()
+	return p.Bytes()
+}
+
+// renderReceipt arma el comprobante del cliente: datos de la compañía, líneas con notas, subtotal,
+// impuestos, total, pagos y un QR con los datos de la venta
+func renderReceipt(order *models.Order, reprint bool) []byte {
+	p := utils.NewESCPOS(printPaperWidth)
+
+	p.Align(utils.AlignCenter)
+	if order.Journal != nil && order.Journal.Company != nil {
+		company := order.Journal.Company
+		p.Bold(true).DoubleSize(true).Line(company.Name).DoubleSize(false).Bold(false)
+		if company.BusinessName != "" && company.BusinessName != company.Name {
+			p.Line(company.BusinessName)
+		}
+		if company.Address != "" {
+			p.Wrap(company.Address, "")
+		}
+		if company.Phone != "" {
+			p.Line("Tel: " + company.Phone)
+		}
+	}
+	if reprint {
+		p.Bold(true).Line("*** COPIA ***").Bold(false)
+	}
+
+	p.Align(utils.AlignLeft).Separator()
+	p.Line("Orden: " + order.Name)
+	p.Line("Fecha: " + order.UpdatedAt.Format("02/01/2006 15:04"))
+	if order.Table != nil {
+		p.Line("Mesa: " + order.Table.Number)
+	}
+	p.Separator()
+
+	for _, item := range order.Items {
+		p.Columns(fmt.Sprintf("%s x %s", formatQuantity(item.Quantity), productLabel(item.Product)), formatAmount(item.PriceTotal))
+		if item.Quantity != 1 {
+			p.Line("   " + formatAmount(item.PriceUnit) + " c/u")
+		}
+		if item.ProductNotes != "" {
+			p.Wrap(item.ProductNotes, "   * ")
+		}
+	}
+
+	p.Separator()
+	p.Columns("Subtotal", formatAmount(order.AmountUntaxed))
+	for _, tax := range order.TaxLines {
+		label := fmt.Sprintf("%s %s%%", tax.Name, strconv.FormatFloat(tax.RatePercent, 'f', -1, 64))
+		if tax.IsPriceInclusive {
+			label += " (incl.)"
+		}
+		p.Columns(label, formatAmount(tax.Amount))
+	}
+	p.Bold(true).DoubleSize(true)
+	p.Columns("TOTAL", formatAmount(order.TotalAmount))
+	p.DoubleSize(false).Bold(false)
+
+	if len(order.Payments) > 0 {
+		p.Separator()
+		for _, payment := range order.Payments {
+			method := "Pago"
+			if payment.PaymentMethod != nil {
+				method = payment.PaymentMethod.Name
+			}
+			p.Columns(method, formatAmount(payment.Amount))
+		}
+	}
+
+	p.Feed(1).Align(utils.AlignCenter)
+	p.QR(receiptQR(order), 6)
+	p.Feed(1).Line("¡Gracias por su visita!")
+	p.Feed(3).Cut()
+	return p.Bytes()
+}
+
+// receiptQR arma el contenido del QR del comprobante: orden|fecha|impuestos|total
+func receiptQR(order *models.Order) string {
+	return strings.Join([]string{
+		order.Name,
+		order.UpdatedAt.Format("2006-01-02"),
+		formatAmount(order.AmountTax),
+		formatAmount(order.TotalAmount),
+	}, "|")
+}
+
+// productLabel arma el nombre del producto con los valores de sus atributos (ej: Pizza Grande)
+func productLabel(product *models.ProductProduct) string {
+	if product == nil {
+		return ""
+	}
+
+	name := product.SKU
+	if product.Template != nil {
+		name = product.Template.Name
+	}
+	for _, value := range product.AttributeValues {
+		name += " " + value.Value
+	}
+	return name
+}
+
+func formatQuantity(quantity float64) string {
+	return strconv.FormatFloat(utils.Round(quantity, 2), 'f', -1, 64)
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// printerAddress completa la IP de la impresora con el puerto RAW si no lo trae
+func printerAddress(ip string) string {
+	if _, _, err := net.SplitHostPort(ip); err == nil {
+		return ip
+	}
+	return net.JoinHostPort(ip, printerPort)
+}
+
+// RunPrintQueue envía los trabajos pendientes a sus impresoras. Un trabajo que falla se reintenta
+// con espera creciente hasta maxPrintAttempts y luego queda como failed (se puede reintentar a mano).
+func RunPrintQueue() {
+	ticker := time.NewTicker(printQueueTick)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for {
+			processed, err := processNextPrintJob()
+			if err != nil {
+				log.Printf("⚠️ Print queue: %v", err)
+				break
+			}
+			if !processed {
+				break
+			}
+		}
+	}
+}
+
+// processNextPrintJob toma el siguiente trabajo vencido (SKIP LOCKED permite varias instancias) y lo envía.
+// El trabajo se reclama en una transacción corta (intento sumado y next_attempt_at movido a
+// printClaimLease) y se envía fuera de ella: una impresora lenta no retiene la fila ni la conexión.
+// Si la instancia cae durante el envío, el trabajo vuelve a la cola al vencer el plazo.
+func processNextPrintJob() (bool, error) {
+	job, err := claimNextPrintJob()
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	changes := map[string]interface{}{}
+	if err := sendToPrinter(job.PrinterAddress, job.Data); err != nil {
+		changes["last_error"] = truncate(err.Error(), 500)
+		if job.Attempts >= maxPrintAttempts {
+			changes["state"] = "failed"
+		} else {
+			// Espera creciente: 5s, 20s, 45s, 80s...
+			backoff := time.Duration(job.Attempts*job.Attempts) * 5 * time.Second
+			changes["next_attempt_at"] = time.Now().Add(backoff)
+		}
+	} else {
+		now := time.Now()
+		changes["state"] = "printed"
+		changes["printed_at"] = &now
+		changes["last_error"] = ""
+	}
+
+	if err := config.DB.Model(&models.PrintJob{}).Where("id = ?", job.ID).Updates(changes).Error; err != nil {
+		return true, fmt.Errorf("failed to update print job %d: %w", job.ID, err)
+	}
+
+	return true, nil
+}
+
+// claimNextPrintJob reclama el siguiente trabajo vencido: suma el intento y lo aparta de la cola
+// durante printClaimLease. Devuelve nil si no hay trabajos pendientes.
+func claimNextPrintJob() (*models.PrintJob, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var job models.PrintJob
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("state = ? AND next_attempt_at <= ?", "pending", time.Now()).
+		Order("id asc").
+		Limit(1).
+		Find(&job)
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, nil
+	}
+
+	job.Attempts++
+	job.NextAttemptAt = time.Now().Add(printClaimLease)
+	if err := tx.Model(&job).Updates(map[string]interface{}{
+		"attempts":        job.Attempts,
+		"next_attempt_at": job.NextAttemptAt,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to claim print job %d: %w", job.ID, err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// sendToPrinter envía el documento por TCP (RAW/JetDirect)
+func sendToPrinter(address string, data []byte) error {
+	conn, err := net.DialTimeout("tcp", address, printerTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(printWriteTimeout)); err != nil {
+		return err
+	}
+	_, err = conn.Write(data)
+	return err
+}
+
+func truncate(text string, length int) string {
+	if len(text) <= length {
+		return text
+	}
+	return text[:length]
+}
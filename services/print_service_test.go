@@ -0,0 +1,185 @@
+package services
+
+import (
+	"b-resto/models"
+	"b-resto/testutil"
+	"bytes"
+	"testing"
+	"time"
+)
+
+var (
+	escposInit = []byte{0x1B, 0x40, 0x1B, 0x74, 0x02} // ESC @ + ESC t 2 (PC850)
+	escposCut  = []byte{0x1D, 0x56, 0x42, 0x03}       // GS V B 3
+	escposQR   = []byte{0x1D, 0x28, 0x6B}             // GS ( k
+)
+
+// TestPrintQueueSendsKitchenTicketAndReceipt encola un ticket de cocina y un comprobante, los envía
+// a una impresora simulada y revisa el flujo ESC/POS recibido. Un trabajo a una impresora caída
+// queda pendiente con espera.
+func TestPrintQueueSendsKitchenTicketAndReceipt(t *testing.T) {
+	db := testutil.OpenDB(t)
+
+	printer, err := StartFakePrinter("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer printer.Close()
+
+	company := models.Company{Name: "Frontera", BusinessName: "Frontera SAC"}
+	if err := db.Create(&company).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: "cajero", Email: "cajero@b-resto.test", Password: "secret", Role: models.UserRole}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	pos := models.POS{CompanyID: company.ID, Code: "CAJA-1", Name: "Caja 1", PrinterIP: printer.Addr(), IsActive: true}
+	if err := db.Create(&pos).Error; err != nil {
+		t.Fatal(err)
+	}
+	station := models.KitchenStation{CompanyID: company.ID, Name: "Parrilla", PrinterIP: printer.Addr(), IsActive: true}
+	if err := db.Create(&station).Error; err != nil {
+		t.Fatal(err)
+	}
+	journal := models.Journal{CompanyID: company.ID, Code: "SO", Name: "Ventas", Type: "sale", IsActive: true}
+	if err := db.Create(&journal).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	unit := models.Unit{Name: "Unidad", Abbreviation: "und", Type: "unit", Factor: 1, IsActive: true}
+	if err := db.Create(&unit).Error; err != nil {
+		t.Fatal(err)
+	}
+	category := models.ProductCategory{Name: "Platos"}
+	if err := db.Create(&category).Error; err != nil {
+		t.Fatal(err)
+	}
+	template := models.ProductTemplate{CategoryID: category.ID, UnitID: unit.ID, KitchenStationID: &station.ID, Name: "Lomo saltado", ProductType: "service", CanBeSold: true, IsActive: true}
+	if err := db.Create(&template).Error; err != nil {
+		t.Fatal(err)
+	}
+	product := models.ProductProduct{TemplateID: template.ID, SKU: "LOMO", IsActive: true}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	order := models.Order{
+		JournalID: journal.ID,
+		UserID:    user.ID,
+		POSID:     &pos.ID,
+		Name:      "SO/0001",
+		State:     OrderStateDone,
+		OrderDate: time.Now(),
+		Items:     []models.OrderItem{{ProductID: product.ID, Quantity: 2, PriceUnit: 11.80, ProductNotes: "Sin cebolla"}},
+	}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := NewOrderService().ComputeTotals(db, order.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	ticket := models.KitchenTicket{
+		OrderID:          order.ID,
+		KitchenStationID: station.ID,
+		TicketNumber:     "7",
+		CreatedDate:      time.Now(),
+		Items:            []models.KitchenTicketItem{{OrderItemID: order.Items[0].ID, Quantity: 2}},
+	}
+	if err := db.Create(&ticket).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ticketJob, err := queueKitchenTicketPrint(db, ticket.ID, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiptJob, err := queueReceiptPrint(db, order.ID, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		processed, err := processNextPrintJob()
+		if err != nil || !processed {
+			t.Fatalf("process print job %d: processed=%v err=%v", i+1, processed, err)
+		}
+	}
+	if processed, err := processNextPrintJob(); err != nil || processed {
+		t.Fatalf("print queue not empty: processed=%v err=%v", processed, err)
+	}
+
+	for _, id := range []uint{ticketJob.ID, receiptJob.ID} {
+		var job models.PrintJob
+		if err := db.First(&job, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		if job.State != "printed" || job.Attempts != 1 || job.PrintedAt == nil {
+			t.Fatalf("print job %d = %s after %d attempts, want printed after 1", job.ID, job.State, job.Attempts)
+		}
+	}
+
+	// Close espera a que la impresora termine de leer las conexiones
+	printer.Close()
+	var kitchen, receipt []byte
+	for _, data := range printer.Jobs() {
+		if bytes.Contains(data, []byte("Parrilla")) {
+			kitchen = data
+		} else {
+			receipt = data
+		}
+	}
+	if len(printer.Jobs()) != 2 || kitchen == nil || receipt == nil {
+		t.Fatalf("printer received %d documents, want a kitchen ticket and a receipt", len(printer.Jobs()))
+	}
+
+	if !bytes.Equal(kitchen, ticketJob.Data) {
+		t.Fatal("kitchen ticket bytes differ from the queued job")
+	}
+	for _, want := range [][]byte{[]byte("#7"), []byte("2 x Lomo saltado"), []byte("* Sin cebolla"), []byte("PARA LLEVAR")} {
+		if !bytes.Contains(kitchen, want) {
+			t.Fatalf("kitchen ticket missing %q", want)
+		}
+	}
+	if !bytes.HasPrefix(kitchen, escposInit) || !bytes.HasSuffix(kitchen, escposCut) {
+		t.Fatal("kitchen ticket does not initialize the printer and cut the paper")
+	}
+
+	if !bytes.Equal(receipt, receiptJob.Data) {
+		t.Fatal("receipt bytes differ from the queued job")
+	}
+	for _, want := range [][]byte{[]byte("Frontera"), []byte("Orden: SO/0001"), []byte("TOTAL"), []byte("23.60"), escposQR, []byte("SO/0001|"), append([]byte{0xAD}, "Gracias"...)} {
+		if !bytes.Contains(receipt, want) {
+			t.Fatalf("receipt missing %q", want)
+		}
+	}
+	if !bytes.HasPrefix(receipt, escposInit) || !bytes.HasSuffix(receipt, escposCut) {
+		t.Fatal("receipt does not initialize the printer and cut the paper")
+	}
+
+	// Impresora caída: el intento se registra y el trabajo espera antes de reintentar
+	offline, err := StartFakePrinter("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offline.Close()
+	if err := db.Model(&pos).Update("printer_ip", offline.Addr()).Error; err != nil {
+		t.Fatal(err)
+	}
+	retryJob, err := queueReceiptPrint(db, order.ID, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed, err := processNextPrintJob(); err != nil || !processed {
+		t.Fatalf("process offline print job: processed=%v err=%v", processed, err)
+	}
+
+	var job models.PrintJob
+	if err := db.First(&job, retryJob.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if job.State != "pending" || job.Attempts != 1 || job.LastError == "" || !job.NextAttemptAt.After(time.Now()) {
+		t.Fatalf("offline print job = %s, %d attempts, error %q, next attempt %v", job.State, job.Attempts, job.LastError, job.NextAttemptAt)
+	}
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefundLine - Línea a devolver (cantidad en unidades de la línea original)
+type RefundLine struct {
+	OrderItemID uint    `json:"order_item_id" binding:"required"`
+	Quantity    float64 `json:"quantity" binding:"required,gt=0"`
+	Waste       bool    `json:"waste"` // No vuelve al stock, se da de baja como merma
+}
+
+// RefundRequest - Datos de una devolución. Sin líneas se devuelve todo lo pendiente de la orden.
+type RefundRequest struct {
+	OrderID         uint
+	JournalID       *uint // Diario de notas de crédito (por defecto el diario refund de la compañía)
+	PaymentMethodID uint
+	Reason          string
+	Lines           []RefundLine
+	Waste           bool // Para devoluciones totales: todo se da de baja como merma
+	CreatedBy       *uint
+}
+
+// CreateRefund registra una devolución de una orden cerrada: nota de crédito numerada con los
+// impuestos de la venta original, pago negativo, salida de caja si es en efectivo y reingreso
+// (o merma) del inventario consumido.
+func (s *OrderService) CreateRefund(request RefundRequest) (*models.Refund, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, request.OrderID).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("order %d not found", request.OrderID)
+	}
+	if order.State != OrderStateDone {
+		tx.Rollback()
+		return nil, fmt.Errorf("order is %s, only done orders can be refunded", order.State)
+	}
+
+	var paymentMethod models.PaymentMethod
+	if err := tx.First(&paymentMethod, request.PaymentMethodID).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("payment method %d not found", request.PaymentMethodID)
+	}
+
+	var items []models.OrderItem
+	if err := tx.Preload("Taxes").Where("order_id = ? AND state <> ?", order.ID, "voided").Find(&items).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to load order items: %w", err)
+	}
+	itemsByID := map[uint]*models.OrderItem{}
+	for i := range items {
+		itemsByID[items[i].ID] = &items[i]
+	}
+
+	// Tasas congeladas al cerrar la orden: un cambio posterior del impuesto no altera la nota de crédito
+	var orderTaxes []models.OrderTax
+	if err := tx.Where("order_id = ?", order.ID).Find(&orderTaxes).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to load order taxes: %w", err)
+	}
+	frozen := map[uint]models.OrderTax{}
+	for _, orderTax := range orderTaxes {
+		frozen[orderTax.TaxID] = orderTax
+	}
+
+	// Cantidades ya devueltas por línea
+	var previous []struct {
+		OrderItemID uint
+		Quantity    float64
+	}
+	if err := tx.Model(&models.RefundItem{}).
+		Select("order_item_id, SUM(quantity) AS quantity").
+		Where("order_item_id IN (?)", tx.Model(&models.OrderItem{}).Select("id").Where("order_id = ?", order.ID)).
+		Group("order_item_id").
+		Scan(&previous).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to load previous refunds: %w", err)
+	}
+	refunded := map[uint]float64{}
+	for _, row := range previous {
+		refunded[row.OrderItemID] = row.Quantity
+	}
+
+	lines := request.Lines
+	if len(lines) == 0 {
+		for _, item := range items {
+			if pending := item.Quantity - refunded[item.ID]; pending > quantityEpsilon {
+				lines = append(lines, RefundLine{OrderItemID: item.ID, Quantity: pending, Waste: request.Waste})
+			}
+		}
+		if len(lines) == 0 {
+			tx.Rollback()
+			return nil, errors.New("order has already been fully refunded")
+		}
+	}
+
+	var saleJournal models.Journal
+	if err := tx.First(&saleJournal, order.JournalID).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("journal %d not found", order.JournalID)
+	}
+
+	refundJournal, err := s.refundJournal(tx, saleJournal.CompanyID, request.JournalID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	journalID := refundJournal.ID
+	name, err := nextSequenceNumber(tx, journalID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	refund := models.Refund{
+		OrderID:         order.ID,
+		JournalID:       journalID,
+		Name:            name,
+		RefundDate:      time.Now(),
+		Reason:          request.Reason,
+		PaymentMethodID: paymentMethod.ID,
+		CreatedBy:       request.CreatedBy,
+	}
+
+	var breakdown []*models.RefundTax
+	byTax := map[uint]*models.RefundTax{}
+	reversals := make([]SaleReversal, 0, len(lines))
+
+	for _, line := range lines {
+		item, ok := itemsByID[line.OrderItemID]
+		if !ok {
+			tx.Rollback()
+			return nil, fmt.Errorf("order item %d does not belong to order %d or is voided", line.OrderItemID, order.ID)
+		}
+
+		pending := item.Quantity - refunded[item.ID]
+		if line.Quantity > pending+quantityEpsilon {
+			tx.Rollback()
+			return nil, fmt.Errorf("order item %d: refund quantity %.2f exceeds the pending quantity %.2f", item.ID, line.Quantity, pending)
+		}
+		refunded[item.ID] += line.Quantity
+
+		// Mismos impuestos, tasas y redondeo que la venta original
+		subtotal, taxes := computeLine(line.Quantity, item.PriceUnit, frozenTaxes(item.Taxes, frozen))
+		priceTax := float64(0)
+		for _, lt := range taxes {
+			priceTax += lt.Amount
+
+			refundTax, ok := byTax[lt.Tax.ID]
+			if !ok {
+				refundTax = &models.RefundTax{
+					TaxID:            lt.Tax.ID,
+					Name:             lt.Tax.Name,
+					RatePercent:      lt.Tax.RatePercent,
+					IsPriceInclusive: lt.Tax.IsPriceInclusive,
+				}
+				byTax[lt.Tax.ID] = refundTax
+				breakdown = append(breakdown, refundTax)
+			}
+			refundTax.Base += subtotal
+			refundTax.Amount += lt.Amount
+		}
+		priceTax = utils.Round(priceTax, 2)
+
+		refund.Items = append(refund.Items, models.RefundItem{
+			OrderItemID:   item.ID,
+			ProductID:     item.ProductID,
+			Quantity:      line.Quantity,
+			PriceUnit:     item.PriceUnit,
+			PriceSubtotal: subtotal,
+			PriceTax:      priceTax,
+			PriceTotal:    utils.Round(subtotal+priceTax, 2),
+			Waste:         line.Waste,
+		})
+		refund.AmountUntaxed += subtotal
+		refund.AmountTax += priceTax
+
+		reversals = append(reversals, SaleReversal{OrderItemID: item.ID, Quantity: line.Quantity, Waste: line.Waste})
+	}
+
+	refund.AmountUntaxed = utils.Round(refund.AmountUntaxed, 2)
+	refund.AmountTax = utils.Round(refund.AmountTax, 2)
+	refund.TotalAmount = utils.Round(refund.AmountUntaxed+refund.AmountTax, 2)
+	for _, refundTax := range breakdown {
+		refundTax.Base = utils.Round(refundTax.Base, 2)
+		refundTax.Amount = utils.Round(refundTax.Amount, 2)
+		refund.TaxLines = append(refund.TaxLines, *refundTax)
+	}
+
+	// La devolución se registra en la sesión de caja abierta; el efectivo solo puede salir de una
+	session, err := s.sessionFor(tx, &order, nil)
+	if errors.Is(err, ErrNoOpenSession) && paymentMethod.Type != "cash" {
+		session, err = nil, nil
+	}
+	if errors.Is(err, ErrNoOpenSession) {
+		err = errors.New("there is no open cash session to pay out the refund")
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if session != nil {
+		refund.POSSessionID = &session.ID
+	}
+
+	if err := tx.Create(&refund).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create refund: %w", err)
+	}
+
+	// Pago negativo en el diario de caja con que se cobró (o el de la devolución)
+	paymentJournalID := journalID
+	var original models.OrderPayment
+	if result := tx.Where("order_id = ? AND payment_method_id = ? AND amount > 0", order.ID, paymentMethod.ID).
+		Order("id desc").Limit(1).Find(&original); result.Error == nil && result.RowsAffected > 0 {
+		paymentJournalID = original.JournalID
+	}
+
+	// Las devoluciones se pagan en moneda base
+	currency, err := baseCurrency(tx, saleJournal.CompanyID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	payment := models.OrderPayment{
+		OrderID:         order.ID,
+		PaymentMethodID: paymentMethod.ID,
+		JournalID:       paymentJournalID,
+		Amount:          -refund.TotalAmount,
+		Currency:        currency,
+		TenderedAmount:  -refund.TotalAmount,
+		ExchangeRate:    1,
+		PaymentDate:     refund.RefundDate,
+		RefundID:        &refund.ID,
+		POSSessionID:    refund.POSSessionID,
+	}
+	if err := tx.Create(&payment).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create refund payment: %w", err)
+	}
+
+	if paymentMethod.Type == "cash" {
+		userID := session.OpenedBy
+		if request.CreatedBy != nil {
+			userID = *request.CreatedBy
+		}
+		movement := models.CashMovement{
+			POSSessionID:   session.ID,
+			Type:           "out",
+			Concept:        "Devolución",
+			Amount:         refund.TotalAmount,
+			Currency:       currency,
+			TenderedAmount: refund.TotalAmount,
+			ExchangeRate:   1,
+			PaymentMethod:  "cash",
+			Reference:      refund.Name,
+			RefundID:       &refund.ID,
+			UserID:         userID,
+			Notes:          fmt.Sprintf("Devolución de la orden %s", order.Name),
+		}
+		if err := tx.Create(&movement).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to create cash movement: %w", err)
+		}
+	}
+
+	if err := NewInventoryService().reverseSale(tx, order.ID, reversals, request.CreatedBy); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	config.DB.Preload("Items").Preload("TaxLines").Preload("Payments").First(&refund, refund.ID)
+	return &refund, nil
+}
+
+// frozenTaxes reemplaza la tasa vigente de cada impuesto por la registrada en el desglose de la
+// orden. Las órdenes anteriores al desglose conservan la tasa del impuesto.
+func frozenTaxes(taxes []models.Tax, frozen map[uint]models.OrderTax) []models.Tax {
+	result := make([]models.Tax, len(taxes))
+	for i, tax := range taxes {
+		if orderTax, ok := frozen[tax.ID]; ok {
+			tax.Name = orderTax.Name
+			tax.RatePercent = orderTax.RatePercent
+			tax.IsPriceInclusive = orderTax.IsPriceInclusive
+		}
+		result[i] = tax
+	}
+	return result
+}
+
+// refundJournal resuelve el diario que numera la nota de crédito: el indicado o el primer diario
+// activo de tipo refund de la compañía. Las notas de crédito nunca comparten la numeración de las ventas.
+func (s *OrderService) refundJournal(tx *gorm.DB, companyID uint, requested *uint) (*models.Journal, error) {
+	var journal models.Journal
+	if requested != nil {
+		if err := tx.First(&journal, *requested).Error; err != nil {
+			return nil, fmt.Errorf("journal %d not found", *requested)
+		}
+		if journal.Type != "refund" {
+			return nil, fmt.Errorf("journal %s is a %s journal, refunds require a refund journal", journal.Code, journal.Type)
+		}
+		if journal.CompanyID != companyID {
+			return nil, fmt.Errorf("journal %s belongs to another company", journal.Code)
+		}
+		return &journal, nil
+	}
+
+	result := tx.Where("company_id = ? AND type = ? AND is_active = ?", companyID, "refund", true).
+		Order("id asc").
+		Limit(1).
+		Find(&journal)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to load refund journal: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("company %d has no refund journal: create a journal of type refund with its sequence", companyID)
+	}
+
+	return &journal, nil
+}
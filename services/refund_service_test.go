@@ -0,0 +1,203 @@
+package services
+
+import (
+	"b-resto/models"
+	"b-resto/testutil"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRefundLimitsPaymentsAndCashPayout cubre una devolución parcial y la total del resto: respeta
+// lo pendiente por línea, usa el diario de notas de crédito y las tasas congeladas de la venta,
+// registra el pago negativo y saca el efectivo de la sesión abierta.
+func TestRefundLimitsPaymentsAndCashPayout(t *testing.T) {
+	db := testutil.OpenDB(t)
+
+	company := models.Company{Name: "Frontera", BusinessName: "Frontera SAC", CurrencyCode: "PEN"}
+	if err := db.Create(&company).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: "cajero", Email: "cajero@b-resto.test", Password: "secret", Role: models.UserRole}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	pos := models.POS{CompanyID: company.ID, Code: "CAJA-1", Name: "Caja 1", IsActive: true}
+	if err := db.Create(&pos).Error; err != nil {
+		t.Fatal(err)
+	}
+	session := models.POSSession{POSID: pos.ID, OpeningBalance: 100, OpenedBy: user.ID, OpenedAt: time.Now(), Status: SessionStatusOpen}
+	if err := db.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	saleJournal := models.Journal{CompanyID: company.ID, Code: "F001", Name: "Ventas", Type: "sale", IsActive: true}
+	cashJournal := models.Journal{CompanyID: company.ID, Code: "CAJA", Name: "Caja", Type: "cash", IsActive: true}
+	refundJournal := models.Journal{CompanyID: company.ID, Code: "NC", Name: "Notas de crédito", Type: "refund", IsActive: true}
+	for _, journal := range []*models.Journal{&saleJournal, &cashJournal, &refundJournal} {
+		if err := db.Create(journal).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	sequence := models.Sequence{JournalID: refundJournal.ID, Name: "NC", Padding: 5, NextNumber: 1, IsActive: true}
+	if err := db.Create(&sequence).Error; err != nil {
+		t.Fatal(err)
+	}
+	cash := models.PaymentMethod{Code: "CASH", Name: "Efectivo", Type: "cash", IsActive: true}
+	if err := db.Create(&cash).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	unit := models.Unit{Name: "Unidad", Abbreviation: "und", Type: "unit", Factor: 1, IsActive: true}
+	if err := db.Create(&unit).Error; err != nil {
+		t.Fatal(err)
+	}
+	category := models.ProductCategory{Name: "Platos"}
+	if err := db.Create(&category).Error; err != nil {
+		t.Fatal(err)
+	}
+	template := models.ProductTemplate{CategoryID: category.ID, UnitID: unit.ID, Name: "Ceviche", ProductType: "service", CanBeSold: true, IsActive: true}
+	if err := db.Create(&template).Error; err != nil {
+		t.Fatal(err)
+	}
+	product := models.ProductProduct{TemplateID: template.ID, SKU: "CEV-1", IsActive: true}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	igv := models.Tax{Name: "IGV", TaxType: "igv", RatePercent: 18, IsPriceInclusive: true, IsActive: true}
+	if err := db.Create(&igv).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	order := models.Order{
+		JournalID:    saleJournal.ID,
+		UserID:       user.ID,
+		POSID:        &pos.ID,
+		POSSessionID: &session.ID,
+		Name:         "F001/00001",
+		State:        "confirmed",
+		OrderDate:    time.Now(),
+		Items: []models.OrderItem{
+			{ProductID: product.ID, Quantity: 3, PriceUnit: 11.80, Taxes: []models.Tax{igv}},
+			{ProductID: product.ID, Quantity: 1, PriceUnit: 5.90, Taxes: []models.Tax{igv}},
+		},
+	}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+	lineA, lineB := order.Items[0].ID, order.Items[1].ID
+
+	service := NewOrderService()
+	if err := service.ComputeTotals(db, order.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(&order, order.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&order).Update("state", OrderStateDone).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.OrderPayment{
+		OrderID:         order.ID,
+		PaymentMethodID: cash.ID,
+		JournalID:       cashJournal.ID,
+		Amount:          order.TotalAmount,
+		PaymentDate:     time.Now(),
+		POSSessionID:    &session.ID,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// La nota de crédito usa la tasa de la venta aunque el impuesto cambie después
+	if err := db.Model(&igv).Update("rate_percent", 10).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	refund := func(lines []RefundLine, journalID *uint) (*models.Refund, error) {
+		return service.CreateRefund(RefundRequest{
+			OrderID:         order.ID,
+			JournalID:       journalID,
+			PaymentMethodID: cash.ID,
+			Reason:          "Cliente insatisfecho",
+			Lines:           lines,
+			CreatedBy:       &user.ID,
+		})
+	}
+
+	if _, err := refund([]RefundLine{{OrderItemID: lineA, Quantity: 1}}, &saleJournal.ID); err == nil {
+		t.Fatal("refund numbered in the sales journal")
+	}
+
+	partial, err := refund([]RefundLine{{OrderItemID: lineA, Quantity: 1}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partial.Name != "NC/00001" || partial.JournalID != refundJournal.ID {
+		t.Fatalf("partial refund numbered %s in journal %d, want NC/00001 in %d", partial.Name, partial.JournalID, refundJournal.ID)
+	}
+	if partial.AmountUntaxed != 10 || partial.AmountTax != 1.80 || partial.TotalAmount != 11.80 {
+		t.Fatalf("partial refund amounts = %.2f + %.2f = %.2f, want 10.00 + 1.80 = 11.80",
+			partial.AmountUntaxed, partial.AmountTax, partial.TotalAmount)
+	}
+	if len(partial.TaxLines) != 1 || partial.TaxLines[0].RatePercent != 18 {
+		t.Fatalf("partial refund tax lines = %+v, want IGV at 18%%", partial.TaxLines)
+	}
+	if len(partial.Payments) != 1 || partial.Payments[0].Amount != -11.80 {
+		t.Fatalf("partial refund payments = %+v, want one payment of -11.80", partial.Payments)
+	}
+	if partial.Payments[0].JournalID != cashJournal.ID {
+		t.Fatalf("refund payment journal = %d, want the cash journal %d", partial.Payments[0].JournalID, cashJournal.ID)
+	}
+
+	var movement models.CashMovement
+	if err := db.Where("refund_id = ?", partial.ID).First(&movement).Error; err != nil {
+		t.Fatalf("cash refund without cash movement: %v", err)
+	}
+	if movement.Type != "out" || movement.Amount != 11.80 || movement.POSSessionID != session.ID {
+		t.Fatalf("cash movement = %s %.2f in session %d, want out 11.80 in %d", movement.Type, movement.Amount, movement.POSSessionID, session.ID)
+	}
+
+	// Solo quedan 2 de la primera línea
+	if _, err := refund([]RefundLine{{OrderItemID: lineA, Quantity: 3}}, nil); err == nil || !strings.Contains(err.Error(), "exceeds the pending quantity") {
+		t.Fatalf("over-refund error = %v", err)
+	}
+
+	rest, err := refund(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rest.Name != "NC/00002" || rest.TotalAmount != 29.50 || len(rest.Items) != 2 {
+		t.Fatalf("full refund = %s %.2f with %d lines, want NC/00002 29.50 with 2 lines", rest.Name, rest.TotalAmount, len(rest.Items))
+	}
+	for _, item := range rest.Items {
+		if item.OrderItemID == lineA && item.Quantity != 2 || item.OrderItemID == lineB && item.Quantity != 1 {
+			t.Fatalf("full refund line %d quantity = %.2f", item.OrderItemID, item.Quantity)
+		}
+	}
+
+	if _, err := refund(nil, nil); err == nil || !strings.Contains(err.Error(), "fully refunded") {
+		t.Fatalf("refund of a fully refunded order error = %v", err)
+	}
+	if _, err := refund([]RefundLine{{OrderItemID: lineB, Quantity: 1}}, nil); err == nil {
+		t.Fatal("refunded a line twice")
+	}
+
+	var paid float64
+	if err := db.Model(&models.OrderPayment{}).Where("order_id = ?", order.ID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&paid).Error; err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(paid) > 0.005 {
+		t.Fatalf("net paid after refunding everything = %.2f, want 0", paid)
+	}
+
+	var cashOut float64
+	if err := db.Model(&models.CashMovement{}).Where("cash_register_id = ? AND type = ?", session.ID, "out").
+		Select("COALESCE(SUM(amount), 0)").Scan(&cashOut).Error; err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(cashOut-order.TotalAmount) > 0.005 {
+		t.Fatalf("cash paid out = %.2f, want %.2f", cashOut, order.TotalAmount)
+	}
+}
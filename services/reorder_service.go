@@ -0,0 +1,182 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/utils"
+	"fmt"
+	"math"
+	"time"
+)
+
+// LowStockItem - Producto por debajo del mínimo de su regla de reabastecimiento
+type LowStockItem struct {
+	Rule              models.ReorderRule `json:"rule"`
+	CurrentStock      float64            `json:"current_stock"`
+	SuggestedQuantity float64            `json:"suggested_quantity"` // Hasta el máximo, en múltiplos de la regla
+}
+
+// GetLowStock evalúa el saldo actual de cada producto contra sus reglas de reabastecimiento activas
+func (s *InventoryService) GetLowStock(warehouseID string) ([]LowStockItem, error) {
+	var rules []models.ReorderRule
+
+	query := config.DB.Where("is_active = ?", true)
+	if warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+	}
+	if err := query.Preload("Product").Preload("Warehouse").Preload("Partner").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	items := []LowStockItem{}
+	for _, rule := range rules {
+		quant, err := s.stockQuant(config.DB, rule.ProductID, rule.WarehouseID)
+		if err != nil {
+			return nil, err
+		}
+		current := quant.Quantity
+		if current >= rule.MinQuantity {
+			continue
+		}
+
+		items = append(items, LowStockItem{
+			Rule:              rule,
+			CurrentStock:      current,
+			SuggestedQuantity: suggestedQuantity(rule, current),
+		})
+	}
+
+	return items, nil
+}
+
+// ProposePurchaseOrders crea una orden de compra en borrador por proveedor preferido y almacén
+// con todo lo que está bajo el mínimo. Devuelve también los productos sin proveedor asignado.
+func (s *InventoryService) ProposePurchaseOrders(warehouseID string, createdBy *uint) ([]models.PurchaseOrder, []LowStockItem, error) {
+	items, err := s.GetLowStock(warehouseID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type groupKey struct {
+		PartnerID   uint
+		WarehouseID uint
+	}
+	groups := map[groupKey][]LowStockItem{}
+	var keys []groupKey
+	withoutSupplier := []LowStockItem{}
+
+	for _, item := range items {
+		if item.Rule.PartnerID == nil {
+			withoutSupplier = append(withoutSupplier, item)
+			continue
+		}
+		key := groupKey{PartnerID: *item.Rule.PartnerID, WarehouseID: item.Rule.WarehouseID}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], item)
+	}
+
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	orders := []models.PurchaseOrder{}
+	for _, key := range keys {
+		var warehouse models.Warehouse
+		if err := tx.First(&warehouse, key.WarehouseID).Error; err != nil {
+			tx.Rollback()
+			return nil, nil, fmt.Errorf("warehouse %d not found", key.WarehouseID)
+		}
+
+		order := models.PurchaseOrder{
+			OrderNumber: "DRAFT",
+			CompanyID:   warehouse.CompanyID,
+			WarehouseID: key.WarehouseID,
+			PartnerID:   key.PartnerID,
+			OrderDate:   time.Now(),
+			Status:      "draft",
+			Notes:       "Generada automáticamente por reglas de reabastecimiento",
+			CreatedBy:   createdBy,
+		}
+
+		for _, item := range groups[key] {
+			unitPrice := s.lastPurchasePrice(item.Rule.ProductID, key.PartnerID, key.WarehouseID)
+			subtotal := utils.Round(item.SuggestedQuantity*unitPrice, 2)
+			order.Items = append(order.Items, models.PurchaseOrderItem{
+				ProductID: item.Rule.ProductID,
+				Quantity:  item.SuggestedQuantity,
+				UnitPrice: unitPrice,
+				Subtotal:  subtotal,
+			})
+			order.Subtotal += subtotal
+		}
+		order.Subtotal = utils.Round(order.Subtotal, 2)
+		order.Total = order.Subtotal
+
+		if err := tx.Create(&order).Error; err != nil {
+			tx.Rollback()
+			return nil, nil, fmt.Errorf("failed to create purchase order: %w", err)
+		}
+
+		order.OrderNumber = fmt.Sprintf("PO%05d", order.ID)
+		if err := tx.Model(&order).Update("order_number", order.OrderNumber).Error; err != nil {
+			tx.Rollback()
+			return nil, nil, fmt.Errorf("failed to number purchase order: %w", err)
+		}
+
+		orders = append(orders, order)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, nil, err
+	}
+
+	return orders, withoutSupplier, nil
+}
+
+// lastPurchasePrice toma el último precio pagado al proveedor, o el costo vigente del Kardex
+func (s *InventoryService) lastPurchasePrice(productID, partnerID, warehouseID uint) float64 {
+	var item models.PurchaseOrderItem
+	result := config.DB.
+		Joins("JOIN purchase_orders ON purchase_orders.id = purchase_order_items.purchase_order_id").
+		Where("purchase_order_items.product_id = ? AND purchase_orders.partner_id = ?", productID, partnerID).
+		Order("purchase_order_items.id desc").
+		Limit(1).
+		Find(&item)
+	if result.Error == nil && result.RowsAffected > 0 {
+		return item.UnitPrice
+	}
+
+	quant, err := s.stockQuant(config.DB, productID, warehouseID)
+	if err != nil {
+		return 0
+	}
+	return quant.UnitCost
+}
+
+// suggestedQuantity calcula la cantidad a pedir para llegar al máximo, redondeada al múltiplo superior
+func suggestedQuantity(rule models.ReorderRule, current float64) float64 {
+	target := rule.MaxQuantity
+	if target < rule.MinQuantity {
+		target = rule.MinQuantity
+	}
+
+	needed := target - current
+	if needed <= 0 {
+		return 0
+	}
+
+	if rule.ReorderMultiple > 0 {
+		needed = math.Ceil(needed/rule.ReorderMultiple-quantityEpsilon) * rule.ReorderMultiple
+	}
+
+	return utils.Round(needed, 4)
+}
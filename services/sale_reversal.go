@@ -0,0 +1,159 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"fmt"
+	"math"
+
+	"gorm.io/gorm"
+)
+
+// SaleReversal - Devolución total o parcial de una línea vendida
+type SaleReversal struct {
+	OrderItemID uint
+	Quantity    float64 // En unidades de la línea de venta
+	Waste       bool    // Lo devuelto se da de baja como merma en lugar de volver al stock
+}
+
+// ReverseSale registra entradas compensatorias por lo que descontó una venta
+func (s *InventoryService) ReverseSale(orderID uint, reversals []SaleReversal, createdBy *uint) error {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := s.reverseSale(tx, orderID, reversals, createdBy); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// reverseSale devuelve al stock, al costo con que salieron, los productos e ingredientes de receta
+// que descontaron las líneas indicadas. Cada salida original solo puede compensarse hasta su cantidad,
+// así una línea no se devuelve dos veces. Con Waste, lo devuelto sale luego como ajuste por merma.
+func (s *InventoryService) reverseSale(tx *gorm.DB, orderID uint, reversals []SaleReversal, createdBy *uint) error {
+	for _, reversal := range reversals {
+		var item models.OrderItem
+		if err := tx.Where("order_id = ?", orderID).First(&item, reversal.OrderItemID).Error; err != nil {
+			return fmt.Errorf("order item %d not found", reversal.OrderItemID)
+		}
+		if item.Quantity <= 0 {
+			continue
+		}
+
+		consumed, reversed, err := s.reverseItem(tx, orderID, &item, reversal.Quantity/item.Quantity, reversal.Waste, createdBy)
+		if err != nil {
+			return err
+		}
+		if consumed && !reversed {
+			return fmt.Errorf("order item %d was already returned to stock", item.ID)
+		}
+	}
+
+	return nil
+}
+
+// reverseOrder devuelve al stock todo lo que aún no se devolvió de una orden cerrada
+func (s *InventoryService) reverseOrder(tx *gorm.DB, order *models.Order, waste bool, createdBy *uint) error {
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ? AND state <> ?", order.ID, "voided").Find(&items).Error; err != nil {
+		return fmt.Errorf("failed to load order items: %w", err)
+	}
+
+	for i := range items {
+		if _, _, err := s.reverseItem(tx, order.ID, &items[i], 1, waste, createdBy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reverseItem compensa la proporción indicada de lo que descontó una línea. Devuelve si la línea
+// consumió stock y si quedó algo por devolver.
+func (s *InventoryService) reverseItem(tx *gorm.DB, orderID uint, item *models.OrderItem, ratio float64, waste bool, createdBy *uint) (bool, bool, error) {
+	ratio = math.Min(ratio, 1)
+
+	// Salidas de la venta (los ajustes por merma de devoluciones previas no cuentan)
+	var outputs []models.Inventory
+	if err := tx.Where("order_id = ? AND order_item_id = ? AND quantity_out > 0 AND adjustment_id IS NULL", orderID, item.ID).
+		Order("id asc").
+		Find(&outputs).Error; err != nil {
+		return false, false, fmt.Errorf("failed to load sale movements: %w", err)
+	}
+	if len(outputs) == 0 {
+		// Servicios o líneas sin consumo de stock
+		return false, false, nil
+	}
+
+	keys := make([]stockKey, 0, len(outputs))
+	for _, output := range outputs {
+		keys = append(keys, stockKey{output.ProductID, output.WarehouseID})
+	}
+	if err := lockStock(tx, keys...); err != nil {
+		return true, false, err
+	}
+
+	reversed := false
+	for _, output := range outputs {
+		var alreadyReversed float64
+		if err := tx.Model(&models.Inventory{}).
+			Where("reversed_inventory_id = ?", output.ID).
+			Select("COALESCE(SUM(quantity_in), 0)").
+			Scan(&alreadyReversed).Error; err != nil {
+			return true, false, fmt.Errorf("failed to load previous reversals: %w", err)
+		}
+
+		quantity := math.Min(output.QuantityOut*ratio, output.QuantityOut-alreadyReversed)
+		if quantity <= quantityEpsilon {
+			continue
+		}
+
+		outputID := output.ID
+		if _, err := s.postKardex(tx, kardexEntry{
+			ProductID:   output.ProductID,
+			WarehouseID: output.WarehouseID,
+			OrderID:     &orderID,
+			OrderItemID: &item.ID,
+			ReversedID:  &outputID,
+			Detail:      fmt.Sprintf("Devolución - Order #%d", orderID),
+			QuantityIn:  quantity,
+			CostIn:      output.CostOut,
+		}); err != nil {
+			return true, false, err
+		}
+		reversed = true
+
+		if waste {
+			adjustment := models.InventoryAdjustment{
+				WarehouseID: output.WarehouseID,
+				ProductID:   output.ProductID,
+				Type:        "out",
+				Quantity:    quantity,
+				Reason:      models.AdjustmentReasonWaste,
+				UnitCost:    output.CostOut,
+				Status:      "pending",
+				Notes:       fmt.Sprintf("Merma por devolución de la orden #%d", orderID),
+				CreatedBy:   createdBy,
+				OrderID:     &orderID,
+			}
+			if err := tx.Create(&adjustment).Error; err != nil {
+				return true, false, fmt.Errorf("failed to create waste adjustment: %w", err)
+			}
+			if err := s.applyAdjustment(tx, &adjustment, createdBy); err != nil {
+				return true, false, err
+			}
+		}
+	}
+
+	return true, reversed, nil
+}
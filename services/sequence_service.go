@@ -0,0 +1,34 @@
+package services
+
+import (
+	"b-resto/models"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// nextSequenceNumber toma el siguiente número de la secuencia activa de un diario (ej: NC/00001).
+// La secuencia se bloquea hasta el fin de la transacción para no repetir números.
+func nextSequenceNumber(tx *gorm.DB, journalID uint) (string, error) {
+	var sequence models.Sequence
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("journal_id = ? AND is_active = ?", journalID, true).
+		Order("id asc").
+		First(&sequence).Error; err != nil {
+		return "", fmt.Errorf("journal %d has no active sequence", journalID)
+	}
+
+	number := sequence.NextNumber
+	if err := tx.Model(&sequence).Update("next_number", number+1).Error; err != nil {
+		return "", fmt.Errorf("failed to update sequence: %w", err)
+	}
+
+	prefix := sequence.Name
+	var journal models.Journal
+	if err := tx.First(&journal, journalID).Error; err == nil && journal.Code != "" {
+		prefix = journal.Code
+	}
+
+	return fmt.Sprintf("%s/%0*d", prefix, sequence.Padding, number), nil
+}
@@ -0,0 +1,475 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tipos de reporte de sesión
+const (
+	ReportTypeX = "x" // Parcial: no cierra la sesión ni se guarda
+	ReportTypeZ = "z" // Cierre: se genera al cerrar la sesión, numerado e inmutable
+)
+
+// SessionReport es el contenido de un reporte X o Z de una sesión de caja
+type SessionReport struct {
+	Type        string     `json:"type"`
+	Name        string     `json:"name"` // Z-0001 (vacío en los reportes X)
+	POSID       uint       `json:"pos_id"`
+	POSName     string     `json:"pos_name"`
+	SessionID   uint       `json:"session_id"`
+	OpenedAt    time.Time  `json:"opened_at"`
+	ClosedAt    *time.Time `json:"closed_at"`
+	GeneratedAt time.Time  `json:"generated_at"`
+
+	TicketCount   int     `json:"ticket_count"`
+	NetSales      float64 `json:"net_sales"` // Sin impuestos
+	TaxTotal      float64 `json:"tax_total"`
+	GrossSales    float64 `json:"gross_sales"` // Con impuestos
+	AverageTicket float64 `json:"average_ticket"`
+	Discounts     float64 `json:"discounts"` // Las órdenes aún no admiten descuentos: siempre 0
+
+	Payments   []ReportPaymentLine  `json:"payments"`
+	Categories []ReportCategoryLine `json:"categories"`
+	Taxes      []ReportTaxLine      `json:"taxes"`
+	Refunds    ReportRefunds        `json:"refunds"`
+	Voids      ReportVoids          `json:"voids"`
+
+	Cash          SessionCash           `json:"cash"`
+	Counted       *float64              `json:"counted"`    // Efectivo en moneda base contado al cerrar (solo Z)
+	Difference    *float64              `json:"difference"` // Contado - esperado en moneda base (solo Z); cada moneda en Cash.Drawers
+	CashMovements []models.CashMovement `json:"cash_movements"`
+}
+
+// ReportPaymentLine - Cobros de un medio de pago
+type ReportPaymentLine struct {
+	PaymentMethodID uint    `json:"payment_method_id"`
+	Name            string  `json:"name"`
+	Count           int     `json:"count"`
+	Amount          float64 `json:"amount"`
+}
+
+// ReportCategoryLine - Ventas de una categoría del menú
+type ReportCategoryLine struct {
+	CategoryID uint    `json:"category_id"`
+	Name       string  `json:"name"`
+	Quantity   float64 `json:"quantity"`
+	Subtotal   float64 `json:"subtotal"`
+	Total      float64 `json:"total"`
+}
+
+// ReportTaxLine - Base e importe de una tasa de impuesto
+type ReportTaxLine struct {
+	TaxID       uint    `json:"tax_id"`
+	Name        string  `json:"name"`
+	RatePercent float64 `json:"rate_percent"`
+	Base        float64 `json:"base"`
+	Amount      float64 `json:"amount"`
+}
+
+// ReportRefunds - Devoluciones emitidas durante la sesión
+type ReportRefunds struct {
+	Count    int                 `json:"count"`
+	Total    float64             `json:"total"`
+	ByMethod []ReportPaymentLine `json:"by_method"`
+}
+
+// ReportVoids - Líneas anuladas y órdenes canceladas durante la sesión
+type ReportVoids struct {
+	Lines           int     `json:"lines"`
+	LinesAmount     float64 `json:"lines_amount"`
+	CancelledOrders int     `json:"cancelled_orders"`
+	CancelledAmount float64 `json:"cancelled_amount"`
+}
+
+// SessionReportService genera los reportes X y Z de las sesiones de caja
+type SessionReportService struct{}
+
+// NewSessionReportService crea una nueva instancia del servicio
+func NewSessionReportService() *SessionReportService {
+	return &SessionReportService{}
+}
+
+// XReport genera el reporte parcial de una sesión abierta o en reconteo (no se guarda)
+func (s *SessionReportService) XReport(sessionID uint) (*SessionReport, error) {
+	var session models.POSSession
+	if err := config.DB.First(&session, sessionID).Error; err != nil {
+		return nil, fmt.Errorf("POS session %d not found", sessionID)
+	}
+	if session.Status == SessionStatusClosed {
+		return nil, errors.New("session is closed, use its Z report")
+	}
+
+	return buildSessionReport(config.DB, &session, ReportTypeX, time.Now())
+}
+
+// ZReport obtiene el reporte Z guardado al cerrar la sesión
+func (s *SessionReportService) ZReport(sessionID uint) (*models.ZReport, *SessionReport, error) {
+	var zReport models.ZReport
+	if err := config.DB.Where("pos_session_id = ?", sessionID).First(&zReport).Error; err != nil {
+		return nil, nil, fmt.Errorf("session %d has no Z report", sessionID)
+	}
+
+	var report SessionReport
+	if err := json.Unmarshal([]byte(zReport.Payload), &report); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode Z report: %w", err)
+	}
+
+	return &zReport, &report, nil
+}
+
+// createZReport genera y guarda el reporte Z de una sesión que se está cerrando. El terminal queda
+// bloqueado hasta el fin de la transacción para no repetir el correlativo.
+func createZReport(tx *gorm.DB, session *models.POSSession, generatedBy *uint) (*models.ZReport, error) {
+	var pos models.POS
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&pos, session.POSID).Error; err != nil {
+		return nil, fmt.Errorf("POS terminal %d not found", session.POSID)
+	}
+
+	var last int
+	if err := tx.Model(&models.ZReport{}).
+		Where("pos_id = ?", pos.ID).
+		Select("COALESCE(MAX(sequence), 0)").
+		Scan(&last).Error; err != nil {
+		return nil, fmt.Errorf("failed to load Z report sequence: %w", err)
+	}
+
+	until := time.Now()
+	if session.ClosedAt != nil {
+		until = *session.ClosedAt
+	}
+	report, err := buildSessionReport(tx, session, ReportTypeZ, until)
+	if err != nil {
+		return nil, err
+	}
+	report.Name = fmt.Sprintf("Z-%04d", last+1)
+
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Z report: %w", err)
+	}
+
+	zReport := models.ZReport{
+		POSID:        pos.ID,
+		POSSessionID: session.ID,
+		Sequence:     last + 1,
+		Name:         report.Name,
+		TicketCount:  report.TicketCount,
+		TotalSales:   report.GrossSales,
+		TotalRefunds: report.Refunds.Total,
+		Payload:      string(payload),
+		GeneratedBy:  generatedBy,
+	}
+	if err := tx.Create(&zReport).Error; err != nil {
+		return nil, fmt.Errorf("failed to create Z report: %w", err)
+	}
+
+	return &zReport, nil
+}
+
+// sessionOrders filtra las órdenes cerradas de la sesión
+func sessionOrders(tx *gorm.DB, session *models.POSSession, until time.Time) *gorm.DB {
+	return tx.Model(&models.Order{}).
+		Select("id").
+		Scopes(inSession(session, until, "orders", "orders.pos_id", "COALESCE(orders.completed_at, orders.updated_at)")).
+		Where("state = ?", OrderStateDone)
+}
+
+// buildSessionReport arma el reporte con las ventas, cobros, devoluciones, anulaciones y el
+// efectivo de la sesión hasta until
+func buildSessionReport(tx *gorm.DB, session *models.POSSession, reportType string, until time.Time) (*SessionReport, error) {
+	var pos models.POS
+	if err := tx.First(&pos, session.POSID).Error; err != nil {
+		return nil, fmt.Errorf("POS terminal %d not found", session.POSID)
+	}
+
+	report := &SessionReport{
+		Type:          reportType,
+		POSID:         pos.ID,
+		POSName:       pos.Name,
+		SessionID:     session.ID,
+		OpenedAt:      session.OpenedAt,
+		ClosedAt:      session.ClosedAt,
+		GeneratedAt:   time.Now(),
+		Payments:      []ReportPaymentLine{},
+		Categories:    []ReportCategoryLine{},
+		Taxes:         []ReportTaxLine{},
+		CashMovements: []models.CashMovement{},
+	}
+	report.Refunds.ByMethod = []ReportPaymentLine{}
+
+	// Ventas
+	var sales struct {
+		Count   int
+		Untaxed float64
+		Tax     float64
+		Total   float64
+	}
+	if err := tx.Model(&models.Order{}).
+		Where("id IN (?)", sessionOrders(tx, session, until)).
+		Select("COUNT(*) AS count, COALESCE(SUM(amount_untaxed), 0) AS untaxed, COALESCE(SUM(amount_tax), 0) AS tax, COALESCE(SUM(total_amount), 0) AS total").
+		Scan(&sales).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum sales: %w", err)
+	}
+	report.TicketCount = sales.Count
+	report.NetSales = utils.Round(sales.Untaxed, 2)
+	report.TaxTotal = utils.Round(sales.Tax, 2)
+	report.GrossSales = utils.Round(sales.Total, 2)
+	if sales.Count > 0 {
+		report.AverageTicket = utils.Round(sales.Total/float64(sales.Count), 2)
+	}
+
+	// Cobros por medio de pago (las devoluciones se informan aparte)
+	if err := tx.Model(&models.OrderPayment{}).
+		Joins("JOIN orders ON orders.id = order_payments.order_id AND orders.deleted_at IS NULL").
+		Joins("JOIN payment_methods ON payment_methods.id = order_payments.payment_method_id").
+		Scopes(inSession(session, until, "order_payments", "orders.pos_id", "order_payments.created_at")).
+		Where("order_payments.refund_id IS NULL").
+		Select("payment_methods.id AS payment_method_id, payment_methods.name AS name, COUNT(*) AS count, COALESCE(SUM(order_payments.amount), 0) AS amount").
+		Group("payment_methods.id, payment_methods.name").
+		Order("payment_methods.name").
+		Scan(&report.Payments).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum payments: %w", err)
+	}
+
+	// Ventas por categoría
+	if err := tx.Model(&models.OrderItem{}).
+		Joins("JOIN product_product ON product_product.id = order_items.product_id").
+		Joins("JOIN product_template ON product_template.id = product_product.template_id").
+		Joins("LEFT JOIN product_categories ON product_categories.id = product_template.category_id").
+		Where("order_items.order_id IN (?) AND order_items.state <> ?", sessionOrders(tx, session, until), "voided").
+		Select("product_template.category_id AS category_id, COALESCE(product_categories.name, '') AS name, " +
+			"COALESCE(SUM(order_items.quantity), 0) AS quantity, COALESCE(SUM(order_items.price_subtotal), 0) AS subtotal, " +
+			"COALESCE(SUM(order_items.price_total), 0) AS total").
+		Group("product_template.category_id, product_categories.name").
+		Order("total desc").
+		Scan(&report.Categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum sales by category: %w", err)
+	}
+
+	// Impuestos por tasa
+	if err := tx.Model(&models.OrderTax{}).
+		Where("order_id IN (?)", sessionOrders(tx, session, until)).
+		Select("tax_id, name, rate_percent, COALESCE(SUM(base), 0) AS base, COALESCE(SUM(amount), 0) AS amount").
+		Group("tax_id, name, rate_percent").
+		Order("rate_percent desc").
+		Scan(&report.Taxes).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum taxes: %w", err)
+	}
+
+	// Devoluciones
+	if err := tx.Model(&models.Refund{}).
+		Joins("JOIN orders ON orders.id = refunds.order_id").
+		Joins("JOIN payment_methods ON payment_methods.id = refunds.payment_method_id").
+		Scopes(inSession(session, until, "refunds", "orders.pos_id", "refunds.created_at")).
+		Select("payment_methods.id AS payment_method_id, payment_methods.name AS name, COUNT(*) AS count, COALESCE(SUM(refunds.total_amount), 0) AS amount").
+		Group("payment_methods.id, payment_methods.name").
+		Order("payment_methods.name").
+		Scan(&report.Refunds.ByMethod).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum refunds: %w", err)
+	}
+
+	// Anulaciones: líneas anuladas y órdenes canceladas de la sesión
+	var voided struct {
+		Count  int
+		Amount float64
+	}
+	if err := tx.Model(&models.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Scopes(inSession(session, until, "orders", "orders.pos_id", "order_items.voided_at")).
+		Where("order_items.state = ?", "voided").
+		Select("COUNT(*) AS count, COALESCE(SUM(order_items.quantity * order_items.price_unit), 0) AS amount").
+		Scan(&voided).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum voided lines: %w", err)
+	}
+	report.Voids.Lines = voided.Count
+	report.Voids.LinesAmount = utils.Round(voided.Amount, 2)
+
+	var cancelled struct {
+		Count  int
+		Amount float64
+	}
+	if err := tx.Model(&models.Order{}).
+		Scopes(inSession(session, until, "orders", "orders.pos_id", "orders.updated_at")).
+		Where("state = ? AND merged_into_id IS NULL", OrderStateCancelled).
+		Select("COUNT(*) AS count, COALESCE(SUM(total_amount), 0) AS amount").
+		Scan(&cancelled).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum cancelled orders: %w", err)
+	}
+	report.Voids.CancelledOrders = cancelled.Count
+	report.Voids.CancelledAmount = utils.Round(cancelled.Amount, 2)
+
+	// Efectivo
+	cash, err := sessionCash(tx, session, until)
+	if err != nil {
+		return nil, err
+	}
+	report.Cash = *cash
+	report.Counted = session.ClosingBalance
+	report.Difference = session.Difference
+
+	if err := tx.Where("cash_register_id = ?", session.ID).Order("id asc").Find(&report.CashMovements).Error; err != nil {
+		return nil, fmt.Errorf("failed to load cash movements: %w", err)
+	}
+
+	for i := range report.Payments {
+		report.Payments[i].Amount = utils.Round(report.Payments[i].Amount, 2)
+	}
+	for i := range report.Categories {
+		report.Categories[i].Quantity = utils.Round(report.Categories[i].Quantity, 2)
+		report.Categories[i].Subtotal = utils.Round(report.Categories[i].Subtotal, 2)
+		report.Categories[i].Total = utils.Round(report.Categories[i].Total, 2)
+		if report.Categories[i].Name == "" {
+			report.Categories[i].Name = "Sin categoría"
+		}
+	}
+	for i := range report.Taxes {
+		report.Taxes[i].Base = utils.Round(report.Taxes[i].Base, 2)
+		report.Taxes[i].Amount = utils.Round(report.Taxes[i].Amount, 2)
+	}
+	for i := range report.Refunds.ByMethod {
+		report.Refunds.ByMethod[i].Amount = utils.Round(report.Refunds.ByMethod[i].Amount, 2)
+		report.Refunds.Count += report.Refunds.ByMethod[i].Count
+		report.Refunds.Total += report.Refunds.ByMethod[i].Amount
+	}
+	report.Refunds.Total = utils.Round(report.Refunds.Total, 2)
+
+	return report, nil
+}
+
+// SessionReportLines arma el reporte como texto de ancho fijo (para pantalla, descarga o impresora)
+func SessionReportLines(report *SessionReport, width int) []string {
+	var lines []string
+	line := func(text string) { lines = append(lines, text) }
+	columns := func(left, right string) {
+		space := width - len([]rune(right)) - 1
+		if space < 0 {
+			space = 0
+		}
+		if len([]rune(left)) > space {
+			left = string([]rune(left)[:space])
+		}
+		padding := width - len([]rune(left)) - len([]rune(right))
+		if padding < 1 {
+			padding = 1
+		}
+		line(left + strings.Repeat(" ", padding) + right)
+	}
+	separator := func() { line(strings.Repeat("-", width)) }
+	center := func(text string) {
+		padding := (width - len([]rune(text))) / 2
+		if padding < 0 {
+			padding = 0
+		}
+		line(strings.Repeat(" ", padding) + text)
+	}
+
+	if report.Type == ReportTypeZ {
+		center("REPORTE Z " + report.Name)
+	} else {
+		center("REPORTE X (PARCIAL)")
+	}
+	center(report.POSName)
+	separator()
+	columns("Sesión", "#"+strconv.FormatUint(uint64(report.SessionID), 10))
+	columns("Apertura", report.OpenedAt.Format("02/01/2006 15:04"))
+	if report.ClosedAt != nil {
+		columns("Cierre", report.ClosedAt.Format("02/01/2006 15:04"))
+	}
+	columns("Generado", report.GeneratedAt.Format("02/01/2006 15:04"))
+
+	separator()
+	line("VENTAS")
+	columns("Tickets", strconv.Itoa(report.TicketCount))
+	columns("Ticket promedio", formatAmount(report.AverageTicket))
+	columns("Venta neta", formatAmount(report.NetSales))
+	columns("Impuestos", formatAmount(report.TaxTotal))
+	columns("Descuentos", formatAmount(report.Discounts))
+	columns("Venta total", formatAmount(report.GrossSales))
+
+	separator()
+	line("MEDIOS DE PAGO")
+	for _, payment := range report.Payments {
+		columns(fmt.Sprintf("%s (%d)", payment.Name, payment.Count), formatAmount(payment.Amount))
+	}
+
+	separator()
+	line("CATEGORÍAS")
+	for _, category := range report.Categories {
+		columns(fmt.Sprintf("%s x %s", formatQuantity(category.Quantity), category.Name), formatAmount(category.Total))
+	}
+
+	separator()
+	line("IMPUESTOS")
+	for _, tax := range report.Taxes {
+		columns(fmt.Sprintf("%s %s%% s/ %s", tax.Name, strconv.FormatFloat(tax.RatePercent, 'f', -1, 64), formatAmount(tax.Base)), formatAmount(tax.Amount))
+	}
+
+	separator()
+	line("DEVOLUCIONES")
+	for _, refund := range report.Refunds.ByMethod {
+		columns(fmt.Sprintf("%s (%d)", refund.Name, refund.Count), formatAmount(refund.Amount))
+	}
+	columns(fmt.Sprintf("Total (%d)", report.Refunds.Count), formatAmount(report.Refunds.Total))
+
+	separator()
+	line("ANULACIONES")
+	columns(fmt.Sprintf("Líneas anuladas (%d)", report.Voids.Lines), formatAmount(report.Voids.LinesAmount))
+	columns(fmt.Sprintf("Órdenes canceladas (%d)", report.Voids.CancelledOrders), formatAmount(report.Voids.CancelledAmount))
+
+	separator()
+	line("EFECTIVO")
+	for _, movement := range report.CashMovements {
+		sign := "+"
+		if movement.Type == "out" {
+			sign = "-"
+		}
+		concept := movement.Concept
+		if movement.Currency != "" && movement.Currency != report.Cash.Currency {
+			concept = fmt.Sprintf("%s (%s %s)", concept, movement.Currency, formatAmount(movement.TenderedAmount))
+		}
+		columns(concept, sign+formatAmount(movement.Amount))
+	}
+	drawers := report.Cash.Drawers
+	if len(drawers) == 0 {
+		// Reportes Z anteriores a las monedas: un solo cajón
+		drawers = []CurrencyDrawer{{
+			OpeningBalance: report.Cash.OpeningBalance,
+			CashPayments:   report.Cash.CashPayments,
+			CashIn:         report.Cash.CashIn,
+			CashOut:        report.Cash.CashOut,
+			Expected:       report.Cash.Expected,
+			Counted:        report.Counted,
+			Difference:     report.Difference,
+		}}
+	}
+	for _, drawer := range drawers {
+		if len(drawers) > 1 {
+			line("Caja " + drawer.Currency)
+		}
+		columns("Fondo inicial", formatAmount(drawer.OpeningBalance))
+		columns("Cobros en efectivo", formatAmount(drawer.CashPayments))
+		if drawer.ChangeGiven != 0 {
+			columns("Vuelto", "-"+formatAmount(drawer.ChangeGiven))
+		}
+		columns("Ingresos", formatAmount(drawer.CashIn))
+		columns("Egresos", formatAmount(drawer.CashOut))
+		columns("Esperado en caja", formatAmount(drawer.Expected))
+		if report.Type == ReportTypeZ && drawer.Counted != nil && drawer.Difference != nil {
+			columns("Contado", formatAmount(*drawer.Counted))
+			columns("Diferencia", formatAmount(*drawer.Difference))
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,392 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"b-resto/utils"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StockCountService maneja el flujo de toma de inventario físico
+type StockCountService struct {
+	inventory *InventoryService
+}
+
+// NewStockCountService crea una nueva instancia del servicio
+func NewStockCountService() *StockCountService {
+	return &StockCountService{inventory: NewInventoryService()}
+}
+
+// CountInput - Cantidad contada de un producto
+type CountInput struct {
+	ProductID uint    `json:"product_id" binding:"required"`
+	Quantity  float64 `json:"quantity" binding:"gte=0"`
+	Mode      string  `json:"mode" binding:"omitempty,oneof=add set"` // add (default) o set
+}
+
+// VarianceLine - Diferencia de un producto entre lo esperado y lo contado
+type VarianceLine struct {
+	ProductID        uint     `json:"product_id"`
+	SKU              string   `json:"sku"`
+	ExpectedQuantity float64  `json:"expected_quantity"`
+	CountedQuantity  *float64 `json:"counted_quantity"`
+	Difference       float64  `json:"difference"`
+	UnitCost         float64  `json:"unit_cost"`
+	VarianceValue    float64  `json:"variance_value"`
+}
+
+// VarianceReport - Reporte de diferencias valorizado al costo
+type VarianceReport struct {
+	StockCountID   uint           `json:"stock_count_id"`
+	WarehouseID    uint           `json:"warehouse_id"`
+	State          string         `json:"state"`
+	Lines          []VarianceLine `json:"lines"`
+	CountedLines   int            `json:"counted_lines"`
+	UncountedLines int            `json:"uncounted_lines"`
+	SurplusValue   float64        `json:"surplus_value"`
+	ShortageValue  float64        `json:"shortage_value"`
+	NetVariance    float64        `json:"net_variance"`
+}
+
+// Start pasa el conteo a "counting" y toma el snapshot de saldos esperados del Kardex
+func (s *StockCountService) Start(countID uint) (*models.StockCount, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var count models.StockCount
+	if err := tx.First(&count, countID).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("stock count not found")
+	}
+	if count.State != "draft" {
+		tx.Rollback()
+		return nil, fmt.Errorf("stock count is %s, only draft counts can be started", count.State)
+	}
+
+	// Saldo actual de cada producto del almacén
+	var balances []models.StockQuant
+	query := tx.Where("stock_quants.warehouse_id = ?", count.WarehouseID)
+	if count.InventoryCategoryID != nil {
+		query = query.
+			Joins("JOIN product_product ON product_product.id = stock_quants.product_id").
+			Joins("JOIN product_template ON product_template.id = product_product.template_id").
+			Where("product_template.inventory_category_id = ?", *count.InventoryCategoryID)
+	}
+	if err := query.Find(&balances).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to snapshot stock: %w", err)
+	}
+
+	for _, balance := range balances {
+		line := models.StockCountLine{
+			StockCountID:     count.ID,
+			ProductID:        balance.ProductID,
+			ExpectedQuantity: balance.Quantity,
+			UnitCost:         balance.UnitCost,
+		}
+		if err := tx.Create(&line).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to create count line: %w", err)
+		}
+	}
+
+	now := time.Now()
+	count.State = "counting"
+	count.StartedAt = &now
+	if err := tx.Save(&count).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to start stock count: %w", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &count, nil
+}
+
+// RecordCounts registra cantidades contadas. "add" suma al conteo (conteos parciales por zona/usuario)
+// y "set" lo reemplaza (recuento). Los productos sin línea se agregan con su saldo actual como esperado.
+func (s *StockCountService) RecordCounts(countID uint, userID *uint, inputs []CountInput) (*models.StockCount, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var count models.StockCount
+	if err := tx.First(&count, countID).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("stock count not found")
+	}
+	if count.State != "counting" && count.State != "review" {
+		tx.Rollback()
+		return nil, fmt.Errorf("stock count is %s, counts can only be recorded while counting or in review", count.State)
+	}
+
+	for _, input := range inputs {
+		line, err := s.findOrCreateLine(tx, &count, input.ProductID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		mode := input.Mode
+		if mode == "" {
+			mode = "add"
+		}
+
+		counted := input.Quantity
+		if mode == "add" && line.CountedQuantity != nil {
+			counted += *line.CountedQuantity
+		}
+		line.CountedQuantity = &counted
+
+		if err := tx.Save(line).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to update count line: %w", err)
+		}
+
+		entry := models.StockCountEntry{
+			StockCountLineID: line.ID,
+			UserID:           userID,
+			Quantity:         input.Quantity,
+			Mode:             mode,
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to record count entry: %w", err)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &count, nil
+}
+
+// SubmitForReview cierra el conteo y lo deja listo para revisión
+func (s *StockCountService) SubmitForReview(countID uint) (*models.StockCount, error) {
+	var count models.StockCount
+	if err := config.DB.First(&count, countID).Error; err != nil {
+		return nil, errors.New("stock count not found")
+	}
+	if count.State != "counting" {
+		return nil, fmt.Errorf("stock count is %s, only counts in progress can be submitted for review", count.State)
+	}
+
+	count.State = "review"
+	if err := config.DB.Save(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to update stock count: %w", err)
+	}
+
+	return &count, nil
+}
+
+// Validate registra las diferencias como ajustes de inventario (count_correction) y cierra el conteo.
+// La diferencia se calcula contra el saldo actual (bajo lock), no contra el snapshot del inicio: las
+// ventas y compras registradas mientras se contaba ya movieron el saldo y no se cuentan dos veces.
+// Las líneas no contadas se ignoran.
+func (s *StockCountService) Validate(countID uint, userID *uint) (*models.StockCount, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var count models.StockCount
+	if err := tx.Preload("Lines").First(&count, countID).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("stock count not found")
+	}
+	if count.State != "review" {
+		tx.Rollback()
+		return nil, fmt.Errorf("stock count is %s, only counts in review can be validated", count.State)
+	}
+
+	keys := make([]stockKey, 0, len(count.Lines))
+	for _, line := range count.Lines {
+		keys = append(keys, stockKey{line.ProductID, count.WarehouseID})
+	}
+	if err := lockStock(tx, keys...); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	for i := range count.Lines {
+		line := &count.Lines[i]
+		if line.CountedQuantity == nil {
+			continue
+		}
+
+		current := s.inventory.lastKardex(tx, line.ProductID, count.WarehouseID).QuantityBalance
+		difference := *line.CountedQuantity - current
+		if math.Abs(difference) < quantityEpsilon {
+			continue
+		}
+
+		adjustment := models.InventoryAdjustment{
+			WarehouseID: count.WarehouseID,
+			ProductID:   line.ProductID,
+			Type:        "in",
+			Quantity:    math.Abs(difference),
+			Reason:      models.AdjustmentReasonCountCorrection,
+			UnitCost:    line.UnitCost,
+			TotalCost:   utils.Round(math.Abs(difference)*line.UnitCost, 2),
+			Status:      "pending",
+			Notes:       fmt.Sprintf("Stock count #%d - %s", count.ID, count.Name),
+			CreatedBy:   userID,
+		}
+		if difference < 0 {
+			adjustment.Type = "out"
+		}
+
+		if err := tx.Create(&adjustment).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to create adjustment: %w", err)
+		}
+		if err := s.inventory.applyAdjustment(tx, &adjustment, userID); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("product %d: %w", line.ProductID, err)
+		}
+
+		line.AdjustmentID = &adjustment.ID
+		if err := tx.Save(line).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to update count line: %w", err)
+		}
+	}
+
+	now := time.Now()
+	count.State = "validated"
+	count.ValidatedAt = &now
+	count.ValidatedBy = userID
+	if err := tx.Omit("Lines").Save(&count).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to validate stock count: %w", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &count, nil
+}
+
+// Cancel anula un conteo que aún no fue validado
+func (s *StockCountService) Cancel(countID uint) (*models.StockCount, error) {
+	var count models.StockCount
+	if err := config.DB.First(&count, countID).Error; err != nil {
+		return nil, errors.New("stock count not found")
+	}
+	if count.State == "validated" || count.State == "cancelled" {
+		return nil, fmt.Errorf("stock count is already %s", count.State)
+	}
+
+	count.State = "cancelled"
+	if err := config.DB.Save(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to cancel stock count: %w", err)
+	}
+
+	return &count, nil
+}
+
+// GetVarianceReport calcula las diferencias por producto valorizadas al costo del snapshot
+func (s *StockCountService) GetVarianceReport(countID uint) (*VarianceReport, error) {
+	var count models.StockCount
+	if err := config.DB.Preload("Lines.Product").First(&count, countID).Error; err != nil {
+		return nil, errors.New("stock count not found")
+	}
+
+	report := &VarianceReport{
+		StockCountID: count.ID,
+		WarehouseID:  count.WarehouseID,
+		State:        count.State,
+		Lines:        []VarianceLine{},
+	}
+
+	for _, line := range count.Lines {
+		variance := VarianceLine{
+			ProductID:        line.ProductID,
+			ExpectedQuantity: line.ExpectedQuantity,
+			CountedQuantity:  line.CountedQuantity,
+			UnitCost:         line.UnitCost,
+		}
+		if line.Product != nil {
+			variance.SKU = line.Product.SKU
+		}
+
+		if line.CountedQuantity == nil {
+			report.UncountedLines++
+		} else {
+			report.CountedLines++
+			variance.Difference = *line.CountedQuantity - line.ExpectedQuantity
+			variance.VarianceValue = utils.Round(variance.Difference*line.UnitCost, 2)
+			if variance.VarianceValue > 0 {
+				report.SurplusValue += variance.VarianceValue
+			} else {
+				report.ShortageValue += variance.VarianceValue
+			}
+		}
+
+		report.Lines = append(report.Lines, variance)
+	}
+
+	report.SurplusValue = utils.Round(report.SurplusValue, 2)
+	report.ShortageValue = utils.Round(report.ShortageValue, 2)
+	report.NetVariance = utils.Round(report.SurplusValue+report.ShortageValue, 2)
+
+	return report, nil
+}
+
+// findOrCreateLine obtiene la línea del producto o la crea con el saldo actual del Kardex como esperado
+func (s *StockCountService) findOrCreateLine(tx *gorm.DB, count *models.StockCount, productID uint) (*models.StockCountLine, error) {
+	var line models.StockCountLine
+	result := tx.Where("stock_count_id = ? AND product_id = ?", count.ID, productID).Limit(1).Find(&line)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to load count line: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return &line, nil
+	}
+
+	quant, err := s.inventory.stockQuant(tx, productID, count.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	line = models.StockCountLine{
+		StockCountID:     count.ID,
+		ProductID:        productID,
+		ExpectedQuantity: quant.Quantity,
+		UnitCost:         quant.UnitCost,
+	}
+	if err := tx.Create(&line).Error; err != nil {
+		return nil, fmt.Errorf("failed to create count line: %w", err)
+	}
+
+	return &line, nil
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StockFilter - Filtros del listado de existencias
+type StockFilter struct {
+	WarehouseID         string
+	CategoryID          string // Categoría de producto
+	InventoryCategoryID string
+	Zero                bool // Solo saldos en cero
+	Negative            bool // Solo saldos negativos
+}
+
+// stockQuant obtiene el saldo de un producto en un almacén. Si todavía no existe (movimientos
+// anteriores a la tabla de saldos) se inicializa desde el último movimiento del Kardex.
+func (s *InventoryService) stockQuant(tx *gorm.DB, productID, warehouseID uint) (*models.StockQuant, error) {
+	var quant models.StockQuant
+	result := tx.Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).Limit(1).Find(&quant)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to load stock quant: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return &quant, nil
+	}
+
+	last := s.lastKardex(tx, productID, warehouseID)
+	quant = models.StockQuant{
+		ProductID:         productID,
+		WarehouseID:       warehouseID,
+		Quantity:          last.QuantityBalance,
+		AvailableQuantity: last.QuantityBalance,
+		UnitCost:          last.CostBalance,
+		Value:             last.TotalBalance,
+	}
+	if last.ID != 0 {
+		quant.LastInventoryID = &last.ID
+	}
+
+	// Otra lectura pudo inicializarlo al mismo tiempo: en ese caso se usa el existente
+	result = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&quant)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to create stock quant: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if err := tx.Where("product_id = ? AND warehouse_id = ?", productID, warehouseID).First(&quant).Error; err != nil {
+			return nil, fmt.Errorf("failed to load stock quant: %w", err)
+		}
+	}
+
+	return &quant, nil
+}
+
+// applyQuant actualiza el saldo con el balance del movimiento recién registrado
+func (s *InventoryService) applyQuant(tx *gorm.DB, quant *models.StockQuant, row *models.Inventory) error {
+	quant.Quantity = row.QuantityBalance
+	quant.AvailableQuantity = row.QuantityBalance - quant.ReservedQuantity
+	quant.UnitCost = row.CostBalance
+	quant.Value = row.TotalBalance
+	quant.LastInventoryID = &row.ID
+
+	if err := tx.Save(quant).Error; err != nil {
+		return fmt.Errorf("failed to update stock quant: %w", err)
+	}
+	return nil
+}
+
+// reserve compromete (cantidad positiva) o libera (negativa) stock de un producto en un almacén
+func (s *InventoryService) reserve(tx *gorm.DB, productID, warehouseID uint, quantity float64) error {
+	if err := lockStock(tx, stockKey{productID, warehouseID}); err != nil {
+		return err
+	}
+
+	quant, err := s.stockQuant(tx, productID, warehouseID)
+	if err != nil {
+		return err
+	}
+
+	if quantity > 0 && quant.AvailableQuantity+quantityEpsilon < quantity {
+		return fmt.Errorf("insufficient stock for product %d: available %.4f, required %.4f",
+			productID, quant.AvailableQuantity, quantity)
+	}
+
+	quant.ReservedQuantity += quantity
+	if quant.ReservedQuantity < quantityEpsilon {
+		quant.ReservedQuantity = 0
+	}
+	quant.AvailableQuantity = quant.Quantity - quant.ReservedQuantity
+
+	if err := tx.Save(quant).Error; err != nil {
+		return fmt.Errorf("failed to update stock quant: %w", err)
+	}
+	return nil
+}
+
+// SendTransfer reserva en el almacén origen las cantidades de la transferencia y la pasa a in_transit
+func (s *InventoryService) SendTransfer(transfer *models.StockTransfer) error {
+	if transfer.Status == "in_transit" || transfer.Status == "received" || transfer.Status == "cancelled" {
+		return fmt.Errorf("stock transfer is %s and cannot be sent", transfer.Status)
+	}
+
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	keys := make([]stockKey, 0, len(transfer.Items))
+	for _, item := range transfer.Items {
+		keys = append(keys, stockKey{item.ProductID, transfer.FromWarehouseID})
+	}
+	if err := lockStock(tx, keys...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, item := range transfer.Items {
+		if err := s.reserve(tx, item.ProductID, transfer.FromWarehouseID, item.Quantity); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	transfer.Status = "in_transit"
+	if err := tx.Model(transfer).Update("status", transfer.Status).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update stock transfer: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// CancelTransfer cancela la transferencia liberando lo reservado si ya estaba en tránsito
+func (s *InventoryService) CancelTransfer(transfer *models.StockTransfer) error {
+	if transfer.Status == "received" {
+		return errors.New("received stock transfers cannot be cancelled")
+	}
+
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if transfer.Status == "in_transit" {
+		if err := s.releaseTransfer(tx, transfer.FromWarehouseID, transfer.Items); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	transfer.Status = "cancelled"
+	if err := tx.Model(transfer).Update("status", transfer.Status).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update stock transfer: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// releaseTransfer libera lo reservado por una transferencia en tránsito
+func (s *InventoryService) releaseTransfer(tx *gorm.DB, fromWarehouseID uint, items []models.StockTransferItem) error {
+	for _, item := range items {
+		if err := s.reserve(tx, item.ProductID, fromWarehouseID, -item.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListStock lista el saldo actual por producto y almacén
+func (s *InventoryService) ListStock(filter StockFilter) ([]models.StockQuant, error) {
+	var quants []models.StockQuant
+
+	query := config.DB.Model(&models.StockQuant{})
+	if filter.WarehouseID != "" {
+		query = query.Where("stock_quants.warehouse_id = ?", filter.WarehouseID)
+	}
+	if filter.CategoryID != "" || filter.InventoryCategoryID != "" {
+		query = query.
+			Joins("JOIN product_product ON product_product.id = stock_quants.product_id").
+			Joins("JOIN product_template ON product_template.id = product_product.template_id")
+		if filter.CategoryID != "" {
+			query = query.Where("product_template.category_id = ?", filter.CategoryID)
+		}
+		if filter.InventoryCategoryID != "" {
+			query = query.Where("product_template.inventory_category_id = ?", filter.InventoryCategoryID)
+		}
+	}
+	switch {
+	case filter.Zero && filter.Negative:
+		query = query.Where("stock_quants.quantity <= 0")
+	case filter.Zero:
+		query = query.Where("stock_quants.quantity = 0")
+	case filter.Negative:
+		query = query.Where("stock_quants.quantity < 0")
+	}
+
+	if err := query.
+		Preload("Product").
+		Preload("Warehouse").
+		Order("stock_quants.warehouse_id, stock_quants.product_id").
+		Find(&quants).Error; err != nil {
+		return nil, err
+	}
+
+	return quants, nil
+}
+
+// BackfillStockQuants crea los saldos que faltan a partir del último movimiento de cada producto/almacén
+func (s *InventoryService) BackfillStockQuants() error {
+	return config.DB.Exec(`
+		INSERT INTO stock_quants (product_id, warehouse_id, quantity, reserved_quantity, available_quantity,
+			unit_cost, value, last_inventory_id, created_at, updated_at)
+		SELECT product_id, warehouse_id, quantity_balance, 0, quantity_balance,
+			cost_balance, total_balance, id, NOW(), NOW()
+		FROM inventories
+		WHERE id IN (?)
+		ON CONFLICT (product_id, warehouse_id) DO NOTHING`, latestKardexIDs(config.DB)).Error
+}
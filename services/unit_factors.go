@@ -0,0 +1,56 @@
+package services
+
+import (
+	"b-resto/config"
+	"b-resto/models"
+	"fmt"
+	"strings"
+)
+
+// standardUnitFactors son las equivalencias de las unidades habituales respecto a la unidad base de
+// su tipo (kg, l, m). Las claves son abreviaturas en minúsculas.
+var standardUnitFactors = map[string]map[string]float64{
+	"weight": {
+		"g": 0.001, "gr": 0.001, "grs": 0.001, "mg": 0.000001,
+		"lb": 0.453592, "lbs": 0.453592, "oz": 0.0283495,
+	},
+	"volume": {
+		"ml": 0.001, "cl": 0.01, "dl": 0.1, "cc": 0.001,
+		"gal": 3.785411, "oz": 0.0295735, "floz": 0.0295735,
+	},
+	"length": {
+		"cm": 0.01, "mm": 0.001, "km": 1000,
+	},
+}
+
+// BackfillUnitFactors corrige las unidades que quedaron con el factor por defecto (1) al agregar la
+// columna factor: sin esto, g y kg (por ejemplo) se convierten 1:1 al descontar recetas. Solo toca
+// unidades de abreviatura conocida de los tipos en los que nadie configuró factores todavía (todas
+// sus unidades siguen en 1).
+func (s *InventoryService) BackfillUnitFactors() error {
+	var configured []string
+	if err := config.DB.Model(&models.Unit{}).Where("factor <> ?", 1).Distinct().Pluck("type", &configured).Error; err != nil {
+		return fmt.Errorf("failed to load unit types: %w", err)
+	}
+
+	query := config.DB.Where("factor = ?", 1)
+	if len(configured) > 0 {
+		query = query.Where("type NOT IN ?", configured)
+	}
+	var units []models.Unit
+	if err := query.Find(&units).Error; err != nil {
+		return fmt.Errorf("failed to load units: %w", err)
+	}
+
+	for _, unit := range units {
+		factor, ok := standardUnitFactors[unit.Type][strings.ToLower(strings.TrimSpace(unit.Abbreviation))]
+		if !ok {
+			continue
+		}
+		if err := config.DB.Model(&unit).Update("factor", factor).Error; err != nil {
+			return fmt.Errorf("failed to update unit %s: %w", unit.Abbreviation, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+// Alineaciones ESC/POS
+const (
+	AlignLeft   = 0
+	AlignCenter = 1
+	AlignRight  = 2
+)
+
+// cp850 traduce los caracteres del español a la página de códigos PC850 de las impresoras térmicas
+var cp850 = map[rune]byte{
+	'á': 0xA0, 'é': 0x82, 'í': 0xA1, 'ó': 0xA2, 'ú': 0xA3, 'ñ': 0xA4, 'Ñ': 0xA5,
+	'Á': 0xB5, 'É': 0x90, 'Í': 0xD6, 'Ó': 0xE0, 'Ú': 0xE9, 'ü': 0x81, 'Ü': 0x9A,
+	'¿': 0xA8, '¡': 0xAD, '°': 0xF8, 'º': 0xA7, 'ª': 0xA6, '€': 0xD5,
+}
+
+// ESCPOS arma el flujo de bytes de un documento para una impresora térmica ESC/POS
+type ESCPOS struct {
+	buf   bytes.Buffer
+	width int // Caracteres por línea (48 en papel de 80 mm, 32 en 58 mm)
+}
+
+// NewESCPOS inicializa la impresora y selecciona la página de códigos PC850
+func NewESCPOS(width int) *ESCPOS {
+	p := &ESCPOS{width: width}
+	p.buf.Write([]byte{0x1B, 0x40})       // ESC @: reinicio
+	p.buf.Write([]byte{0x1B, 0x74, 0x02}) // ESC t 2: PC850
+	return p
+}
+
+// Width devuelve los caracteres por línea
+func (p *ESCPOS) Width() int {
+	return p.width
+}
+
+// Align fija la alineación de las líneas siguientes
+func (p *ESCPOS) Align(align int) *ESCPOS {
+	p.buf.Write([]byte{0x1B, 0x61, byte(align)})
+	return p
+}
+
+// Bold activa o desactiva la negrita
+func (p *ESCPOS) Bold(on bool) *ESCPOS {
+	p.buf.Write([]byte{0x1B, 0x45, boolByte(on)})
+	return p
+}
+
+// DoubleSize activa o desactiva el doble alto y ancho (la línea admite la mitad de caracteres)
+func (p *ESCPOS) DoubleSize(on bool) *ESCPOS {
+	size := byte(0x00)
+	if on {
+		size = 0x11
+	}
+	p.buf.Write([]byte{0x1D, 0x21, size})
+	return p
+}
+
+// Text escribe texto sin salto de línea
+func (p *ESCPOS) Text(text string) *ESCPOS {
+	for _, r := range text {
+		switch {
+		case r == '\n' || (r >= 0x20 && r < 0x7F):
+			p.buf.WriteByte(byte(r))
+		default:
+			if b, ok := cp850[r]; ok {
+				p.buf.WriteByte(b)
+			} else {
+				p.buf.WriteByte('?')
+			}
+		}
+	}
+	return p
+}
+
+// Line escribe una línea de texto
+func (p *ESCPOS) Line(text string) *ESCPOS {
+	return p.Text(text).Text("\n")
+}
+
+// Wrap escribe un texto largo en varias líneas sin cortar palabras, con sangría opcional
+func (p *ESCPOS) Wrap(text, indent string) *ESCPOS {
+	limit := p.width - utf8.RuneCountInString(indent)
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > limit {
+			p.Line(indent + line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		p.Line(indent + line)
+	}
+	return p
+}
+
+// Columns escribe una línea con texto a la izquierda y a la derecha (ej: producto e importe)
+func (p *ESCPOS) Columns(left, right string) *ESCPOS {
+	space := p.width - utf8.RuneCountInString(right) - 1
+	if space < 0 {
+		space = 0
+	}
+	if utf8.RuneCountInString(left) > space {
+		left = string([]rune(left)[:space])
+	}
+	padding := p.width - utf8.RuneCountInString(left) - utf8.RuneCountInString(right)
+	if padding < 1 {
+		padding = 1
+	}
+	return p.Line(left + strings.Repeat(" ", padding) + right)
+}
+
+// Separator escribe una línea de guiones
+func (p *ESCPOS) Separator() *ESCPOS {
+	return p.Line(strings.Repeat("-", p.width))
+}
+
+// Feed avanza el papel n líneas
+func (p *ESCPOS) Feed(lines int) *ESCPOS {
+	p.buf.Write([]byte{0x1B, 0x64, byte(lines)})
+	return p
+}
+
+// QR imprime un código QR nativo (GS ( k) con corrección de errores M
+func (p *ESCPOS) QR(data string, moduleSize int) *ESCPOS {
+	content := []byte(data)
+	length := len(content) + 3
+
+	p.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x04, 0x00, 0x31, 0x41, 0x32, 0x00})                       // Modelo 2
+	p.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x43, byte(moduleSize)})                 // Tamaño del módulo
+	p.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x45, 0x31})                             // Corrección M
+	p.buf.Write([]byte{0x1D, 0x28, 0x6B, byte(length % 256), byte(length / 256), 0x31, 0x50, 0x30}) // Guardar datos
+	p.buf.Write(content)
+	p.buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x51, 0x30}) // Imprimir
+	return p
+}
+
+// Cut avanza el papel y hace un corte parcial
+func (p *ESCPOS) Cut() *ESCPOS {
+	p.buf.Write([]byte{0x1D, 0x56, 0x42, 0x03})
+	return p
+}
+
+// Bytes devuelve el documento listo para enviar a la impresora
+func (p *ESCPOS) Bytes() []byte {
+	return p.buf.Bytes()
+}
+
+func boolByte(on bool) byte {
+	if on {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,9 @@
+package utils
+
+import "math"
+
+// Round redondea un valor a la cantidad de decimales indicada (mitad hacia afuera)
+func Round(value float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	return math.Round(value*factor) / factor
+}